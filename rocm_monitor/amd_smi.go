@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// amdSMIValue is a loosely typed amd-smi JSON value. Depending on the release a metric is a
+// plain number, a string such as "N/A" or "45 C", or an object of the form {"value", "unit"}.
+// Objects and arrays keep their children in fields, arrays keyed by index.
+type amdSMIValue struct {
+	num    float64
+	unit   string
+	text   string
+	ok     bool
+	fields map[string]amdSMIValue
+}
+
+var amdSMINumberRegex = regexp.MustCompile(`^(-?\d+(?:\.\d+)?)\s*([A-Za-z%]*)$`)
+
+// newAmdSMIValue converts a decoded JSON value into an amdSMIValue
+func newAmdSMIValue(raw interface{}) amdSMIValue {
+	switch t := raw.(type) {
+	case json.Number:
+		num, err := t.Float64()
+		return amdSMIValue{num: num, text: t.String(), ok: err == nil}
+	case string:
+		value := amdSMIValue{text: strings.TrimSpace(t)}
+		if matches := amdSMINumberRegex.FindStringSubmatch(value.text); len(matches) > 2 {
+			value.num, _ = strconv.ParseFloat(matches[1], 64)
+			value.unit = matches[2]
+			value.ok = true
+		}
+		return value
+	case map[string]interface{}:
+		fields := make(map[string]amdSMIValue, len(t))
+		for key, item := range t {
+			fields[key] = newAmdSMIValue(item)
+		}
+		value := amdSMIValue{fields: fields}
+		if inner, ok := fields["value"]; ok {
+			value.num, value.text, value.ok = inner.num, inner.text, inner.ok
+			if unit, ok := t["unit"].(string); ok {
+				value.unit = unit
+			} else {
+				value.unit = inner.unit
+			}
+		}
+		return value
+	case []interface{}:
+		// Arrays are exposed as objects keyed by index so they can be walked like maps
+		fields := make(map[string]amdSMIValue, len(t))
+		for i, item := range t {
+			fields[strconv.Itoa(i)] = newAmdSMIValue(item)
+		}
+		return amdSMIValue{fields: fields}
+	default:
+		return amdSMIValue{}
+	}
+}
+
+// get walks nested objects along path
+func (v amdSMIValue) get(path ...string) amdSMIValue {
+	for _, key := range path {
+		next, ok := v.fields[key]
+		if !ok {
+			return amdSMIValue{}
+		}
+		v = next
+	}
+	return v
+}
+
+// number returns the numeric value of the first path that has one
+func (v amdSMIValue) number(paths ...[]string) (amdSMIValue, bool) {
+	for _, path := range paths {
+		if value := v.get(path...); value.ok {
+			return value, true
+		}
+	}
+	return amdSMIValue{}, false
+}
+
+// str returns the text of the first path that holds a usable string
+func (v amdSMIValue) str(paths ...[]string) (string, bool) {
+	for _, path := range paths {
+		text := v.get(path...).text
+		if text != "" && text != "N/A" {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+// gigabytes converts a memory value to GB, treating unit-less values as MB like amd-smi does
+func (v amdSMIValue) gigabytes() float64 {
+	switch strings.ToUpper(v.unit) {
+	case "B":
+		return v.num / (1024 * 1024 * 1024)
+	case "KB", "KIB":
+		return v.num / (1024 * 1024)
+	case "GB", "GIB":
+		return v.num
+	default:
+		return v.num / 1024
+	}
+}
+
+// decodeAmdSMIList returns the per-GPU entries of amd-smi --json output, which is a bare
+// array in ROCm 6.0-6.3 and wrapped in {"gpu_data": [...]} from ROCm 6.4 on
+func decodeAmdSMIList(output []byte) ([]amdSMIValue, error) {
+	start := bytes.IndexAny(output, "[{")
+	if start < 0 {
+		return nil, fmt.Errorf("no JSON document in amd-smi output")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(output[start:]))
+	decoder.UseNumber()
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid amd-smi JSON: %w", err)
+	}
+
+	if doc, ok := raw.(map[string]interface{}); ok {
+		if list, ok := doc["gpu_data"]; ok {
+			raw = list
+		} else {
+			raw = []interface{}{doc}
+		}
+	}
+
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected amd-smi JSON layout")
+	}
+
+	entries := make([]amdSMIValue, 0, len(list))
+	for _, item := range list {
+		entry := newAmdSMIValue(item)
+		if entry.fields == nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no GPU data found in amd-smi JSON")
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].get("gpu").num < entries[j].get("gpu").num
+	})
+	return entries, nil
+}
+
+// AmdSMISource collects data by running amd-smi, the successor of rocm-smi
+type AmdSMISource struct {
+	binary string
+}
+
+// NewAmdSMISource creates an amd-smi backed source; binary defaults to amd-smi from PATH
+func NewAmdSMISource(binary string) *AmdSMISource {
+	if binary == "" {
+		binary = "amd-smi"
+	}
+	return &AmdSMISource{binary: binary}
+}
+
+// Name returns the source identifier
+func (s *AmdSMISource) Name() string {
+	return "amd-smi"
+}
+
+// Collect runs amd-smi metric --json and converts the result
+func (s *AmdSMISource) Collect(ctx context.Context) (*RocmData, error) {
+	output, err := exec.CommandContext(ctx, s.binary, "metric", "--json").Output()
+	if err != nil {
+		return nil, execError(fmt.Errorf("amd-smi execution failed: %w", err))
+	}
+
+	data, err := ParseAmdSMIMetrics(output)
+	if err != nil {
+		return nil, parseError(fmt.Errorf("parsing failed: %w", err))
+	}
+	return data, nil
+}
+
+// ParseAmdSMIMetrics parses amd-smi metric --json output and returns structured data
+func ParseAmdSMIMetrics(output []byte) (*RocmData, error) {
+	entries, err := decodeAmdSMIList(output)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &RocmData{
+		Timestamp: time.Now(),
+		GPUs:      make([]GPU, 0, len(entries)),
+	}
+
+	for _, entry := range entries {
+		gpu := GPU{ID: int(entry.get("gpu").num)}
+
+		if v, ok := entry.number([]string{"temperature", "edge"}, []string{"temperature", "hotspot"}); ok {
+			gpu.Set(FieldTemperature, v.num)
+		}
+		if v, ok := entry.number(
+			[]string{"power", "socket_power"},
+			[]string{"power", "average_socket_power"},
+			[]string{"power", "current_socket_power"},
+		); ok {
+			gpu.Set(FieldPower, v.num)
+		}
+		if v, ok := entry.number([]string{"usage", "gfx_activity"}); ok {
+			gpu.Set(FieldGPUUsage, v.num)
+		}
+
+		// Fan usage is a percentage; older releases only report speed against max
+		if v, ok := entry.number([]string{"fan", "usage"}); ok {
+			gpu.Set(FieldFanSpeed, v.num)
+		} else if speed, ok := entry.number([]string{"fan", "speed"}); ok {
+			if maxSpeed, ok := entry.number([]string{"fan", "max"}); ok && maxSpeed.num > 0 {
+				gpu.Set(FieldFanSpeed, speed.num/maxSpeed.num*100)
+			}
+		}
+
+		if v, ok := entry.number([]string{"clock", "gfx_0", "clk"}, []string{"clock", "gfx_0", "cur_clk"}); ok {
+			gpu.Set(FieldSCLKFreq, v.num)
+		}
+		if v, ok := entry.number([]string{"clock", "mem_0", "clk"}, []string{"clock", "mem_0", "cur_clk"}); ok {
+			gpu.Set(FieldMCLKFreq, v.num)
+		}
+
+		if v, ok := entry.number([]string{"mem_usage", "total_vram"}); ok {
+			gpu.Set(FieldVRAMTotal, v.gigabytes())
+		}
+		if v, ok := entry.number([]string{"mem_usage", "used_vram"}); ok {
+			gpu.Set(FieldVRAMUsage, v.gigabytes())
+		}
+		if v, ok := entry.number([]string{"mem_usage", "total_visible_vram"}); ok {
+			gpu.Set(FieldVisVRAMTotal, v.gigabytes())
+		}
+		if v, ok := entry.number([]string{"mem_usage", "used_visible_vram"}); ok {
+			gpu.Set(FieldVisVRAMUsage, v.gigabytes())
+		}
+		if v, ok := entry.number([]string{"mem_usage", "total_gtt"}); ok {
+			gpu.Set(FieldGTTTotal, v.gigabytes())
+		}
+		if v, ok := entry.number([]string{"mem_usage", "used_gtt"}); ok {
+			gpu.Set(FieldGTTUsage, v.gigabytes())
+		}
+
+		data.GPUs = append(data.GPUs, gpu)
+	}
+
+	return data, nil
+}
+
+// StaticInfo runs amd-smi static and firmware queries and converts the result
+func (s *AmdSMISource) StaticInfo(ctx context.Context) ([]GPUStaticInfo, error) {
+	output, err := exec.CommandContext(ctx, s.binary, "static", "--json").Output()
+	if err != nil {
+		return nil, execError(fmt.Errorf("amd-smi execution failed: %w", err))
+	}
+
+	infos, err := ParseAmdSMIStatic(output)
+	if err != nil {
+		return nil, parseError(fmt.Errorf("parsing failed: %w", err))
+	}
+
+	// Firmware versions are optional; keep the static info if the query fails
+	if fwOutput, err := exec.CommandContext(ctx, s.binary, "firmware", "--json").Output(); err == nil {
+		if firmware, err := ParseAmdSMIFirmware(fwOutput); err == nil {
+			for i := range infos {
+				for name, version := range firmware[infos[i].ID] {
+					infos[i].FirmwareInfo[name] = version
+				}
+			}
+		}
+	}
+
+	return infos, nil
+}
+
+// ParseAmdSMIStatic parses amd-smi static --json output into static GPU info
+func ParseAmdSMIStatic(output []byte) ([]GPUStaticInfo, error) {
+	entries, err := decodeAmdSMIList(output)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]GPUStaticInfo, 0, len(entries))
+	for _, entry := range entries {
+		info := GPUStaticInfo{
+			ID:           int(entry.get("gpu").num),
+			ProductName:  "Unknown",
+			VendorName:   "AMD",
+			SerialNumber: "Not Available",
+			UniqueID:     "Not Available",
+			FirmwareInfo: make(map[string]string),
+			VRAMVendor:   "Not Available",
+			BusInfo:      "Not Available",
+		}
+
+		if v, ok := entry.str([]string{"asic", "market_name"}, []string{"board", "product_name"}); ok {
+			info.ProductName = v
+		}
+		if v, ok := entry.str([]string{"board", "product_serial"}); ok {
+			info.SerialNumber = v
+		}
+		if v, ok := entry.str([]string{"asic", "asic_serial"}); ok {
+			info.UniqueID = v
+		}
+		if v, ok := entry.str([]string{"vram", "vendor"}); ok {
+			info.VRAMVendor = v
+		}
+		if v, ok := entry.str([]string{"bus", "bdf"}); ok {
+			info.BusInfo = strings.ToUpper(v)
+		}
+		if v, ok := entry.str([]string{"vbios", "version"}); ok {
+			info.FirmwareInfo["VBIOS version"] = v
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// ParseAmdSMIFirmware parses amd-smi firmware --json output into firmware versions per GPU
+func ParseAmdSMIFirmware(output []byte) (map[int]map[string]string, error) {
+	entries, err := decodeAmdSMIList(output)
+	if err != nil {
+		return nil, err
+	}
+
+	firmware := make(map[int]map[string]string, len(entries))
+	for _, entry := range entries {
+		id := int(entry.get("gpu").num)
+		fwInfo := make(map[string]string)
+
+		// fw_list is an array of {"fw_id", "fw_version"} objects
+		for _, fw := range entry.get("fw_list").fields {
+			name, okName := fw.str([]string{"fw_id"}, []string{"fw_name"})
+			version, okVersion := fw.str([]string{"fw_version"})
+			if okName && okVersion {
+				fwInfo[name+" firmware version"] = version
+			}
+		}
+
+		firmware[id] = fwInfo
+	}
+
+	return firmware, nil
+}
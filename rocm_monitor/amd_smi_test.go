@@ -0,0 +1,268 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseAmdSMIMetricsFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []map[GPUField]float64 // Per GPU; fields left out must be unavailable
+	}{
+		{
+			// Plain numbers in MB, "N/A" fan
+			fixture: "rocm-6.0",
+			want: []map[GPUField]float64{{
+				FieldTemperature:  47,
+				FieldPower:        21,
+				FieldGPUUsage:     12,
+				FieldSCLKFreq:     1203,
+				FieldMCLKFreq:     1000,
+				FieldVRAMTotal:    0.5,
+				FieldVRAMUsage:    180.0 / 1024,
+				FieldVisVRAMTotal: 0.5,
+				FieldVisVRAMUsage: 180.0 / 1024,
+				FieldGTTTotal:     15560.0 / 1024,
+				FieldGTTUsage:     2204.0 / 1024,
+			}},
+		},
+		{
+			// {"value", "unit"} objects and socket_power
+			fixture: "rocm-6.2",
+			want: []map[GPUField]float64{{
+				FieldTemperature:  55,
+				FieldPower:        27,
+				FieldGPUUsage:     34,
+				FieldSCLKFreq:     2100,
+				FieldMCLKFreq:     2800,
+				FieldVRAMTotal:    0.5,
+				FieldVRAMUsage:    143.0 / 1024,
+				FieldVisVRAMTotal: 0.5,
+				FieldVisVRAMUsage: 143.0 / 1024,
+				FieldGTTTotal:     15560.0 / 1024,
+				FieldGTTUsage:     6021.0 / 1024,
+			}},
+		},
+		{
+			// gpu_data wrapper; only the discrete card reports a fan
+			fixture: "rocm-6.4",
+			want: []map[GPUField]float64{
+				{
+					FieldTemperature:  62,
+					FieldPower:        71,
+					FieldGPUUsage:     97,
+					FieldSCLKFreq:     2900,
+					FieldMCLKFreq:     8000,
+					FieldVRAMTotal:    0.5,
+					FieldVRAMUsage:    196.0 / 1024,
+					FieldVisVRAMTotal: 0.5,
+					FieldVisVRAMUsage: 196.0 / 1024,
+					FieldGTTTotal:     96,
+					FieldGTTUsage:     90,
+				},
+				{
+					FieldTemperature:  34,
+					FieldPower:        14,
+					FieldGPUUsage:     0,
+					FieldFanSpeed:     0,
+					FieldSCLKFreq:     0,
+					FieldMCLKFreq:     96,
+					FieldVRAMTotal:    16368.0 / 1024,
+					FieldVRAMUsage:    290.0 / 1024,
+					FieldVisVRAMTotal: 16368.0 / 1024,
+					FieldVisVRAMUsage: 290.0 / 1024,
+					FieldGTTTotal:     62793.0 / 1024,
+					FieldGTTUsage:     14.0 / 1024,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data, err := ParseAmdSMIMetrics(readFixture(t, "amd-smi", tt.fixture, "metric.json"))
+			if err != nil {
+				t.Fatalf("ParseAmdSMIMetrics: %v", err)
+			}
+			if len(data.GPUs) != len(tt.want) {
+				t.Fatalf("got %d GPUs, want %d", len(data.GPUs), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if data.GPUs[i].ID != i {
+					t.Errorf("GPU %d has ID %d", i, data.GPUs[i].ID)
+				}
+				assertGPUFields(t, data.GPUs[i], want)
+			}
+		})
+	}
+}
+
+func TestParseAmdSMIMetricsVariants(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   map[GPUField]float64
+	}{
+		{
+			name:   "string values with units and fan speed against max",
+			output: `[{"gpu": 0, "temperature": {"edge": "N/A", "hotspot": "61 C"}, "fan": {"speed": 64, "max": 255, "usage": "N/A"}, "mem_usage": {"total_vram": "8589934592 B", "used_vram": {"value": 1048576, "unit": "KB"}}}]`,
+			want: map[GPUField]float64{
+				FieldTemperature: 61,
+				FieldFanSpeed:    64.0 / 255 * 100,
+				FieldVRAMTotal:   8,
+				FieldVRAMUsage:   1,
+			},
+		},
+		{
+			name:   "warnings before the document and a single object",
+			output: "WARNING: User is missing the following required groups: render\n" + `{"gpu": 0, "power": {"current_socket_power": {"value": 5, "unit": "W"}}}`,
+			want:   map[GPUField]float64{FieldPower: 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ParseAmdSMIMetrics([]byte(tt.output))
+			if err != nil {
+				t.Fatalf("ParseAmdSMIMetrics: %v", err)
+			}
+			if len(data.GPUs) != 1 {
+				t.Fatalf("got %d GPUs, want 1", len(data.GPUs))
+			}
+			assertGPUFields(t, data.GPUs[0], tt.want)
+		})
+	}
+
+	for _, output := range []string{"", "amd-smi: command failed", `{"gpu_data": []}`, `"text"`} {
+		if _, err := ParseAmdSMIMetrics([]byte(output)); err == nil {
+			t.Errorf("ParseAmdSMIMetrics(%q) succeeded", output)
+		}
+	}
+}
+
+func TestParseAmdSMIStaticFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []GPUStaticInfo
+	}{
+		{
+			fixture: "rocm-6.0",
+			want: []GPUStaticInfo{{
+				ID:           0,
+				ProductName:  "Phoenix1",
+				SerialNumber: "Not Available",
+				UniqueID:     "Not Available",
+				VRAMVendor:   "Not Available",
+				BusInfo:      "0000:C4:00.0",
+				FirmwareInfo: map[string]string{"VBIOS version": "022.012.000.027.000001"},
+			}},
+		},
+		{
+			fixture: "rocm-6.2",
+			want: []GPUStaticInfo{{
+				ID:           0,
+				ProductName:  "AMD Radeon 780M",
+				SerialNumber: "Not Available",
+				UniqueID:     "0x0000000000000000",
+				VRAMVendor:   "Not Available",
+				BusInfo:      "0000:C4:00.0",
+				FirmwareInfo: map[string]string{"VBIOS version": "022.012.000.027.000001"},
+			}},
+		},
+		{
+			fixture: "rocm-6.4",
+			want: []GPUStaticInfo{
+				{
+					ID:           0,
+					ProductName:  "AMD Radeon Graphics",
+					SerialNumber: "Not Available",
+					UniqueID:     "Not Available",
+					VRAMVendor:   "Not Available",
+					BusInfo:      "0000:C5:00.0",
+					FirmwareInfo: map[string]string{"VBIOS version": "023.011.000.039.000001"},
+				},
+				{
+					ID:           1,
+					ProductName:  "AMD Radeon RX 9070 XT",
+					SerialNumber: "Not Available",
+					UniqueID:     "0x5B7C1E2D9A4F8036",
+					VRAMVendor:   "HYNIX",
+					BusInfo:      "0000:03:00.0",
+					FirmwareInfo: map[string]string{"VBIOS version": "023.008.000.068.000001"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			infos, err := ParseAmdSMIStatic(readFixture(t, "amd-smi", tt.fixture, "static.json"))
+			if err != nil {
+				t.Fatalf("ParseAmdSMIStatic: %v", err)
+			}
+			if len(infos) != len(tt.want) {
+				t.Fatalf("got %d GPUs, want %d", len(infos), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := infos[i]
+				if got.ID != want.ID || got.ProductName != want.ProductName || got.VendorName != "AMD" ||
+					got.SerialNumber != want.SerialNumber || got.UniqueID != want.UniqueID ||
+					got.VRAMVendor != want.VRAMVendor || got.BusInfo != want.BusInfo {
+					t.Errorf("GPU %d = %+v, want %+v", i, got, want)
+				}
+				if len(got.FirmwareInfo) != len(want.FirmwareInfo) || got.FirmwareInfo["VBIOS version"] != want.FirmwareInfo["VBIOS version"] {
+					t.Errorf("GPU %d firmware = %v, want %v", i, got.FirmwareInfo, want.FirmwareInfo)
+				}
+			}
+		})
+	}
+}
+
+func TestParseAmdSMIFirmwareFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		count   map[int]int               // Entries per GPU
+		sample  map[int]map[string]string // Spot checks
+	}{
+		{
+			fixture: "rocm-6.0",
+			count:   map[int]int{0: 8},
+			sample:  map[int]map[string]string{0: {"CP_MEC1 firmware version": "67", "PSP_SOSDRV firmware version": "00.00.00.00"}},
+		},
+		{
+			fixture: "rocm-6.2",
+			count:   map[int]int{0: 7},
+			sample:  map[int]map[string]string{0: {"PM firmware version": "00.76.79.00"}},
+		},
+		{
+			fixture: "rocm-6.4",
+			count:   map[int]int{0: 7, 1: 7},
+			sample: map[int]map[string]string{
+				0: {"VCN firmware version": "09.11.F0.06"},
+				1: {"RLC firmware version": "290521664", "PM firmware version": "00.104.22.00"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			firmware, err := ParseAmdSMIFirmware(readFixture(t, "amd-smi", tt.fixture, "firmware.json"))
+			if err != nil {
+				t.Fatalf("ParseAmdSMIFirmware: %v", err)
+			}
+			if len(firmware) != len(tt.count) {
+				t.Fatalf("got firmware for %d GPUs, want %d", len(firmware), len(tt.count))
+			}
+			for id, count := range tt.count {
+				if len(firmware[id]) != count {
+					t.Errorf("GPU %d has %d entries, want %d: %v", id, len(firmware[id]), count, firmware[id])
+				}
+				for key, want := range tt.sample[id] {
+					if got := firmware[id][key]; got != want {
+						t.Errorf("GPU %d %s = %q, want %q", id, key, got, want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -4,28 +4,67 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os/exec"
+	"strings"
 	"sync"
 	"time"
 )
 
 // Collector manages the data collection process
 type Collector struct {
-	parser        *Parser
+	source        Source
 	dataMutex     sync.RWMutex
-	history       []RocmData
-	maxHistory    int
-	interval      time.Duration
-	ctx           context.Context
-	cancel        context.CancelFunc
+	history       *HistoryRing  // Most recent samples; guarded by dataMutex
+	store         *HistoryStore // Optional on-disk copy of the history
+	tiers         []*RollupTier // Downsampled history, finest first; guarded by dataMutex
 	errorCallback func(error)
+
+	// Lifecycle of the collection loop; lifecycleMutex serialises Start, Stop and Reconfigure
+	lifecycleMutex sync.Mutex
+	cancel         context.CancelFunc // Cancels the running loop, nil when stopped
+	done           chan struct{}      // Closed when the running loop has exited
+	intervalCh     chan time.Duration // Holds the latest interval change for the running loop
+	intervalMutex  sync.RWMutex
+	interval       time.Duration
+
+	// Static GPU info cache, refreshed on startup, periodically and on device changes
+	staticMutex   sync.RWMutex
+	staticInfo    []GPUStaticInfo
+	staticUpdated time.Time
+	staticRefresh time.Duration
+	staticErr     error         // Last failed load, returned while nothing is cached
+	staticFailed  time.Time     // Time of staticErr; on-demand loads wait staticRetryGap after it
+	staticLoad    sync.Mutex    // Serialises on-demand loads so concurrent requests share one
+	deviceChange  chan struct{} // Asks the loop to re-read static info; holds at most one request
+
+	// Outcome and latency counters of collection attempts
+	stats *collectionStats
+
+	// Live consumers of new samples
+	subscribers subscriberSet
 }
 
 // CollectorConfig holds configuration for the collector
 type CollectorConfig struct {
-	MaxHistory    int
-	Interval      time.Duration
-	ErrorCallback func(error)
+	MaxHistory        int
+	Interval          time.Duration
+	Source            Source
+	StaticInfoRefresh time.Duration
+	Store             *HistoryStore // Optional; samples are persisted and reloaded at startup
+	Tiers             []TierConfig  // Rollup tiers, finest first; defaults to 1m and 15m
+	ErrorCallback     func(error)
+}
+
+// TierConfig describes a downsampled history tier
+type TierConfig struct {
+	Name       string
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+// DefaultTiers keeps 1-minute rollups for two days and 15-minute rollups for two weeks
+var DefaultTiers = []TierConfig{
+	{Name: "1m", Resolution: time.Minute, Retention: 48 * time.Hour},
+	{Name: "15m", Resolution: 15 * time.Minute, Retention: 14 * 24 * time.Hour},
 }
 
 // NewCollector creates a new collector instance
@@ -36,135 +75,376 @@ func NewCollector(config CollectorConfig) *Collector {
 	if config.Interval <= 0 {
 		config.Interval = 5 * time.Second
 	}
+	if config.Source == nil {
+		config.Source = NewRocmSMISource("", 0)
+	}
+	if config.StaticInfoRefresh <= 0 {
+		config.StaticInfoRefresh = 10 * time.Minute
+	}
+	if config.Tiers == nil {
+		config.Tiers = DefaultTiers
+	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	
-	return &Collector{
-		parser:        NewParser(),
-		history:       make([]RocmData, 0, config.MaxHistory),
-		maxHistory:    config.MaxHistory,
+	c := &Collector{
+		source:        config.Source,
+		history:       NewHistoryRing(config.MaxHistory),
+		store:         config.Store,
 		interval:      config.Interval,
-		ctx:           ctx,
-		cancel:        cancel,
 		errorCallback: config.ErrorCallback,
+		staticRefresh: config.StaticInfoRefresh,
+		deviceChange:  make(chan struct{}, 1),
+		stats:         newCollectionStats(),
 	}
+
+	for _, tier := range config.Tiers {
+		c.tiers = append(c.tiers, NewRollupTier(tier.Name, tier.Resolution, tier.Retention))
+	}
+
+	// Restore the in-memory window and rebuild the rollups from disk
+	if c.store != nil {
+		loaded := 0
+		err := c.store.Scan(func(data *RocmData) {
+			c.appendHistory(data)
+			loaded++
+		})
+		if err != nil {
+			log.Printf("Failed to load history: %v", err)
+		}
+		log.Printf("Loaded %d samples from the history store (%d kept in memory)", loaded, c.history.Len())
+	}
+
+	return c
 }
 
-// Start begins the collection process
+// Start begins the collection process; it does nothing if the collector is already running
 func (c *Collector) Start() {
-	go c.collectLoop()
+	c.lifecycleMutex.Lock()
+	defer c.lifecycleMutex.Unlock()
+
+	if c.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	c.intervalCh = make(chan time.Duration, 1)
+
+	go c.collectLoop(ctx, c.done, c.intervalCh)
 }
 
-// Stop halts the collection process
+// Stop halts the collection process and waits for the loop to exit; it does nothing if the
+// collector is not running
 func (c *Collector) Stop() {
+	c.lifecycleMutex.Lock()
+	defer c.lifecycleMutex.Unlock()
+
+	if c.cancel == nil {
+		return
+	}
+
 	c.cancel()
+	<-c.done
+	c.cancel = nil
+	c.done = nil
+	c.intervalCh = nil
+}
+
+// MinReconfigureInterval is the shortest interval Reconfigure accepts; collecting a sample
+// takes several tool runs, and the API must not be able to make the loop spin
+const MinReconfigureInterval = time.Second
+
+// Reconfigure changes the collection interval. A running loop picks up the new interval
+// without restarting; setting the current interval again does nothing.
+func (c *Collector) Reconfigure(interval time.Duration) error {
+	if interval < MinReconfigureInterval {
+		return fmt.Errorf("invalid interval %v: must be at least %v", interval, MinReconfigureInterval)
+	}
+
+	c.lifecycleMutex.Lock()
+	defer c.lifecycleMutex.Unlock()
+
+	if interval == c.Interval() {
+		return nil
+	}
+
+	c.intervalMutex.Lock()
+	c.interval = interval
+	c.intervalMutex.Unlock()
+
+	// The loop may be mid-collect; replace any change it has not picked up yet instead of
+	// waiting for it. Only Reconfigure sends, under lifecycleMutex, so the send cannot block.
+	if c.cancel != nil {
+		select {
+		case <-c.intervalCh:
+		default:
+		}
+		c.intervalCh <- interval
+	}
+	return nil
 }
 
-// collectLoop runs the collection process
-func (c *Collector) collectLoop() {
-	ticker := time.NewTicker(c.interval)
+// Interval returns the current collection interval
+func (c *Collector) Interval() time.Duration {
+	c.intervalMutex.RLock()
+	defer c.intervalMutex.RUnlock()
+	return c.interval
+}
+
+// collectLoop runs the collection process until ctx is cancelled, then closes done
+func (c *Collector) collectLoop(ctx context.Context, done chan<- struct{}, intervalCh <-chan time.Duration) {
+	defer close(done)
+
+	ticker := time.NewTicker(c.Interval())
 	defer ticker.Stop()
 
+	staticTicker := time.NewTicker(c.staticRefresh)
+	defer staticTicker.Stop()
+
+	// Load static info unless a previous run already cached it recently
+	if c.staticInfoAge() < 0 || c.staticInfoAge() >= c.staticRefresh {
+		c.refreshStaticInfo(ctx, "startup")
+	}
+
 	// Collect initial data
-	c.collect()
+	c.collect(ctx)
+
+	// Device changes are answered at most once per deviceRefreshGap
+	var lastDeviceRefresh time.Time
 
 	for {
 		select {
-		case <-c.ctx.Done():
+		case <-ctx.Done():
 			return
+		case interval := <-intervalCh:
+			ticker.Reset(interval)
 		case <-ticker.C:
-			c.collect()
+			c.collect(ctx)
+		case <-staticTicker.C:
+			c.refreshStaticInfo(ctx, "scheduled refresh")
+		case <-c.deviceChange:
+			if time.Since(lastDeviceRefresh) >= deviceRefreshGap {
+				lastDeviceRefresh = time.Now()
+				c.refreshStaticInfo(ctx, "GPU device change")
+			}
 		}
 	}
 }
 
-// collect executes rocm-smi and stores the data
-func (c *Collector) collect() {
-	// Create context with timeout for command execution
-	ctx, cancel := context.WithTimeout(c.ctx, 3*time.Second)
+// collect queries the data source and stores the data
+func (c *Collector) collect(ctx context.Context) {
+	start := time.Now()
+	defer func() { c.stats.observe(time.Since(start)) }()
+
+	// Bound the whole sample; sources apply tighter limits to individual queries
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	// Execute rocm-smi with timeout protection
-	cmd := exec.CommandContext(ctx, "rocm-smi")
-	output, err := cmd.Output()
-	
+	data, err := c.source.Collect(ctx)
 	if err != nil {
-		if c.errorCallback != nil {
-			c.errorCallback(fmt.Errorf("rocm-smi execution failed: %w", err))
+		// A collection interrupted by Stop is not a failure
+		if ctx.Err() == context.Canceled {
+			return
 		}
+		c.reportError(errorCategory(err), err, true)
 		return
 	}
 
-	// Also get detailed VRAM information
-	cmdVRAM := exec.CommandContext(ctx, "rocm-smi", "--showmeminfo", "vram")
-	vramOutput, vramErr := cmdVRAM.Output()
-	
-	// Get clock frequencies
-	cmdClock := exec.CommandContext(ctx, "rocm-smi", "-c")
-	clockOutput, clockErr := cmdClock.Output()
-	
-	// Combine outputs for parsing
-	combinedOutput := string(output)
-	if vramErr == nil {
-		combinedOutput += "\n" + string(vramOutput)
-	}
-	if clockErr == nil {
-		combinedOutput += "\n" + string(clockOutput)
-	}
-	// Parse the combined output
-	data, err := c.parser.ParseRocmSMIOutput(combinedOutput)
-	if err != nil {
-		if c.errorCallback != nil {
-			c.errorCallback(fmt.Errorf("parsing failed: %w", err))
-		}
-		return
+	// Derive combined VRAM + GTT figures
+	for i := range data.GPUs {
+		data.GPUs[i].UpdateAccessibleMemory()
 	}
 
 	// Get CPU usage
-	cpuUsage, err := GetCPUUsage()
+	cpuUsage, cpuOK, err := GetCPUUsage()
 	if err != nil {
-		if c.errorCallback != nil {
-			c.errorCallback(fmt.Errorf("CPU usage collection failed: %w", err))
-		}
 		// Continue without CPU data
-		cpuUsage = 0
+		c.reportError(ErrorCPU, fmt.Errorf("CPU usage collection failed: %w", err), false)
 	}
 	data.CPUUsage = cpuUsage
+	data.CPUAvailable = cpuOK
 
-	// Validate the data
-	if err := data.Validate(); err != nil {
-		if c.errorCallback != nil {
-			c.errorCallback(fmt.Errorf("validation failed: %w", err))
-		}
+	// Validate the data; implausible values are dropped from the sample rather than rejecting it
+	problems, err := data.Validate()
+	if err != nil {
+		c.reportError(ErrorValidate, fmt.Errorf("validation failed: %w", err), true)
 		return
 	}
+	if len(problems) > 0 {
+		c.reportError(ErrorValidate, fmt.Errorf("dropped implausible values: %s", strings.Join(problems, ", ")), false)
+	}
+
+	// Have the loop re-read static info when the set of devices changed; a pending request
+	// already covers this sample
+	if c.devicesChanged(data) {
+		select {
+		case c.deviceChange <- struct{}{}:
+		default:
+		}
+	}
 
 	// Store the data
 	c.dataMutex.Lock()
-	c.history = append(c.history, *data)
-	
-	// Maintain history size limit
-	if len(c.history) > c.maxHistory {
-		// Keep only the most recent data
-		c.history = c.history[len(c.history)-c.maxHistory:]
-	}
+	c.appendHistory(data)
 	c.dataMutex.Unlock()
+	c.stats.success()
+	c.publish(*data)
+
+	if c.store != nil {
+		if err := c.store.Append(data); err != nil && c.errorCallback != nil {
+			c.errorCallback(fmt.Errorf("history store: %w", err))
+		}
+	}
+
+	if len(data.Unavailable) > 0 {
+		log.Printf("Partial sample: unavailable %s", strings.Join(data.Unavailable, ", "))
+	}
 
 	log.Printf("Collected data for %d GPUs at %s (SCLK: %.0f, MCLK: %.0f)", len(data.GPUs), data.Timestamp.Format(time.RFC3339), 
 		func() float64 { if len(data.GPUs) > 0 { return data.GPUs[0].SCLKFreq } else { return 0 } }(),
 		func() float64 { if len(data.GPUs) > 0 { return data.GPUs[0].MCLKFreq } else { return 0 } }())
 }
 
+// appendHistory stores a sample in the raw window and the rollup tiers; the caller must hold
+// dataMutex unless the collector is not shared yet
+func (c *Collector) appendHistory(data *RocmData) {
+	// The ring drops the oldest sample once it is full
+	c.history.Push(*data)
+
+	for _, tier := range c.tiers {
+		tier.Add(data)
+	}
+}
+
+// reportError counts an error in its category and passes it to the error callback.
+// Fatal errors are those that cost the sample.
+func (c *Collector) reportError(category ErrorCategory, err error, fatal bool) {
+	c.stats.failure(category, err, fatal)
+	if c.errorCallback != nil {
+		c.errorCallback(err)
+	}
+}
+
+// GetCollectionStats returns the collector's outcome and latency counters
+func (c *Collector) GetCollectionStats() CollectionStats {
+	if c.stats == nil {
+		return newCollectionStats().snapshot()
+	}
+	return c.stats.snapshot()
+}
+
 // GetHistory returns a copy of the collected data history
 func (c *Collector) GetHistory() []RocmData {
 	c.dataMutex.RLock()
 	defer c.dataMutex.RUnlock()
 	
 	// Return a copy to prevent external modification
-	historyCopy := make([]RocmData, len(c.history))
-	copy(historyCopy, c.history)
-	
-	return historyCopy
+	return c.history.AppendTo(make([]RocmData, 0, c.history.Len()), 0)
+}
+
+// GetHistorySince returns a copy of the samples taken after cutoff, or nil if there are none
+func (c *Collector) GetHistorySince(cutoff time.Time) []RocmData {
+	c.dataMutex.RLock()
+	defer c.dataMutex.RUnlock()
+
+	from := c.history.Search(cutoff)
+	if from == c.history.Len() {
+		return nil
+	}
+	return c.history.AppendTo(make([]RocmData, 0, c.history.Len()-from), from)
+}
+
+// GetHistoryPage returns a copy of at most limit samples taken after cutoff, oldest first,
+// skipping those with a sequence number below next, and the sequence number to pass as next
+// for the following page. Exports page through the history with it so the lock is never held
+// for long; paging by sequence rather than by time keeps samples sharing a timestamp together.
+func (c *Collector) GetHistoryPage(cutoff time.Time, next uint64, limit int) ([]RocmData, uint64) {
+	c.dataMutex.RLock()
+	defer c.dataMutex.RUnlock()
+
+	from := c.history.Search(cutoff)
+	if position := c.history.Position(next); position > from {
+		from = position
+	}
+	n := c.history.Len() - from
+	if n > limit {
+		n = limit
+	}
+	if n <= 0 {
+		return nil, next
+	}
+	page := make([]RocmData, 0, n)
+	c.history.Range(from, func(data *RocmData) bool {
+		page = append(page, *data)
+		return len(page) < n
+	})
+	return page, c.history.Sequence(from + n)
+}
+
+// ViewHistory calls fn with a read-only view of the history without copying it. The read
+// lock is held while fn runs, so fn must be quick, must not write to the network and must
+// not call other Collector methods.
+func (c *Collector) ViewHistory(fn func(view HistoryView)) {
+	c.dataMutex.RLock()
+	defer c.dataMutex.RUnlock()
+
+	fn(HistoryView{ring: c.history})
+}
+
+// HistoryLen returns the number of samples in the in-memory history
+func (c *Collector) HistoryLen() int {
+	c.dataMutex.RLock()
+	defer c.dataMutex.RUnlock()
+
+	return c.history.Len()
+}
+
+// ResolutionRaw names the undownsampled history in SelectResolution and GetRollups
+const ResolutionRaw = "raw"
+
+// SelectResolution picks the finest history that covers the window: raw samples if they
+// reach back far enough, else the first tier that does, else whichever reaches furthest back
+func (c *Collector) SelectResolution(window time.Duration) string {
+	c.dataMutex.RLock()
+	defer c.dataMutex.RUnlock()
+
+	cutoff := time.Now().Add(-window)
+	best := ResolutionRaw
+	var bestOldest time.Time
+	if oldest := c.history.Oldest(); oldest != nil {
+		bestOldest = oldest.Timestamp
+		if !bestOldest.After(cutoff) {
+			return ResolutionRaw
+		}
+	}
+
+	for _, tier := range c.tiers {
+		oldest := tier.Oldest()
+		if oldest.IsZero() {
+			continue
+		}
+		if !oldest.After(cutoff) {
+			return tier.Name
+		}
+		// Only prefer a coarser history if it holds more than one extra interval
+		if bestOldest.IsZero() || oldest.Before(bestOldest.Add(-tier.Resolution)) {
+			best, bestOldest = tier.Name, oldest
+		}
+	}
+	return best
+}
+
+// GetRollups returns the points of the named tier that overlap the time since cutoff
+func (c *Collector) GetRollups(name string, cutoff time.Time) ([]RollupPoint, error) {
+	c.dataMutex.RLock()
+	defer c.dataMutex.RUnlock()
+
+	for _, tier := range c.tiers {
+		if tier.Name == name {
+			return tier.Since(cutoff), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown resolution %q", name)
 }
 
 // GetLatest returns the most recent data point
@@ -172,37 +452,177 @@ func (c *Collector) GetLatest() (*RocmData, error) {
 	c.dataMutex.RLock()
 	defer c.dataMutex.RUnlock()
 	
-	if len(c.history) == 0 {
+	newest := c.history.Newest()
+	if newest == nil {
 		return nil, fmt.Errorf("no data available")
 	}
 	
-	latest := c.history[len(c.history)-1]
+	latest := *newest
 	return &latest, nil
 }
 
-// SetInterval updates the collection interval
-func (c *Collector) SetInterval(interval time.Duration) {
-	if interval <= 0 {
-		return
+// GetStaticInfo returns the cached static GPU information. Refreshing is left to the
+// collection loop; only while nothing is cached is it loaded on demand, at most once per
+// staticRetryGap, and a failed load is reported until then.
+func (c *Collector) GetStaticInfo() ([]GPUStaticInfo, error) {
+	if c.source == nil {
+		return nil, fmt.Errorf("no data source configured")
 	}
-	
-	// Stop current collection
-	c.Stop()
-	
-	// Update interval
-	c.interval = interval
-	
-	// Create new context and restart
-	c.ctx, c.cancel = context.WithCancel(context.Background())
-	c.Start()
+
+	cached, err := c.cachedStaticInfo()
+	if cached == nil && err == nil {
+		c.staticLoad.Lock()
+		// Another request may have loaded it, or failed to, while this one waited
+		if cached, err = c.cachedStaticInfo(); cached == nil && err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), staticLoadTimeout)
+			err = c.refreshStaticInfo(ctx, "first request")
+			cancel()
+			cached, _ = c.cachedStaticInfo()
+		}
+		c.staticLoad.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Return a copy to prevent external modification
+	infoCopy := make([]GPUStaticInfo, len(cached))
+	copy(infoCopy, cached)
+	return infoCopy, nil
 }
 
-// ClearHistory removes all collected data
-func (c *Collector) ClearHistory() {
-	c.dataMutex.Lock()
-	defer c.dataMutex.Unlock()
-	
-	c.history = c.history[:0]
+// cachedStaticInfo returns the cached static info, or the error of a load that failed less
+// than staticRetryGap ago; both are nil when a load is due
+func (c *Collector) cachedStaticInfo() ([]GPUStaticInfo, error) {
+	c.staticMutex.RLock()
+	defer c.staticMutex.RUnlock()
+
+	if c.staticInfo != nil {
+		return c.staticInfo, nil
+	}
+	if c.staticErr != nil && time.Since(c.staticFailed) < staticRetryGap {
+		return nil, fmt.Errorf("static info unavailable: %w", c.staticErr)
+	}
+	return nil, nil
+}
+
+// GetStaticInfoAge returns how long ago the static info cache was refreshed, or -1 if never
+func (c *Collector) GetStaticInfoAge() time.Duration {
+	return c.staticInfoAge()
+}
+
+// staticInfoAge returns the age of the static info cache, or -1 if it was never loaded
+func (c *Collector) staticInfoAge() time.Duration {
+	c.staticMutex.RLock()
+	defer c.staticMutex.RUnlock()
+
+	if c.staticUpdated.IsZero() {
+		return -1
+	}
+	return time.Since(c.staticUpdated)
+}
+
+// refreshStaticInfo queries the source for static info and replaces the cache
+func (c *Collector) refreshStaticInfo(ctx context.Context, reason string) error {
+	if c.source == nil {
+		return fmt.Errorf("no data source configured")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	infos, err := c.source.StaticInfo(ctx)
+	if err != nil {
+		c.staticMutex.Lock()
+		c.staticErr = err
+		c.staticFailed = time.Now()
+		c.staticMutex.Unlock()
+
+		if c.errorCallback != nil {
+			c.errorCallback(fmt.Errorf("static info refresh (%s) failed: %w", reason, err))
+		}
+		return err
+	}
+
+	c.staticMutex.Lock()
+	previous := c.staticInfo
+	c.staticInfo = infos
+	c.staticUpdated = time.Now()
+	c.staticErr = nil
+	c.staticMutex.Unlock()
+
+	if previous != nil && !sameDevices(previous, infos) {
+		log.Printf("GPU devices changed (%s): %d -> %d GPUs", reason, len(previous), len(infos))
+	}
+
+	return nil
+}
+
+// deviceRefreshGap is the minimum time between static info refreshes caused by device changes
+const deviceRefreshGap = time.Minute
+
+// Limits on loading static info outside the collection loop, which happens on the request
+// path of /metrics, /api/gpuinfo and the push exporters
+const (
+	staticRetryGap    = time.Minute
+	staticLoadTimeout = 5 * time.Second
+)
+
+// devicesChanged reports whether a sample's GPUs differ from the cached static info. GPUs
+// are matched by ID, and by unique ID where both sides report one.
+func (c *Collector) devicesChanged(data *RocmData) bool {
+	c.staticMutex.RLock()
+	defer c.staticMutex.RUnlock()
+
+	// Nothing to compare against until the cache has been loaded once
+	if c.staticInfo == nil {
+		return false
+	}
+	if len(c.staticInfo) != len(data.GPUs) {
+		return true
+	}
+
+	known := make(map[int]GPUStaticInfo, len(c.staticInfo))
+	for _, info := range c.staticInfo {
+		known[info.ID] = info
+	}
+	for _, gpu := range data.GPUs {
+		info, ok := known[gpu.ID]
+		if !ok {
+			return true
+		}
+		if validUniqueID(gpu.UniqueID) && validUniqueID(info.UniqueID) && !strings.EqualFold(gpu.UniqueID, info.UniqueID) {
+			return true
+		}
+	}
+	return false
+}
+
+// validUniqueID reports whether a unique ID identifies a device; tools report placeholders
+// such as "N/A" or all zeros when the GPU has none
+func validUniqueID(id string) bool {
+	if id == "" || id == "Not Available" || id == "N/A" {
+		return false
+	}
+	return strings.Trim(strings.TrimPrefix(strings.ToLower(id), "0x"), "0") != ""
+}
+
+// sameDevices reports whether two static info snapshots describe the same GPUs
+func sameDevices(a, b []GPUStaticInfo) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	uniqueIDs := make(map[int]string, len(a))
+	for _, info := range a {
+		uniqueIDs[info.ID] = info.UniqueID + "|" + info.BusInfo
+	}
+	for _, info := range b {
+		if id, ok := uniqueIDs[info.ID]; !ok || id != info.UniqueID+"|"+info.BusInfo {
+			return false
+		}
+	}
+	return true
 }
 
 // GetStats returns statistics about the collected data
@@ -211,33 +631,87 @@ func (c *Collector) GetStats() map[string]interface{} {
 	defer c.dataMutex.RUnlock()
 	
 	stats := make(map[string]interface{})
-	stats["history_size"] = len(c.history)
-	stats["max_history"] = c.maxHistory
-	stats["interval_seconds"] = c.interval.Seconds()
+	stats["history_size"] = c.history.Len()
+	stats["max_history"] = c.history.Cap()
+	tiers := make(map[string]interface{}, len(c.tiers))
+	for _, tier := range c.tiers {
+		tiers[tier.Name] = map[string]interface{}{
+			"resolution_seconds": tier.Resolution.Seconds(),
+			"retention_seconds":  tier.Retention.Seconds(),
+			"points":             tier.Len(),
+		}
+	}
+	stats["rollup_tiers"] = tiers
+	stats["interval_seconds"] = c.Interval().Seconds()
+	if c.source != nil {
+		stats["source"] = c.source.Name()
+	}
+	if age := c.staticInfoAge(); age >= 0 {
+		stats["static_info_age_seconds"] = age.Seconds()
+	}
+	stats["static_info_refresh_seconds"] = c.staticRefresh.Seconds()
+	liveSubscribers, droppedSubscribers := c.subscriberCounts()
+	stats["stream_clients"] = liveSubscribers
+	stats["stream_clients_dropped"] = float64(droppedSubscribers)
+
+	// Collector health; values are float64 so exporters can treat them uniformly
+	collection := c.GetCollectionStats()
+	stats["total_collections"] = float64(collection.Successful)
+	stats["failed_collections"] = float64(collection.Failed)
+	stats["collection_errors"] = float64(collection.TotalErrors())
+	for _, category := range ErrorCategories {
+		stats["collection_errors_"+string(category)] = float64(collection.Errors[category])
+	}
+	stats["avg_collection_time_ms"] = float64(collection.AvgLatency()) / float64(time.Millisecond)
+	stats["last_collection_time_ms"] = float64(collection.LastDuration) / float64(time.Millisecond)
+	stats["uptime_seconds"] = time.Since(collection.StartTime).Seconds()
+	stats["memory_usage_mb"] = memoryUsageMB()
+	if collection.LastError != "" {
+		stats["last_error"] = collection.LastError
+		stats["last_error_time"] = collection.LastErrorTime
+	}
 	
-	if len(c.history) > 0 {
-		stats["oldest_timestamp"] = c.history[0].Timestamp
-		stats["newest_timestamp"] = c.history[len(c.history)-1].Timestamp
+	if c.history.Len() > 0 {
+		stats["oldest_timestamp"] = c.history.Oldest().Timestamp
+		stats["newest_timestamp"] = c.history.Newest().Timestamp
 		
-		// Calculate average values across all GPUs and time
+		// Calculate average values across all GPUs and time, skipping unreported metrics
 		var totalTemp, totalPower, totalGPU, totalVRAM float64
-		var count int
+		var countTemp, countPower, countGPU, countVRAM int
 		
-		for _, data := range c.history {
+		c.history.Range(0, func(data *RocmData) bool {
 			for _, gpu := range data.GPUs {
-				totalTemp += gpu.Temperature
-				totalPower += gpu.Power
-				totalGPU += gpu.GPUUsage
-				totalVRAM += gpu.VRAMUsage
-				count++
+				if gpu.Has(FieldTemperature) {
+					totalTemp += gpu.Temperature
+					countTemp++
+				}
+				if gpu.Has(FieldPower) {
+					totalPower += gpu.Power
+					countPower++
+				}
+				if gpu.Has(FieldGPUUsage) {
+					totalGPU += gpu.GPUUsage
+					countGPU++
+				}
+				if gpu.Has(FieldVRAMUsage) {
+					totalVRAM += gpu.VRAMUsage
+					countVRAM++
+				}
 			}
-		}
+			return true
+		})
 		
-		if count > 0 {
-			stats["avg_temperature"] = totalTemp / float64(count)
-			stats["avg_power"] = totalPower / float64(count)
-			stats["avg_gpu_usage"] = totalGPU / float64(count)
-			stats["avg_vram_usage"] = totalVRAM / float64(count)
+		if countTemp > 0 {
+			stats["avg_temperature"] = totalTemp / float64(countTemp)
+		}
+		if countPower > 0 {
+			stats["avg_power"] = totalPower / float64(countPower)
+		}
+		if countGPU > 0 {
+			stats["avg_gpu_usage"] = totalGPU / float64(countGPU)
+		}
+		if countVRAM > 0 {
+			stats["avg_vram_usage"] = totalVRAM / float64(countVRAM)
 		}
 	}
 	
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ErrorCategory classifies why a collection step failed
+type ErrorCategory string
+
+const (
+	ErrorExec     ErrorCategory = "exec"     // The source tool could not be run or read
+	ErrorParse    ErrorCategory = "parse"    // The source output could not be understood
+	ErrorValidate ErrorCategory = "validate" // The sample was rejected or had values dropped
+	ErrorCPU      ErrorCategory = "cpu"      // CPU usage could not be read
+)
+
+// ErrorCategories lists every category in reporting order
+var ErrorCategories = []ErrorCategory{ErrorExec, ErrorParse, ErrorValidate, ErrorCPU}
+
+// CollectionError is an error tagged with the collection step that failed
+type CollectionError struct {
+	Category ErrorCategory
+	Err      error
+}
+
+func (e *CollectionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CollectionError) Unwrap() error {
+	return e.Err
+}
+
+// execError tags err as a failure to run or read the data source
+func execError(err error) error {
+	return &CollectionError{Category: ErrorExec, Err: err}
+}
+
+// parseError tags err as a failure to understand the data source output
+func parseError(err error) error {
+	return &CollectionError{Category: ErrorParse, Err: err}
+}
+
+// errorCategory returns the category of err; untagged source errors count as exec errors
+func errorCategory(err error) ErrorCategory {
+	var collectionErr *CollectionError
+	if errors.As(err, &collectionErr) {
+		return collectionErr.Category
+	}
+	return ErrorExec
+}
+
+// collectionLatencyBuckets are the upper bounds of the collection latency histogram in seconds
+var collectionLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// CollectionStats is a snapshot of the collector's own health counters
+type CollectionStats struct {
+	StartTime         time.Time                `json:"start_time"`
+	Successful        uint64                   `json:"successful"`
+	Failed            uint64                   `json:"failed"`
+	Errors            map[ErrorCategory]uint64 `json:"errors"`
+	LastSuccess       time.Time                `json:"last_success"`
+	LastError         string                   `json:"last_error,omitempty"`
+	LastErrorTime     time.Time                `json:"last_error_time"`
+	LastDuration      time.Duration            `json:"-"`
+	LatencyBuckets    []float64                `json:"latency_buckets_seconds"`
+	LatencyCounts     []uint64                 `json:"latency_counts"` // Cumulative, one per bucket
+	LatencyCount      uint64                   `json:"latency_count"`
+	LatencySumSeconds float64                  `json:"latency_sum_seconds"`
+}
+
+// TotalErrors returns the number of errors over all categories
+func (s CollectionStats) TotalErrors() uint64 {
+	var total uint64
+	for _, count := range s.Errors {
+		total += count
+	}
+	return total
+}
+
+// AvgLatency returns the mean collection latency
+func (s CollectionStats) AvgLatency() time.Duration {
+	if s.LatencyCount == 0 {
+		return 0
+	}
+	return time.Duration(s.LatencySumSeconds / float64(s.LatencyCount) * float64(time.Second))
+}
+
+// collectionStats records collection outcomes; it is safe for concurrent use
+type collectionStats struct {
+	mutex         sync.Mutex
+	startTime     time.Time
+	successful    uint64
+	failed        uint64
+	errors        map[ErrorCategory]uint64
+	lastSuccess   time.Time
+	lastError     string
+	lastErrorTime time.Time
+	lastDuration  time.Duration
+	latencyCounts []uint64 // Per bucket plus a final +Inf bucket, not cumulative
+	latencyCount  uint64
+	latencySum    time.Duration
+}
+
+// newCollectionStats creates an empty recorder starting now
+func newCollectionStats() *collectionStats {
+	return &collectionStats{
+		startTime:     time.Now(),
+		errors:        make(map[ErrorCategory]uint64, len(ErrorCategories)),
+		latencyCounts: make([]uint64, len(collectionLatencyBuckets)+1),
+	}
+}
+
+// observe records the latency of one collection attempt
+func (s *collectionStats) observe(duration time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	bucket := len(collectionLatencyBuckets)
+	for i, bound := range collectionLatencyBuckets {
+		if duration.Seconds() <= bound {
+			bucket = i
+			break
+		}
+	}
+	s.latencyCounts[bucket]++
+	s.latencyCount++
+	s.latencySum += duration
+	s.lastDuration = duration
+}
+
+// success records a stored sample
+func (s *collectionStats) success() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.successful++
+	s.lastSuccess = time.Now()
+}
+
+// failure records an error; fatal errors also count the attempt as a failed collection
+func (s *collectionStats) failure(category ErrorCategory, err error, fatal bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.errors[category]++
+	s.lastError = err.Error()
+	s.lastErrorTime = time.Now()
+	if fatal {
+		s.failed++
+	}
+}
+
+// snapshot returns a copy of the counters
+func (s *collectionStats) snapshot() CollectionStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snapshot := CollectionStats{
+		StartTime:         s.startTime,
+		Successful:        s.successful,
+		Failed:            s.failed,
+		Errors:            make(map[ErrorCategory]uint64, len(ErrorCategories)),
+		LastSuccess:       s.lastSuccess,
+		LastError:         s.lastError,
+		LastErrorTime:     s.lastErrorTime,
+		LastDuration:      s.lastDuration,
+		LatencyBuckets:    collectionLatencyBuckets,
+		LatencyCounts:     make([]uint64, len(collectionLatencyBuckets)),
+		LatencyCount:      s.latencyCount,
+		LatencySumSeconds: s.latencySum.Seconds(),
+	}
+	for _, category := range ErrorCategories {
+		snapshot.Errors[category] = s.errors[category]
+	}
+
+	var cumulative uint64
+	for i := range collectionLatencyBuckets {
+		cumulative += s.latencyCounts[i]
+		snapshot.LatencyCounts[i] = cumulative
+	}
+	return snapshot
+}
+
+// memoryUsageMB returns the heap memory in use by the Go runtime in megabytes
+func memoryUsageMB() float64 {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	return float64(memStats.HeapAlloc) / (1024 * 1024)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSource returns fixed GPUs and counts the calls made to it
+type fakeSource struct {
+	mu          sync.Mutex
+	gpus        []GPU
+	block       chan struct{} // When set, Collect waits for it to close or for ctx to end
+	static      []GPUStaticInfo
+	staticErr   error // Returned by StaticInfo when set
+	collects    int
+	staticCalls int
+	staticUntil time.Time // Deadline of the last StaticInfo call
+}
+
+func (s *fakeSource) Name() string { return "fake" }
+
+func (s *fakeSource) Collect(ctx context.Context) (*RocmData, error) {
+	if s.block != nil {
+		select {
+		case <-s.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.collects++
+	gpus := make([]GPU, len(s.gpus))
+	copy(gpus, s.gpus)
+	return &RocmData{Timestamp: time.Now(), GPUs: gpus}, nil
+}
+
+func (s *fakeSource) StaticInfo(ctx context.Context) ([]GPUStaticInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.staticCalls++
+	s.staticUntil, _ = ctx.Deadline()
+	if s.staticErr != nil {
+		return nil, s.staticErr
+	}
+	infos := make([]GPUStaticInfo, len(s.static))
+	copy(infos, s.static)
+	return infos, nil
+}
+
+// counts returns the number of Collect and StaticInfo calls so far
+func (s *fakeSource) counts() (collects, staticCalls int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.collects, s.staticCalls
+}
+
+// fakeGPU returns a GPU with a plausible reading
+func fakeGPU(id int, uniqueID string) GPU {
+	gpu := GPU{ID: id, UniqueID: uniqueID}
+	gpu.Set(FieldTemperature, 40)
+	return gpu
+}
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDevicesChanged(t *testing.T) {
+	static := []GPUStaticInfo{
+		{ID: 0, UniqueID: "0x5b7c1e2d9a4f8036"},
+		{ID: 1, UniqueID: "Not Available"},
+	}
+	tests := []struct {
+		name string
+		gpus []GPU
+		want bool
+	}{
+		{"same IDs", []GPU{fakeGPU(0, ""), fakeGPU(1, "")}, false},
+		{"unique ID matches ignoring case", []GPU{fakeGPU(0, "0x5B7C1E2D9A4F8036"), fakeGPU(1, "0x1111")}, false},
+		{"placeholder unique IDs are not compared", []GPU{fakeGPU(0, "0x0000000000000000"), fakeGPU(1, "N/A")}, false},
+		{"different unique ID", []GPU{fakeGPU(0, "0x0123456789abcdef"), fakeGPU(1, "")}, true},
+		{"GPU removed", []GPU{fakeGPU(0, "")}, true},
+		{"GPU renumbered", []GPU{fakeGPU(0, ""), fakeGPU(2, "")}, true},
+	}
+
+	c := &Collector{staticInfo: static}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.devicesChanged(&RocmData{GPUs: tt.gpus}); got != tt.want {
+				t.Errorf("devicesChanged = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if (&Collector{}).devicesChanged(&RocmData{GPUs: []GPU{fakeGPU(0, "")}}) {
+		t.Error("devicesChanged reported a change before static info was loaded")
+	}
+}
+
+func TestCollectorDeviceChangeRefreshIsRateLimited(t *testing.T) {
+	// Every sample disagrees with the static info, as when a GPU appears that the static
+	// queries do not report
+	source := &fakeSource{
+		gpus:   []GPU{fakeGPU(0, ""), fakeGPU(1, "")},
+		static: []GPUStaticInfo{{ID: 0}},
+	}
+	c := NewCollector(CollectorConfig{Source: source, Interval: time.Millisecond, StaticInfoRefresh: time.Hour})
+	c.Start()
+	waitFor(t, "20 samples", func() bool { return c.HistoryLen() >= 20 })
+	c.Stop()
+
+	// One refresh at startup and one for the device change
+	if _, staticCalls := source.counts(); staticCalls != 2 {
+		t.Errorf("static info was queried %d times, want 2", staticCalls)
+	}
+}
+
+func TestGetStaticInfoBacksOffAfterFailure(t *testing.T) {
+	source := &fakeSource{staticErr: errors.New("rocm-smi hung"), static: []GPUStaticInfo{{ID: 0}}}
+	c := NewCollector(CollectorConfig{Source: source})
+
+	// A failed load is reported without querying again, as on every scrape of a broken box
+	for i := 0; i < 5; i++ {
+		if _, err := c.GetStaticInfo(); err == nil || !errors.Is(err, source.staticErr) {
+			t.Fatalf("call %d: err = %v, want the cached failure", i, err)
+		}
+	}
+	source.mu.Lock()
+	calls, deadline := source.staticCalls, source.staticUntil
+	source.mu.Unlock()
+	if calls != 1 {
+		t.Errorf("static info was queried %d times, want 1", calls)
+	}
+	if deadline.IsZero() || time.Until(deadline) > staticLoadTimeout {
+		t.Errorf("on-demand load deadline %v, want within %v", deadline, staticLoadTimeout)
+	}
+
+	// Once the retry gap has passed the next request loads again
+	source.mu.Lock()
+	source.staticErr = nil
+	source.mu.Unlock()
+	c.staticMutex.Lock()
+	c.staticFailed = time.Now().Add(-staticRetryGap)
+	c.staticMutex.Unlock()
+	infos, err := c.GetStaticInfo()
+	if err != nil || len(infos) != 1 {
+		t.Fatalf("after the retry gap: %v, %v", infos, err)
+	}
+	if _, err := c.GetStaticInfo(); err != nil {
+		t.Fatal(err)
+	}
+	if _, calls := source.counts(); calls != 2 {
+		t.Errorf("static info was queried %d times, want 2", calls)
+	}
+}
+
+func TestCollectorConcurrentLifecycle(t *testing.T) {
+	source := &fakeSource{gpus: []GPU{fakeGPU(0, "")}, static: []GPUStaticInfo{{ID: 0}}}
+	c := NewCollector(CollectorConfig{Source: source, Interval: time.Millisecond, StaticInfoRefresh: time.Hour})
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 4; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				switch (worker + i) % 3 {
+				case 0:
+					c.Start()
+				case 1:
+					c.Stop()
+				case 2:
+					if err := c.Reconfigure(time.Duration(1+i%5) * time.Second); err != nil {
+						t.Error(err)
+					}
+				}
+				c.Interval()
+				c.HistoryLen()
+			}
+		}(worker)
+	}
+	wg.Wait()
+	c.Stop()
+
+	// The collector still works after the churn
+	c.Start()
+	defer c.Stop()
+	before, _ := source.counts()
+	waitFor(t, "a new sample", func() bool {
+		collects, _ := source.counts()
+		return collects > before
+	})
+}
+
+func TestCollectorReconfigureDoesNotWaitForCollect(t *testing.T) {
+	source := &fakeSource{gpus: []GPU{fakeGPU(0, "")}, static: []GPUStaticInfo{{ID: 0}}, block: make(chan struct{})}
+	c := NewCollector(CollectorConfig{Source: source, Interval: time.Hour, StaticInfoRefresh: time.Hour})
+	c.Start()
+	defer c.Stop()
+
+	// The loop is stuck in its first Collect; every change must return at once and the
+	// last one wins
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, interval := range []time.Duration{time.Minute, 2 * time.Minute, MinReconfigureInterval} {
+			if err := c.Reconfigure(interval); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reconfigure blocked while a collection was running")
+	}
+
+	// Once Collect returns, the loop switches to the latest interval
+	close(source.block)
+	waitFor(t, "a sample at the new interval", func() bool { return c.HistoryLen() >= 2 })
+	if got := c.Interval(); got != MinReconfigureInterval {
+		t.Errorf("Interval = %v, want %v", got, MinReconfigureInterval)
+	}
+}
+
+func TestCollectorReconfigureMinimum(t *testing.T) {
+	c := NewCollector(CollectorConfig{Source: &fakeSource{}, Interval: 5 * time.Second})
+	for _, interval := range []time.Duration{-time.Second, 0, time.Millisecond, MinReconfigureInterval - 1} {
+		if err := c.Reconfigure(interval); err == nil {
+			t.Errorf("Reconfigure(%v) succeeded", interval)
+		}
+	}
+	if err := c.Reconfigure(MinReconfigureInterval); err != nil {
+		t.Errorf("Reconfigure(%v): %v", MinReconfigureInterval, err)
+	}
+	if got := c.Interval(); got != MinReconfigureInterval {
+		t.Errorf("Interval = %v, want %v", got, MinReconfigureInterval)
+	}
+}
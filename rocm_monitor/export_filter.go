@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// exportPageSize is the number of samples an export copies out of the history at a time
+const exportPageSize = 256
+
+// ExportFilter selects the part of the history an export covers. The zero value selects
+// everything.
+type ExportFilter struct {
+	Start  time.Time       // Zero for no lower bound; inclusive
+	End    time.Time       // Zero for no upper bound; inclusive
+	GPUs   map[int]bool    // Empty selects every GPU
+	Fields map[string]bool // GPU field names and cpu_usage; empty selects all
+}
+
+// ParseExportFilter reads the start, end, gpu and fields URL parameters. start and end accept
+// RFC 3339 times or Unix seconds; gpu and fields take comma-separated lists.
+func ParseExportFilter(params url.Values) (ExportFilter, error) {
+	var filter ExportFilter
+	var err error
+	if value := params.Get("start"); value != "" {
+		if filter.Start, err = parseQueryTime(value); err != nil {
+			return filter, fmt.Errorf("invalid start: %w", err)
+		}
+	}
+	if value := params.Get("end"); value != "" {
+		if filter.End, err = parseQueryTime(value); err != nil {
+			return filter, fmt.Errorf("invalid end: %w", err)
+		}
+	}
+	if !filter.Start.IsZero() && !filter.End.IsZero() && filter.End.Before(filter.Start) {
+		return filter, fmt.Errorf("end must not be before start")
+	}
+
+	ids, err := parseGPUList(params.Get("gpu"))
+	if err != nil {
+		return filter, err
+	}
+	if len(ids) > 0 {
+		filter.GPUs = make(map[int]bool, len(ids))
+		for _, id := range ids {
+			filter.GPUs[id] = true
+		}
+	}
+
+	if value := params.Get("fields"); value != "" {
+		filter.Fields = make(map[string]bool)
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name != queryMetricCPU && gpuFieldByName(name) == 0 {
+				return filter, fmt.Errorf("unknown field %q", name)
+			}
+			filter.Fields[name] = true
+		}
+	}
+	return filter, nil
+}
+
+// Selects reports whether the filter keeps the field with the given JSON name
+func (f ExportFilter) Selects(name string) bool {
+	return len(f.Fields) == 0 || f.Fields[name]
+}
+
+// Apply returns the part of a sample the filter keeps, and false if it keeps nothing: the
+// sample is outside the time range or holds none of the selected GPUs
+func (f ExportFilter) Apply(data *RocmData) (RocmData, bool) {
+	if (!f.Start.IsZero() && data.Timestamp.Before(f.Start)) || (!f.End.IsZero() && data.Timestamp.After(f.End)) {
+		return RocmData{}, false
+	}
+	if len(f.GPUs) == 0 && len(f.Fields) == 0 {
+		return *data, true
+	}
+
+	result := *data
+	result.GPUs = make([]GPU, 0, len(data.GPUs))
+	for _, gpu := range data.GPUs {
+		if len(f.GPUs) > 0 && !f.GPUs[gpu.ID] {
+			continue
+		}
+		for _, info := range gpuFields {
+			if !f.Selects(info.name) {
+				gpu.Invalidate(info.field)
+			}
+		}
+		result.GPUs = append(result.GPUs, gpu)
+	}
+	if len(f.GPUs) > 0 && len(result.GPUs) == 0 {
+		return RocmData{}, false
+	}
+	if !f.Selects(queryMetricCPU) {
+		result.CPUAvailable = false
+		result.CPUUsage = 0
+	}
+	return result, true
+}
+
+// eachSample calls fn with every sample the filter keeps, oldest first. The history is copied
+// a page at a time, so fn may write to a slow client without holding up collection; samples
+// overwritten while fn runs are skipped.
+func (e *Exporter) eachSample(filter ExportFilter, fn func(data *RocmData) error) error {
+	var cutoff time.Time
+	if !filter.Start.IsZero() {
+		cutoff = filter.Start.Add(-time.Nanosecond)
+	}
+	var next uint64
+	for {
+		var page []RocmData
+		page, next = e.collector.GetHistoryPage(cutoff, next, exportPageSize)
+		for i := range page {
+			if !filter.End.IsZero() && page[i].Timestamp.After(filter.End) {
+				return nil
+			}
+			if data, ok := filter.Apply(&page[i]); ok {
+				if err := fn(&data); err != nil {
+					return err
+				}
+			}
+		}
+		if len(page) < exportPageSize {
+			return nil
+		}
+	}
+}
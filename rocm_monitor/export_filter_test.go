@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEachSamplePagesByPosition(t *testing.T) {
+	// More samples share each timestamp than fit in a page
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	sample := func(i int) RocmData {
+		return RocmData{
+			Timestamp:    start.Add(time.Duration(i/300) * time.Second),
+			GPUs:         []GPU{fakeGPU(0, "")},
+			CPUUsage:     float64(i),
+			CPUAvailable: true,
+		}
+	}
+	c := &Collector{source: &fakeSource{}, history: NewHistoryRing(1000), stats: newCollectionStats()}
+	for i := 0; i < 900; i++ {
+		c.history.Push(sample(i))
+	}
+	e := NewExporter(c, nil)
+
+	tests := []struct {
+		name        string
+		filter      ExportFilter
+		first, last int
+	}{
+		{"everything", ExportFilter{}, 0, 899},
+		{"from the second timestamp", ExportFilter{Start: start.Add(time.Second)}, 300, 899},
+		{"up to the second timestamp", ExportFilter{End: start.Add(time.Second)}, 0, 599},
+	}
+	for _, tt := range tests {
+		var got []int
+		err := e.eachSample(tt.filter, func(data *RocmData) error {
+			got = append(got, int(data.CPUUsage))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(got) != tt.last-tt.first+1 || got[0] != tt.first || got[len(got)-1] != tt.last {
+			t.Errorf("%s: got %d samples from %v to %v, want %d to %d", tt.name, len(got), got[0], got[len(got)-1], tt.first, tt.last)
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i] != got[i-1]+1 {
+				t.Errorf("%s: sample %d follows %d", tt.name, got[i], got[i-1])
+				break
+			}
+		}
+	}
+
+	// Samples overwritten between pages are skipped, never repeated
+	var got []int
+	err := e.eachSample(ExportFilter{}, func(data *RocmData) error {
+		if len(got) == 0 {
+			for i := 900; i < 1500; i++ {
+				c.history.Push(sample(i))
+			}
+		}
+		got = append(got, int(data.CPUUsage))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] <= got[i-1] {
+			t.Fatalf("sample %d follows %d", got[i], got[i-1])
+		}
+	}
+	if got[len(got)-1] != 1499 || got[exportPageSize] != 500 {
+		t.Errorf("export went %v ... %d, want the first page, then from 500 to 1499", got[:exportPageSize+1], got[len(got)-1])
+	}
+}
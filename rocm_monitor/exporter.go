@@ -1,11 +1,13 @@
 package main
 
 import (
-	"bytes"
+	"bufio"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -13,79 +15,130 @@ import (
 // Exporter handles data export functionality
 type Exporter struct {
 	collector *Collector
+	tests     *ROCmTestStore // Optional; the latest diagnostics are added to the metrics
 }
 
 // NewExporter creates a new exporter instance
-func NewExporter(collector *Collector) *Exporter {
+func NewExporter(collector *Collector, tests *ROCmTestStore) *Exporter {
 	return &Exporter{
 		collector: collector,
+		tests:     tests,
 	}
 }
 
-// ExportCSV writes data history as CSV
-func (e *Exporter) ExportCSV(w io.Writer) error {
-	history := e.collector.GetHistory()
-	if len(history) == 0 {
+// csvColumn describes one metric column of the CSV export
+type csvColumn struct {
+	header string
+	field  GPUField // 0 for the CPU usage column
+	format string
+}
+
+// csvColumns lists the metric columns in output order, after Timestamp and GPU_ID
+var csvColumns = []csvColumn{
+	{"Temperature_C", FieldTemperature, "%.2f"},
+	{"Power_W", FieldPower, "%.2f"},
+	{"VRAM_Usage_GB", FieldVRAMUsage, "%.2f"},
+	{"VRAM_Total_GB", FieldVRAMTotal, "%.2f"},
+	{"GPU_Usage_%", FieldGPUUsage, "%.2f"},
+	{"SCLK_MHz", FieldSCLKFreq, "%.0f"},
+	{"MCLK_MHz", FieldMCLKFreq, "%.0f"},
+	{"CPU_Usage_%", 0, "%.2f"},
+	{"Fan_Speed_%", FieldFanSpeed, "%.2f"},
+	{"Vis_VRAM_Usage_GB", FieldVisVRAMUsage, "%.2f"},
+	{"Vis_VRAM_Total_GB", FieldVisVRAMTotal, "%.2f"},
+	{"GTT_Usage_GB", FieldGTTUsage, "%.2f"},
+	{"GTT_Total_GB", FieldGTTTotal, "%.2f"},
+	{"GPU_Mem_Usage_GB", FieldAccessibleMemUsage, "%.2f"},
+	{"GPU_Mem_Total_GB", FieldAccessibleMemTotal, "%.2f"},
+}
+
+// name returns the JSON name the column is selected by in an export filter
+func (c csvColumn) name() string {
+	if c.field == 0 {
+		return queryMetricCPU
+	}
+	return c.field.String()
+}
+
+// ExportCSV writes the selected data history as CSV, leaving out unselected columns
+func (e *Exporter) ExportCSV(w io.Writer, filter ExportFilter) error {
+	if e.collector.HistoryLen() == 0 {
 		return fmt.Errorf("no data to export")
 	}
 
 	writer := csv.NewWriter(w)
 	defer writer.Flush()
 
+	var columns []csvColumn
+	for _, column := range csvColumns {
+		if filter.Selects(column.name()) {
+			columns = append(columns, column)
+		}
+	}
+
 	// Write header
-	header := []string{
-		"Timestamp",
-		"GPU_ID",
-		"Temperature_C",
-		"Power_W",
-		"VRAM_Usage_GB",
-		"VRAM_Total_GB",
-		"GPU_Usage_%",
-		"SCLK_MHz",
-		"MCLK_MHz",
-		"CPU_Usage_%",
-		"Fan_Speed_%",
+	header := []string{"Timestamp", "GPU_ID"}
+	for _, column := range columns {
+		header = append(header, column.header)
 	}
-	
 	if err := writer.Write(header); err != nil {
 		return fmt.Errorf("failed to write CSV header: %w", err)
 	}
 
 	// Write data rows
-	for _, data := range history {
+	row := make([]string, len(header))
+	return e.eachSample(filter, func(data *RocmData) error {
 		timestamp := data.Timestamp.Format(time.RFC3339)
-		
+		cpuUsage := ""
+		if data.CPUAvailable {
+			cpuUsage = fmt.Sprintf("%.2f", data.CPUUsage)
+		}
+
 		for _, gpu := range data.GPUs {
-			row := []string{
-				timestamp,
-				fmt.Sprintf("%d", gpu.ID),
-				fmt.Sprintf("%.2f", gpu.Temperature),
-				fmt.Sprintf("%.2f", gpu.Power),
-				fmt.Sprintf("%.2f", gpu.VRAMUsage),
-				fmt.Sprintf("%.2f", gpu.VRAMTotal),
-				fmt.Sprintf("%.2f", gpu.GPUUsage),
-				fmt.Sprintf("%.0f", gpu.SCLKFreq),
-				fmt.Sprintf("%.0f", gpu.MCLKFreq),
-				fmt.Sprintf("%.2f", data.CPUUsage),
-				fmt.Sprintf("%.2f", gpu.FanSpeed),
+			// Metrics missing from the sample are written as empty cells, not zeros
+			row[0] = timestamp
+			row[1] = strconv.Itoa(gpu.ID)
+			for i, column := range columns {
+				if column.field == 0 {
+					row[i+2] = cpuUsage
+				} else {
+					row[i+2] = csvField(gpu, column.field, column.format)
+				}
 			}
-			
+
 			if err := writer.Write(row); err != nil {
 				return fmt.Errorf("failed to write CSV row: %w", err)
 			}
 		}
-	}
+		return nil
+	})
+}
 
-	return nil
+// csvField formats an available metric, or returns an empty cell when it is missing
+func csvField(gpu GPU, field GPUField, format string) string {
+	value, ok := gpu.Value(field)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(format, value)
 }
 
-// ExportJSON writes data history as JSON
-func (e *Exporter) ExportJSON(w io.Writer) error {
-	history := e.collector.GetHistory()
-	if len(history) == 0 {
+// ExportJSON writes the selected data history as one JSON document. The document is built in
+// memory; ExportNDJSON is the streaming alternative for large histories.
+func (e *Exporter) ExportJSON(w io.Writer, filter ExportFilter) error {
+	if e.collector.HistoryLen() == 0 {
 		return fmt.Errorf("no data to export")
 	}
 
+	history := []RocmData{}
+	err := e.eachSample(filter, func(data *RocmData) error {
+		history = append(history, *data)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
 	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", "  ")
 	
@@ -109,6 +162,30 @@ func (e *Exporter) ExportJSON(w io.Writer) error {
 	return nil
 }
 
+// ExportNDJSON streams the selected data history as newline-delimited JSON, one sample per
+// line, so large histories are never held in memory at once
+func (e *Exporter) ExportNDJSON(w io.Writer, filter ExportFilter) error {
+	if e.collector.HistoryLen() == 0 {
+		return fmt.Errorf("no data to export")
+	}
+
+	out := bufio.NewWriter(w)
+	encoder := json.NewEncoder(out)
+	err := e.eachSample(filter, func(data *RocmData) error {
+		if err := encoder.Encode(data); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
+	}
+	return nil
+}
+
 // ExportLatestJSON writes only the latest data point as JSON
 func (e *Exporter) ExportLatestJSON(w io.Writer) error {
 	latest, err := e.collector.GetLatest()
@@ -128,192 +205,226 @@ func (e *Exporter) ExportLatestJSON(w io.Writer) error {
 
 // ExportPrometheus writes comprehensive metrics in Prometheus format
 func (e *Exporter) ExportPrometheus(w io.Writer) error {
-	latest, err := e.collector.GetLatest()
+	metrics, err := e.PrometheusMetrics()
 	if err != nil {
-		return fmt.Errorf("failed to get latest data: %w", err)
+		return err
 	}
 
-	// Use a buffer to capture all output and filter problematic text
-	var buf bytes.Buffer
-	
-	stats := e.collector.GetStats()
-	gpuStaticInfo, _ := GetGPUStaticInfo()
-
-	// Generate timestamp for all metrics
-	timestamp := latest.Timestamp.UnixMilli()
+	if err := metrics.WriteText(w); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
 
-	// === GPU Hardware Metrics ===
-	for _, gpu := range latest.GPUs {
-		// Get GPU static info for labels
-		var productName, vendor, serialNumber, vramVendor string
-		if len(gpuStaticInfo) > int(gpu.ID) {
-			info := gpuStaticInfo[gpu.ID]
-			productName = info.ProductName
-			vendor = info.VendorName
-			serialNumber = info.SerialNumber
-			vramVendor = info.VRAMVendor
-		}
+// ExportOpenMetrics writes the same metrics as ExportPrometheus in OpenMetrics 1.0 format
+func (e *Exporter) ExportOpenMetrics(w io.Writer) error {
+	metrics, err := e.PrometheusMetrics()
+	if err != nil {
+		return err
+	}
 
-		labels := fmt.Sprintf(`gpu_id="%d",product_name="%s",vendor="%s",serial_number="%s",vram_vendor="%s"`, 
-			gpu.ID, productName, vendor, serialNumber, vramVendor)
-
-		// Temperature
-		fmt.Fprintf(&buf, "# HELP rocm_gpu_temperature_celsius GPU edge temperature in Celsius\n")
-		fmt.Fprintf(&buf, "# TYPE rocm_gpu_temperature_celsius gauge\n")
-		fmt.Fprintf(&buf, "rocm_gpu_temperature_celsius{%s} %.2f %d\n", labels, gpu.Temperature, timestamp)
-
-		// Power consumption
-		fmt.Fprintf(&buf, "# HELP rocm_gpu_power_watts GPU power consumption in watts\n")
-		fmt.Fprintf(&buf, "# TYPE rocm_gpu_power_watts gauge\n")
-		fmt.Fprintf(&buf, "rocm_gpu_power_watts{%s} %.2f %d\n", labels, gpu.Power, timestamp)
-
-		// GPU utilization
-		fmt.Fprintf(&buf, "# HELP rocm_gpu_usage_percent GPU compute utilization percentage\n")
-		fmt.Fprintf(&buf, "# TYPE rocm_gpu_usage_percent gauge\n")
-		fmt.Fprintf(&buf, "rocm_gpu_usage_percent{%s} %.2f %d\n", labels, gpu.GPUUsage, timestamp)
-
-		// VRAM usage
-		fmt.Fprintf(&buf, "# HELP rocm_gpu_vram_usage_gb VRAM usage in gigabytes\n")
-		fmt.Fprintf(&buf, "# TYPE rocm_gpu_vram_usage_gb gauge\n")
-		fmt.Fprintf(&buf, "rocm_gpu_vram_usage_gb{%s} %.3f %d\n", labels, gpu.VRAMUsage, timestamp)
-
-		// VRAM total
-		fmt.Fprintf(&buf, "# HELP rocm_gpu_vram_total_gb Total VRAM in gigabytes\n")
-		fmt.Fprintf(&buf, "# TYPE rocm_gpu_vram_total_gb gauge\n")
-		fmt.Fprintf(&buf, "rocm_gpu_vram_total_gb{%s} %.3f %d\n", labels, gpu.VRAMTotal, timestamp)
-
-		// VRAM utilization percentage
-		vramUtilPct := 0.0
-		if gpu.VRAMTotal > 0 {
-			vramUtilPct = (gpu.VRAMUsage / gpu.VRAMTotal) * 100
-		}
-		fmt.Fprintf(&buf, "# HELP rocm_gpu_vram_utilization_percent VRAM utilization percentage\n")
-		fmt.Fprintf(&buf, "# TYPE rocm_gpu_vram_utilization_percent gauge\n")
-		fmt.Fprintf(&buf, "rocm_gpu_vram_utilization_percent{%s} %.2f %d\n", labels, vramUtilPct, timestamp)
+	if err := metrics.WriteOpenMetrics(w); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
 
-		// Clock frequencies
-		fmt.Fprintf(&buf, "# HELP rocm_gpu_sclk_mhz GPU system clock frequency in MHz\n")
-		fmt.Fprintf(&buf, "# TYPE rocm_gpu_sclk_mhz gauge\n")
-		fmt.Fprintf(&buf, "rocm_gpu_sclk_mhz{%s} %.0f %d\n", labels, gpu.SCLKFreq, timestamp)
+// prometheusGPUMetric describes a per-GPU gauge; value reports false when it cannot be derived
+type prometheusGPUMetric struct {
+	name  string
+	help  string
+	value func(gpu GPU) (float64, bool)
+}
 
-		fmt.Fprintf(&buf, "# HELP rocm_gpu_mclk_mhz GPU memory clock frequency in MHz\n")
-		fmt.Fprintf(&buf, "# TYPE rocm_gpu_mclk_mhz gauge\n")
-		fmt.Fprintf(&buf, "rocm_gpu_mclk_mhz{%s} %.0f %d\n", labels, gpu.MCLKFreq, timestamp)
+// prometheusGPUMetrics lists the per-GPU gauges in output order
+var prometheusGPUMetrics = []prometheusGPUMetric{
+	{"rocm_gpu_temperature_celsius", "GPU edge temperature in Celsius", gpuFieldMetric(FieldTemperature)},
+	{"rocm_gpu_power_watts", "GPU power consumption in watts", gpuFieldMetric(FieldPower)},
+	{"rocm_gpu_usage_percent", "GPU compute utilization percentage", gpuFieldMetric(FieldGPUUsage)},
+	{"rocm_gpu_vram_usage_gb", "VRAM usage in gigabytes", gpuFieldMetric(FieldVRAMUsage)},
+	{"rocm_gpu_vram_total_gb", "Total VRAM in gigabytes", gpuFieldMetric(FieldVRAMTotal)},
+	{"rocm_gpu_vram_utilization_percent", "VRAM utilization percentage", gpuUtilizationMetric(FieldVRAMUsage, FieldVRAMTotal)},
+	{"rocm_gpu_vis_vram_usage_gb", "CPU-visible VRAM usage in gigabytes", gpuFieldMetric(FieldVisVRAMUsage)},
+	{"rocm_gpu_vis_vram_total_gb", "Total CPU-visible VRAM in gigabytes", gpuFieldMetric(FieldVisVRAMTotal)},
+	{"rocm_gpu_gtt_usage_gb", "GTT memory usage in gigabytes", gpuFieldMetric(FieldGTTUsage)},
+	{"rocm_gpu_gtt_total_gb", "Total GTT memory in gigabytes", gpuFieldMetric(FieldGTTTotal)},
+	{"rocm_gpu_accessible_memory_usage_gb", "GPU-accessible memory (VRAM + GTT) usage in gigabytes", gpuFieldMetric(FieldAccessibleMemUsage)},
+	{"rocm_gpu_accessible_memory_total_gb", "Total GPU-accessible memory (VRAM + GTT) in gigabytes", gpuFieldMetric(FieldAccessibleMemTotal)},
+	{"rocm_gpu_accessible_memory_utilization_percent", "GPU-accessible memory utilization percentage", gpuUtilizationMetric(FieldAccessibleMemUsage, FieldAccessibleMemTotal)},
+	{"rocm_gpu_sclk_mhz", "GPU system clock frequency in MHz", gpuFieldMetric(FieldSCLKFreq)},
+	{"rocm_gpu_mclk_mhz", "GPU memory clock frequency in MHz", gpuFieldMetric(FieldMCLKFreq)},
+	{"rocm_gpu_fan_speed_percent", "GPU fan speed percentage", gpuFieldMetric(FieldFanSpeed)},
+}
 
-		// Fan speed
-		fmt.Fprintf(&buf, "# HELP rocm_gpu_fan_speed_percent GPU fan speed percentage\n")
-		fmt.Fprintf(&buf, "# TYPE rocm_gpu_fan_speed_percent gauge\n")
-		fmt.Fprintf(&buf, "rocm_gpu_fan_speed_percent{%s} %.2f %d\n", labels, gpu.FanSpeed, timestamp)
+// gpuFieldMetric reads a metric straight from the sample
+func gpuFieldMetric(field GPUField) func(gpu GPU) (float64, bool) {
+	return func(gpu GPU) (float64, bool) {
+		return gpu.Value(field)
 	}
+}
 
-	// === System CPU Metrics ===
-	fmt.Fprintf(&buf, "# HELP rocm_system_cpu_usage_percent System CPU utilization percentage\n")
-	fmt.Fprintf(&buf, "# TYPE rocm_system_cpu_usage_percent gauge\n")
-	fmt.Fprintf(&buf, "rocm_system_cpu_usage_percent %.2f %d\n", latest.CPUUsage, timestamp)
+// gpuUtilizationMetric derives a usage percentage; it needs both operands
+func gpuUtilizationMetric(usage, total GPUField) func(gpu GPU) (float64, bool) {
+	return func(gpu GPU) (float64, bool) {
+		used, ok := gpu.Value(usage)
+		capacity, totalOK := gpu.Value(total)
+		if !ok || !totalOK {
+			return 0, false
+		}
+		if capacity <= 0 {
+			return 0, true
+		}
+		return used / capacity * 100, true
+	}
+}
 
-	// === System Information ===
-	fmt.Fprintf(&buf, "# HELP rocm_system_gpu_count Number of detected GPUs\n")
-	fmt.Fprintf(&buf, "# TYPE rocm_system_gpu_count gauge\n")
-	fmt.Fprintf(&buf, "rocm_system_gpu_count %d %d\n", len(latest.GPUs), timestamp)
+// PrometheusMetrics builds the metric families for the latest sample and the monitor itself.
+// Before the first sample, or once the history is cleared, only the monitor's own families
+// are reported, so a failing source still shows up in the health metrics.
+func (e *Exporter) PrometheusMetrics() (*MetricSet, error) {
+	// Only the families derived from the sample carry its time; the rest are current as of
+	// the scrape
+	metrics := NewMetricSet(time.Time{})
+	latest, err := e.collector.GetLatest()
+	hasSample := err == nil
+	if hasSample {
+		addSampleMetrics(metrics, latest, e.staticInfoByID())
+	}
 
 	// === Monitoring Health Metrics ===
-	fmt.Fprintf(&buf, "# HELP rocm_monitor_collection_errors_total Total number of collection errors\n")
-	fmt.Fprintf(&buf, "# TYPE rocm_monitor_collection_errors_total counter\n")
-	if errorCount, ok := stats["collection_errors"]; ok {
-		fmt.Fprintf(&buf, "rocm_monitor_collection_errors_total %.0f %d\n", errorCount.(float64), timestamp)
-	} else {
-		fmt.Fprintf(&buf, "rocm_monitor_collection_errors_total 0 %d\n", timestamp)
+	collection := e.collector.GetCollectionStats()
+	addCollectionMetrics(metrics, collection)
+	metrics.Family("rocm_monitor_uptime_seconds", MetricGauge, "Monitor uptime in seconds").
+		Add(math.Floor(time.Since(collection.StartTime).Seconds()))
+	metrics.Family("rocm_monitor_memory_usage_mb", MetricGauge, "Monitor memory usage in megabytes").
+		Add(memoryUsageMB())
+	metrics.Family("rocm_monitor_history_size_points", MetricGauge, "Number of historical data points stored").
+		Add(float64(e.collector.HistoryLen()))
+
+	// === Performance Thresholds ===
+	if hasSample {
+		addThresholdMetrics(metrics, latest)
+	}
+
+	// === ROCm Diagnostics ===
+	if e.tests != nil {
+		if suite := e.tests.Latest(); suite != nil {
+			addROCmTestMetrics(metrics, suite)
+		}
 	}
 
-	fmt.Fprintf(&buf, "# HELP rocm_monitor_collection_duration_ms Collection duration in milliseconds\n")
-	fmt.Fprintf(&buf, "# TYPE rocm_monitor_collection_duration_ms gauge\n")
-	if duration, ok := stats["avg_collection_time_ms"]; ok {
-		fmt.Fprintf(&buf, "rocm_monitor_collection_duration_ms %.2f %d\n", duration.(float64), timestamp)
-	} else {
-		fmt.Fprintf(&buf, "rocm_monitor_collection_duration_ms 0 %d\n", timestamp)
+	// === Build Info ===
+	metrics.Family("rocm_monitor_build_info", MetricGauge, "ROCm Monitor build information").
+		Add(1, Label{"version", "1.0.0"}, Label{"go_version", "unknown"})
+
+	return metrics, nil
+}
+
+// addCollectionMetrics adds the collector's outcome counters and latency histogram, which
+// count from the collector's start
+func addCollectionMetrics(metrics *MetricSet, collection CollectionStats) {
+	counter := func(name string, metricType MetricType, help string) *MetricFamily {
+		family := metrics.Family(name, metricType, help)
+		family.Created = collection.StartTime
+		return family
 	}
 
-	fmt.Fprintf(&buf, "# HELP rocm_monitor_data_points_total Total collected data points\n")
-	fmt.Fprintf(&buf, "# TYPE rocm_monitor_data_points_total counter\n")
-	if dataPoints, ok := stats["total_collections"]; ok {
-		fmt.Fprintf(&buf, "rocm_monitor_data_points_total %.0f %d\n", dataPoints.(float64), timestamp)
-	} else {
-		fmt.Fprintf(&buf, "rocm_monitor_data_points_total 0 %d\n", timestamp)
+	counter("rocm_monitor_collection_errors_total", MetricCounter, "Total number of collection errors").
+		Add(float64(collection.TotalErrors()))
+
+	categoryErrors := counter("rocm_monitor_collection_category_errors_total", MetricCounter, "Collection errors by category")
+	for _, category := range ErrorCategories {
+		categoryErrors.Add(float64(collection.Errors[category]), Label{"category", string(category)})
 	}
 
-	fmt.Fprintf(&buf, "# HELP rocm_monitor_uptime_seconds Monitor uptime in seconds\n")
-	fmt.Fprintf(&buf, "# TYPE rocm_monitor_uptime_seconds gauge\n")
-	if uptime, ok := stats["uptime_seconds"]; ok {
-		fmt.Fprintf(&buf, "rocm_monitor_uptime_seconds %.0f %d\n", uptime.(float64), timestamp)
-	} else {
-		fmt.Fprintf(&buf, "rocm_monitor_uptime_seconds 0 %d\n", timestamp)
+	counter("rocm_monitor_failed_collections_total", MetricCounter, "Collection attempts that produced no sample").
+		Add(float64(collection.Failed))
+
+	latency := counter("rocm_monitor_collection_latency_seconds", MetricHistogram, "Collection latency in seconds")
+	for i, bound := range collection.LatencyBuckets {
+		latency.AddSuffixed("_bucket", float64(collection.LatencyCounts[i]), Label{"le", formatMetricValue(bound)})
 	}
+	latency.AddSuffixed("_bucket", float64(collection.LatencyCount), Label{"le", "+Inf"})
+	latency.AddSuffixed("_sum", collection.LatencySumSeconds)
+	latency.AddSuffixed("_count", float64(collection.LatencyCount))
+
+	metrics.Family("rocm_monitor_collection_duration_ms", MetricGauge, "Collection duration in milliseconds").
+		Add(float64(collection.AvgLatency()) / float64(time.Millisecond))
+	counter("rocm_monitor_data_points_total", MetricCounter, "Total collected data points").
+		Add(float64(collection.Successful))
+}
 
-	fmt.Fprintf(&buf, "# HELP rocm_monitor_memory_usage_mb Monitor memory usage in megabytes\n")
-	fmt.Fprintf(&buf, "# TYPE rocm_monitor_memory_usage_mb gauge\n")
-	if memUsage, ok := stats["memory_usage_mb"]; ok {
-		fmt.Fprintf(&buf, "rocm_monitor_memory_usage_mb %.2f %d\n", memUsage.(float64), timestamp)
-	} else {
-		fmt.Fprintf(&buf, "rocm_monitor_memory_usage_mb 0 %d\n", timestamp)
+// staticInfoByID returns the cached static info keyed by device ID, so it can be matched to
+// samples regardless of their position in the slice
+func (e *Exporter) staticInfoByID() map[int]GPUStaticInfo {
+	gpuStaticInfo, _ := e.collector.GetStaticInfo()
+	staticInfoByID := make(map[int]GPUStaticInfo, len(gpuStaticInfo))
+	for _, info := range gpuStaticInfo {
+		staticInfoByID[info.ID] = info
 	}
+	return staticInfoByID
+}
 
-	fmt.Fprintf(&buf, "# HELP rocm_monitor_history_size_points Number of historical data points stored\n")
-	fmt.Fprintf(&buf, "# TYPE rocm_monitor_history_size_points gauge\n")
-	history := e.collector.GetHistory()
-	fmt.Fprintf(&buf, "rocm_monitor_history_size_points %d %d\n", len(history), timestamp)
+// sampleGauge returns a gauge family stamped with the time of the sample it describes
+func sampleGauge(metrics *MetricSet, data *RocmData, name, help string) *MetricFamily {
+	family := metrics.Family(name, MetricGauge, help)
+	family.Timestamp = data.Timestamp
+	return family
+}
 
-	// === Performance Thresholds ===
-	for _, gpu := range latest.GPUs {
-		labels := fmt.Sprintf(`gpu_id="%d"`, gpu.ID)
+// addThresholdMetrics adds the per-GPU alert gauges for one sample
+func addThresholdMetrics(metrics *MetricSet, data *RocmData) {
+	for _, gpu := range data.GPUs {
+		labels := []Label{{"gpu_id", strconv.Itoa(gpu.ID)}}
 
 		// Temperature thresholds
-		tempWarning := 0.0
-		tempCritical := 0.0
-		if gpu.Temperature > 80 {
-			tempCritical = 1.0
-		} else if gpu.Temperature > 70 {
-			tempWarning = 1.0
+		if gpu.Has(FieldTemperature) {
+			sampleGauge(metrics, data, "rocm_gpu_temperature_warning_threshold", "Temperature warning threshold exceeded").
+				Add(boolMetric(gpu.Temperature > 70 && gpu.Temperature <= 80), labels...)
+			sampleGauge(metrics, data, "rocm_gpu_temperature_critical_threshold", "Temperature critical threshold exceeded").
+				Add(boolMetric(gpu.Temperature > 80), labels...)
 		}
 
-		fmt.Fprintf(&buf, "# HELP rocm_gpu_temperature_warning_threshold Temperature warning threshold exceeded\n")
-		fmt.Fprintf(&buf, "# TYPE rocm_gpu_temperature_warning_threshold gauge\n")
-		fmt.Fprintf(&buf, "rocm_gpu_temperature_warning_threshold{%s} %.0f %d\n", labels, tempWarning, timestamp)
-
-		fmt.Fprintf(&buf, "# HELP rocm_gpu_temperature_critical_threshold Temperature critical threshold exceeded\n")
-		fmt.Fprintf(&buf, "# TYPE rocm_gpu_temperature_critical_threshold gauge\n")
-		fmt.Fprintf(&buf, "rocm_gpu_temperature_critical_threshold{%s} %.0f %d\n", labels, tempCritical, timestamp)
-
 		// VRAM threshold
-		vramUtilPct := 0.0
-		if gpu.VRAMTotal > 0 {
-			vramUtilPct = (gpu.VRAMUsage / gpu.VRAMTotal) * 100
+		if vramUtilPct, ok := gpuUtilizationMetric(FieldVRAMUsage, FieldVRAMTotal)(gpu); ok {
+			sampleGauge(metrics, data, "rocm_gpu_vram_high_utilization", "VRAM utilization above 80%").
+				Add(boolMetric(vramUtilPct > 80), labels...)
+		}
+	}
+}
+
+// addSampleMetrics adds the gauges describing one sample, stamped with its time: per-GPU
+// hardware metrics, system CPU usage and the GPU count
+func addSampleMetrics(metrics *MetricSet, data *RocmData, staticInfoByID map[int]GPUStaticInfo) {
+	// === GPU Hardware Metrics ===
+	for _, gpu := range data.GPUs {
+		// Get GPU static info for labels
+		info := staticInfoByID[gpu.ID]
+		labels := []Label{
+			{"gpu_id", strconv.Itoa(gpu.ID)},
+			{"product_name", info.ProductName},
+			{"vendor", info.VendorName},
+			{"serial_number", info.SerialNumber},
+			{"vram_vendor", info.VRAMVendor},
 		}
-		vramHigh := 0.0
-		if vramUtilPct > 80 {
-			vramHigh = 1.0
+
+		// Metrics missing from the sample are skipped rather than reported as 0
+		for _, metric := range prometheusGPUMetrics {
+			if value, ok := metric.value(gpu); ok {
+				sampleGauge(metrics, data, metric.name, metric.help).Add(value, labels...)
+			}
 		}
-		fmt.Fprintf(&buf, "# HELP rocm_gpu_vram_high_utilization VRAM utilization above 80%\n")
-		fmt.Fprintf(&buf, "# TYPE rocm_gpu_vram_high_utilization gauge\n")
-		fmt.Fprintf(&buf, "rocm_gpu_vram_high_utilization{%s} %.0f %d\n", labels, vramHigh, timestamp)
 	}
 
-	// === Build Info ===
-	fmt.Fprintf(&buf, "# HELP rocm_monitor_build_info ROCm Monitor build information\n")
-	fmt.Fprintf(&buf, "# TYPE rocm_monitor_build_info gauge\n")
-	fmt.Fprintf(&buf, "rocm_monitor_build_info{version=\"1.0.0\",go_version=\"unknown\"} 1 %d\n", timestamp)
-
-	// Clean the output by removing problematic text that breaks Prometheus parsing
-	output := buf.String()
-	output = strings.ReplaceAll(output, "(MISSING)", "")
-	output = strings.ReplaceAll(output, "\n\n", "\n") // Remove double newlines
-	
-	// Write the cleaned output
-	_, err = w.Write([]byte(output))
-	if err != nil {
-		return fmt.Errorf("failed to write metrics: %w", err)
+	// === System CPU Metrics ===
+	if data.CPUAvailable {
+		sampleGauge(metrics, data, "rocm_system_cpu_usage_percent", "System CPU utilization percentage").
+			Add(data.CPUUsage)
 	}
 
-	return nil
+	// === System Information ===
+	sampleGauge(metrics, data, "rocm_system_gpu_count", "Number of detected GPUs").
+		Add(float64(len(data.GPUs)))
 }
 
 // ExportROCmTestMetrics exports ROCm test results in Prometheus format
@@ -322,20 +433,31 @@ func (e *Exporter) ExportROCmTestMetrics(w io.Writer, testSuite *ROCmTestSuite)
 		return fmt.Errorf("no test results to export")
 	}
 
-	timestamp := testSuite.Timestamp.UnixMilli()
+	metrics := NewMetricSet(time.Time{})
+	addROCmTestMetrics(metrics, testSuite)
+	if err := metrics.WriteText(w); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
 
-	// === ROCm Test Suite Metrics ===
-	fmt.Fprintf(w, "# HELP rocm_test_suite_success Overall ROCm test suite success (1=pass, 0=fail)\n")
-	fmt.Fprintf(w, "# TYPE rocm_test_suite_success gauge\n")
-	successValue := 0.0
-	if testSuite.OverallSuccess {
-		successValue = 1.0
+// addROCmTestMetrics adds the families describing a test suite run. They carry no
+// timestamp, as Prometheus rejects samples stamped with an old run time; the run time is
+// reported as a value instead.
+func addROCmTestMetrics(metrics *MetricSet, testSuite *ROCmTestSuite) {
+	family := func(name, help string) *MetricFamily {
+		family := metrics.Family(name, MetricGauge, help)
+		family.Timestamp = time.Time{}
+		return family
 	}
-	fmt.Fprintf(w, "rocm_test_suite_success %.0f %d\n", successValue, timestamp)
 
-	fmt.Fprintf(w, "# HELP rocm_test_suite_duration_ms Total test suite execution time in milliseconds\n")
-	fmt.Fprintf(w, "# TYPE rocm_test_suite_duration_ms gauge\n")
-	fmt.Fprintf(w, "rocm_test_suite_duration_ms %d %d\n", testSuite.Duration, timestamp)
+	// === ROCm Test Suite Metrics ===
+	family("rocm_test_suite_last_run_timestamp_seconds", "Unix time the ROCm test suite last ran").
+		Add(float64(testSuite.Timestamp.UnixMilli()) / 1000)
+	family("rocm_test_suite_success", "Overall ROCm test suite success (1=pass, 0=fail)").
+		Add(boolMetric(testSuite.OverallSuccess))
+	family("rocm_test_suite_duration_ms", "Total test suite execution time in milliseconds").
+		Add(float64(testSuite.Duration))
 
 	// Count test results
 	passed := 0
@@ -352,85 +474,57 @@ func (e *Exporter) ExportROCmTestMetrics(w io.Writer, testSuite *ROCmTestSuite)
 		}
 	}
 
-	fmt.Fprintf(w, "# HELP rocm_test_suite_total_tests Total number of tests executed\n")
-	fmt.Fprintf(w, "# TYPE rocm_test_suite_total_tests gauge\n")
-	fmt.Fprintf(w, "rocm_test_suite_total_tests %d %d\n", len(testSuite.TestResults), timestamp)
-
-	fmt.Fprintf(w, "# HELP rocm_test_suite_passed_tests Number of tests that passed\n")
-	fmt.Fprintf(w, "# TYPE rocm_test_suite_passed_tests gauge\n")
-	fmt.Fprintf(w, "rocm_test_suite_passed_tests %d %d\n", passed, timestamp)
-
-	fmt.Fprintf(w, "# HELP rocm_test_suite_failed_tests Number of tests that failed\n")
-	fmt.Fprintf(w, "# TYPE rocm_test_suite_failed_tests gauge\n")
-	fmt.Fprintf(w, "rocm_test_suite_failed_tests %d %d\n", failed, timestamp)
-
-	fmt.Fprintf(w, "# HELP rocm_test_suite_warnings_tests Number of tests with warnings\n")
-	fmt.Fprintf(w, "# TYPE rocm_test_suite_warnings_tests gauge\n")
-	fmt.Fprintf(w, "rocm_test_suite_warnings_tests %d %d\n", warnings, timestamp)
+	family("rocm_test_suite_total_tests", "Total number of tests executed").Add(float64(len(testSuite.TestResults)))
+	family("rocm_test_suite_passed_tests", "Number of tests that passed").Add(float64(passed))
+	family("rocm_test_suite_failed_tests", "Number of tests that failed").Add(float64(failed))
+	family("rocm_test_suite_warnings_tests", "Number of tests with warnings").Add(float64(warnings))
 
 	// === Individual Test Metrics ===
+	success := family("rocm_test_success", "Individual test success (1=pass, 0=fail)")
+	duration := family("rocm_test_duration_ms", "Individual test execution time in milliseconds")
+	issues := family("rocm_test_issues_count", "Number of issues detected in test")
 	for _, result := range testSuite.TestResults {
-		// Sanitize test name for metric label
-		testName := strings.ReplaceAll(strings.ToLower(result.Command), " ", "_")
-		testName = strings.ReplaceAll(testName, "-", "_")
-		
-		labels := fmt.Sprintf(`test_name="%s",command="%s"`, testName, result.Command)
-
-		// Test success status
-		fmt.Fprintf(w, "# HELP rocm_test_success Individual test success (1=pass, 0=fail)\n")
-		fmt.Fprintf(w, "# TYPE rocm_test_success gauge\n")
-		successVal := 0.0
-		if result.Success {
-			successVal = 1.0
+		// Sanitize test name for metric label; the command alone is not unique
+		testName := result.Name
+		if testName == "" {
+			testName = result.Command
 		}
-		fmt.Fprintf(w, "rocm_test_success{%s} %.0f %d\n", labels, successVal, timestamp)
-
-		// Test duration
-		fmt.Fprintf(w, "# HELP rocm_test_duration_ms Individual test execution time in milliseconds\n")
-		fmt.Fprintf(w, "# TYPE rocm_test_duration_ms gauge\n")
-		fmt.Fprintf(w, "rocm_test_duration_ms{%s} %d %d\n", labels, result.Duration, timestamp)
+		testName = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(testName)), " ", "_")
+		testName = strings.ReplaceAll(testName, "-", "_")
 
-		// Test issues count
-		fmt.Fprintf(w, "# HELP rocm_test_issues_count Number of issues detected in test\n")
-		fmt.Fprintf(w, "# TYPE rocm_test_issues_count gauge\n")
-		fmt.Fprintf(w, "rocm_test_issues_count{%s} %d %d\n", labels, len(result.Issues), timestamp)
+		labels := []Label{{"test_name", testName}, {"command", result.Command}}
+		success.Add(boolMetric(result.Success), labels...)
+		duration.Add(float64(result.Duration), labels...)
+		issues.Add(float64(len(result.Issues)), labels...)
 	}
-
-	return nil
 }
 
 // ExportHistorySubset exports a time-windowed subset of history
 func (e *Exporter) ExportHistorySubset(w io.Writer, duration time.Duration, format string) error {
-	history := e.collector.GetHistory()
-	if len(history) == 0 {
+	if e.collector.HistoryLen() == 0 {
 		return fmt.Errorf("no data to export")
 	}
 
-	// Find cutoff time
-	cutoff := time.Now().Add(-duration)
-	
-	// Filter history
-	var filtered []RocmData
-	for _, data := range history {
-		if data.Timestamp.After(cutoff) {
-			filtered = append(filtered, data)
-		}
-	}
-
+	// Copy only the samples inside the window
+	filtered := e.collector.GetHistorySince(time.Now().Add(-duration))
 	if len(filtered) == 0 {
 		return fmt.Errorf("no data in the specified time range")
 	}
 
 	// Create temporary exporter with filtered data
-	tempCollector := &Collector{history: filtered}
+	window := NewHistoryRing(len(filtered))
+	for _, data := range filtered {
+		window.Push(data)
+	}
+	tempCollector := &Collector{history: window}
 	tempExporter := &Exporter{collector: tempCollector}
 
 	// Export in requested format
 	switch format {
 	case "csv":
-		return tempExporter.ExportCSV(w)
+		return tempExporter.ExportCSV(w, ExportFilter{})
 	case "json":
-		return tempExporter.ExportJSON(w)
+		return tempExporter.ExportJSON(w, ExportFilter{})
 	case "prometheus":
 		return tempExporter.ExportPrometheus(w)
 	default:
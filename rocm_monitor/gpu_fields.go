@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// GPUField identifies a per-GPU metric whose availability is tracked in a sample
+type GPUField uint32
+
+const (
+	FieldTemperature GPUField = 1 << iota
+	FieldPower
+	FieldVRAMUsage
+	FieldVRAMTotal
+	FieldVisVRAMUsage
+	FieldVisVRAMTotal
+	FieldGTTUsage
+	FieldGTTTotal
+	FieldAccessibleMemUsage
+	FieldAccessibleMemTotal
+	FieldGPUUsage
+	FieldFanSpeed
+	FieldSCLKFreq
+	FieldMCLKFreq
+)
+
+// gpuFieldInfo describes a tracked GPU metric and where its value lives
+type gpuFieldInfo struct {
+	field GPUField
+	name  string // JSON key
+	value func(g *GPU) *float64
+}
+
+// gpuFields lists the tracked metrics in JSON output order
+var gpuFields = []gpuFieldInfo{
+	{FieldTemperature, "temperature", func(g *GPU) *float64 { return &g.Temperature }},
+	{FieldPower, "power", func(g *GPU) *float64 { return &g.Power }},
+	{FieldVRAMUsage, "vram_usage", func(g *GPU) *float64 { return &g.VRAMUsage }},
+	{FieldVRAMTotal, "vram_total", func(g *GPU) *float64 { return &g.VRAMTotal }},
+	{FieldVisVRAMUsage, "vis_vram_usage", func(g *GPU) *float64 { return &g.VisVRAMUsage }},
+	{FieldVisVRAMTotal, "vis_vram_total", func(g *GPU) *float64 { return &g.VisVRAMTotal }},
+	{FieldGTTUsage, "gtt_usage", func(g *GPU) *float64 { return &g.GTTUsage }},
+	{FieldGTTTotal, "gtt_total", func(g *GPU) *float64 { return &g.GTTTotal }},
+	{FieldAccessibleMemUsage, "accessible_mem_usage", func(g *GPU) *float64 { return &g.AccessibleMemUsage }},
+	{FieldAccessibleMemTotal, "accessible_mem_total", func(g *GPU) *float64 { return &g.AccessibleMemTotal }},
+	{FieldGPUUsage, "gpu_usage", func(g *GPU) *float64 { return &g.GPUUsage }},
+	{FieldFanSpeed, "fan_speed", func(g *GPU) *float64 { return &g.FanSpeed }},
+	{FieldSCLKFreq, "sclk_freq", func(g *GPU) *float64 { return &g.SCLKFreq }},
+	{FieldMCLKFreq, "mclk_freq", func(g *GPU) *float64 { return &g.MCLKFreq }},
+}
+
+// String returns the JSON name of a single field
+func (f GPUField) String() string {
+	for _, info := range gpuFields {
+		if info.field == f {
+			return info.name
+		}
+	}
+	return fmt.Sprintf("GPUField(%d)", uint32(f))
+}
+
+// Names returns the JSON names of the fields in a set, in JSON output order
+func (f GPUField) Names() []string {
+	var names []string
+	for _, info := range gpuFields {
+		if f&info.field != 0 {
+			names = append(names, info.name)
+		}
+	}
+	return names
+}
+
+// Set stores a metric value and marks it available
+func (g *GPU) Set(field GPUField, value float64) {
+	for _, info := range gpuFields {
+		if info.field == field {
+			*info.value(g) = value
+			g.Available |= field
+			return
+		}
+	}
+}
+
+// Value returns a metric value and whether it is available
+func (g GPU) Value(field GPUField) (float64, bool) {
+	if !g.Has(field) {
+		return 0, false
+	}
+	for _, info := range gpuFields {
+		if info.field == field {
+			return *info.value(&g), true
+		}
+	}
+	return 0, false
+}
+
+// Has reports whether the metric was reported in this sample
+func (g GPU) Has(field GPUField) bool {
+	return g.Available&field != 0
+}
+
+// Invalidate marks a metric unavailable and clears its value
+func (g *GPU) Invalidate(field GPUField) {
+	for _, info := range gpuFields {
+		if info.field == field {
+			*info.value(g) = 0
+			g.Available &^= field
+			return
+		}
+	}
+}
+
+// MissingFields returns the names of the tracked metrics that are not available
+func (g GPU) MissingFields() []string {
+	var missing []string
+	for _, info := range gpuFields {
+		if !g.Has(info.field) {
+			missing = append(missing, info.name)
+		}
+	}
+	return missing
+}
+
+// MarshalJSON writes only the available metrics, so a missing value is never reported as 0
+func (g GPU) MarshalJSON() ([]byte, error) {
+	name, err := json.Marshal(g.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{"id":%d,"name":%s`, g.ID, name)
+	for _, info := range gpuFields {
+		if !g.Has(info.field) {
+			continue
+		}
+		value, err := json.Marshal(*info.value(&g))
+		if err != nil {
+			return nil, fmt.Errorf("invalid GPU %s: %w", info.name, err)
+		}
+		fmt.Fprintf(&buf, `,"%s":%s`, info.name, value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON reads a GPU, marking every metric present in the document as available
+func (g *GPU) UnmarshalJSON(b []byte) error {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+
+	*g = GPU{}
+	if raw, ok := doc["id"]; ok {
+		if err := json.Unmarshal(raw, &g.ID); err != nil {
+			return fmt.Errorf("invalid GPU id: %w", err)
+		}
+	}
+	if raw, ok := doc["name"]; ok {
+		if err := json.Unmarshal(raw, &g.Name); err != nil {
+			return fmt.Errorf("invalid GPU name: %w", err)
+		}
+	}
+	for _, info := range gpuFields {
+		raw, ok := doc[info.name]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		var value float64
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return fmt.Errorf("invalid GPU %s: %w", info.name, err)
+		}
+		g.Set(info.field, value)
+	}
+	return nil
+}
+
+// gpuFieldLimits are the plausible maxima of metrics; larger values are treated as read errors
+var gpuFieldLimits = map[GPUField]float64{
+	FieldTemperature: 150,
+	FieldPower:       1000,
+	FieldGPUUsage:    100,
+	FieldFanSpeed:    100,
+}
+
+// Sanitize drops metrics that are NaN, negative or outside their plausible range and
+// returns the names of the dropped metrics
+func (g *GPU) Sanitize() []string {
+	var dropped []string
+	for _, info := range gpuFields {
+		if !g.Has(info.field) {
+			continue
+		}
+		value := *info.value(g)
+		limit, limited := gpuFieldLimits[info.field]
+		if math.IsNaN(value) || math.IsInf(value, 0) || value < 0 || (limited && value > limit) {
+			g.Invalidate(info.field)
+			dropped = append(dropped, info.name)
+		}
+	}
+	return dropped
+}
+
+// rocmDataJSON is the wire form of RocmData with an optional CPU usage
+type rocmDataJSON struct {
+	Timestamp   time.Time `json:"timestamp"`
+	GPUs        []GPU     `json:"gpus"`
+	CPUUsage    *float64  `json:"cpu_usage,omitempty"`
+	Unavailable []string  `json:"unavailable,omitempty"`
+}
+
+// MarshalJSON omits cpu_usage when it could not be measured
+func (d RocmData) MarshalJSON() ([]byte, error) {
+	doc := rocmDataJSON{
+		Timestamp:   d.Timestamp,
+		GPUs:        d.GPUs,
+		Unavailable: d.Unavailable,
+	}
+	if d.CPUAvailable {
+		cpuUsage := d.CPUUsage
+		doc.CPUUsage = &cpuUsage
+	}
+	return json.Marshal(doc)
+}
+
+// UnmarshalJSON reads a sample, marking CPU usage available when present
+func (d *RocmData) UnmarshalJSON(b []byte) error {
+	var doc rocmDataJSON
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+
+	*d = RocmData{Timestamp: doc.Timestamp, GPUs: doc.GPUs, Unavailable: doc.Unavailable}
+	if doc.CPUUsage != nil {
+		d.CPUUsage = *doc.CPUUsage
+		d.CPUAvailable = true
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// HistoryRing is a fixed-capacity buffer of samples that overwrites the oldest sample when
+// full. Samples are expected in collection order. It is not safe for concurrent use; the
+// collector guards its ring with dataMutex.
+type HistoryRing struct {
+	samples []RocmData
+	start   int // Index of the oldest sample
+	length  int
+	pushed  uint64 // Samples pushed since creation; not reset, so sequence numbers stay unique
+}
+
+// NewHistoryRing creates an empty ring holding up to capacity samples
+func NewHistoryRing(capacity int) *HistoryRing {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &HistoryRing{samples: make([]RocmData, capacity)}
+}
+
+// Push stores a sample, replacing the oldest one when the ring is full
+func (r *HistoryRing) Push(data RocmData) {
+	if len(r.samples) == 0 {
+		return
+	}
+	r.pushed++
+	if r.length < len(r.samples) {
+		r.samples[r.index(r.length)] = data
+		r.length++
+		return
+	}
+	r.samples[r.start] = data
+	r.start = (r.start + 1) % len(r.samples)
+}
+
+// index maps a position counted from the oldest sample to a slot
+func (r *HistoryRing) index(i int) int {
+	return (r.start + i) % len(r.samples)
+}
+
+// Len returns the number of samples held
+func (r *HistoryRing) Len() int {
+	return r.length
+}
+
+// Cap returns the maximum number of samples held
+func (r *HistoryRing) Cap() int {
+	return len(r.samples)
+}
+
+// At returns the i-th sample, counted from the oldest. The pointer refers to the ring's own
+// storage and is only valid until the next Push.
+func (r *HistoryRing) At(i int) *RocmData {
+	if i < 0 || i >= r.length {
+		panic("history ring index out of range")
+	}
+	return &r.samples[r.index(i)]
+}
+
+// Oldest returns the oldest sample, or nil if the ring is empty
+func (r *HistoryRing) Oldest() *RocmData {
+	if r.length == 0 {
+		return nil
+	}
+	return r.At(0)
+}
+
+// Newest returns the most recent sample, or nil if the ring is empty
+func (r *HistoryRing) Newest() *RocmData {
+	if r.length == 0 {
+		return nil
+	}
+	return r.At(r.length - 1)
+}
+
+// Sequence returns the sequence number of the i-th sample, counted from the oldest. It
+// identifies the sample for as long as the ring holds it, while positions shift on every Push.
+func (r *HistoryRing) Sequence(i int) uint64 {
+	return r.pushed - uint64(r.length) + uint64(i)
+}
+
+// Position returns the position of the sample with sequence number seq: 0 if it has been
+// overwritten already, Len if it has not been pushed yet
+func (r *HistoryRing) Position(seq uint64) int {
+	first := r.Sequence(0)
+	if seq <= first {
+		return 0
+	}
+	if seq-first >= uint64(r.length) {
+		return r.length
+	}
+	return int(seq - first)
+}
+
+// Search returns the position of the first sample taken after cutoff, or Len if there is none
+func (r *HistoryRing) Search(cutoff time.Time) int {
+	return sort.Search(r.length, func(i int) bool {
+		return r.At(i).Timestamp.After(cutoff)
+	})
+}
+
+// Range calls fn for the samples from position from onwards, oldest first, until fn returns false
+func (r *HistoryRing) Range(from int, fn func(data *RocmData) bool) {
+	if from < 0 {
+		from = 0
+	}
+	for i := from; i < r.length; i++ {
+		if !fn(&r.samples[r.index(i)]) {
+			return
+		}
+	}
+}
+
+// AppendTo appends copies of the samples from position from onwards to dst
+func (r *HistoryRing) AppendTo(dst []RocmData, from int) []RocmData {
+	if from < 0 {
+		from = 0
+	}
+	if from >= r.length {
+		return dst
+	}
+
+	// The samples occupy at most two contiguous runs of the backing array
+	first := r.index(from)
+	end := first + r.length - from
+	if end <= len(r.samples) {
+		return append(dst, r.samples[first:end]...)
+	}
+	dst = append(dst, r.samples[first:]...)
+	return append(dst, r.samples[:end-len(r.samples)]...)
+}
+
+// HistoryView is a read-only view of a collector's history, valid only inside the callback of
+// Collector.ViewHistory. Samples returned by it are not copies and must not be modified or
+// kept after the callback returns.
+type HistoryView struct {
+	ring *HistoryRing
+}
+
+// Len returns the number of samples in the history
+func (v HistoryView) Len() int {
+	return v.ring.Len()
+}
+
+// At returns the i-th sample, counted from the oldest
+func (v HistoryView) At(i int) *RocmData {
+	return v.ring.At(i)
+}
+
+// Oldest returns the oldest sample, or nil if the history is empty
+func (v HistoryView) Oldest() *RocmData {
+	return v.ring.Oldest()
+}
+
+// Newest returns the most recent sample, or nil if the history is empty
+func (v HistoryView) Newest() *RocmData {
+	return v.ring.Newest()
+}
+
+// Range calls fn for every sample, oldest first, until fn returns false
+func (v HistoryView) Range(fn func(data *RocmData) bool) {
+	v.ring.Range(0, fn)
+}
+
+// RangeSince calls fn for every sample taken after cutoff, oldest first, until fn returns false
+func (v HistoryView) RangeSince(cutoff time.Time, fn func(data *RocmData) bool) {
+	v.ring.Range(v.ring.Search(cutoff), fn)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// historySample returns a sample taken i seconds after a fixed base time
+func historySample(i int) RocmData {
+	data := RocmData{Timestamp: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Second)}
+	data.GPUs = []GPU{fakeGPU(0, "")}
+	return data
+}
+
+func TestHistoryRingWrapsAround(t *testing.T) {
+	ring := NewHistoryRing(4)
+	for i := 0; i < 10; i++ {
+		ring.Push(historySample(i))
+	}
+	if ring.Len() != 4 || ring.Cap() != 4 {
+		t.Fatalf("Len %d, Cap %d, want 4 and 4", ring.Len(), ring.Cap())
+	}
+
+	want := []int{6, 7, 8, 9}
+	got := ring.AppendTo(nil, 0)
+	for i, sec := range want {
+		if !got[i].Timestamp.Equal(historySample(sec).Timestamp) {
+			t.Errorf("sample %d at %v, want second %d", i, got[i].Timestamp, sec)
+		}
+	}
+
+	// Search finds the first sample after the cutoff across the wrap point
+	if pos := ring.Search(historySample(7).Timestamp); pos != 2 {
+		t.Errorf("Search = %d, want 2", pos)
+	}
+	var seen []int
+	view := HistoryView{ring: ring}
+	view.RangeSince(historySample(6).Timestamp, func(data *RocmData) bool {
+		seen = append(seen, int(data.Timestamp.Sub(historySample(0).Timestamp)/time.Second))
+		return len(seen) < 2
+	})
+	if len(seen) != 2 || seen[0] != 7 || seen[1] != 8 {
+		t.Errorf("RangeSince stopped after %v, want [7 8]", seen)
+	}
+
+	// Sequence numbers count every push, so they survive the wrap
+	if seq := ring.Sequence(0); seq != 6 {
+		t.Errorf("Sequence(0) = %d, want 6", seq)
+	}
+	for seq, want := range map[uint64]int{0: 0, 6: 0, 8: 2, 9: 3, 10: 4, 20: 4} {
+		if pos := ring.Position(seq); pos != want {
+			t.Errorf("Position(%d) = %d, want %d", seq, pos, want)
+		}
+	}
+}
+
+// The benchmarks compare the ring with the slice the collector used before: append plus
+// reslice to keep maxHistory samples, and a full copy for every history read.
+
+const benchmarkHistorySize = 1000
+
+func BenchmarkHistorySliceAppend(b *testing.B) {
+	var history []RocmData
+	for i := 0; i < b.N; i++ {
+		history = append(history, historySample(i))
+		if len(history) > benchmarkHistorySize {
+			history = history[len(history)-benchmarkHistorySize:]
+		}
+	}
+}
+
+func BenchmarkHistoryRingPush(b *testing.B) {
+	ring := NewHistoryRing(benchmarkHistorySize)
+	for i := 0; i < b.N; i++ {
+		ring.Push(historySample(i))
+	}
+}
+
+// fullHistorySlice returns a full slice in the state the old collector kept it
+func fullHistorySlice() []RocmData {
+	history := make([]RocmData, 0, benchmarkHistorySize)
+	for i := 0; i < benchmarkHistorySize; i++ {
+		history = append(history, historySample(i))
+	}
+	return history
+}
+
+// fullHistoryRing returns a full ring whose oldest sample is not at the start of the array
+func fullHistoryRing() *HistoryRing {
+	ring := NewHistoryRing(benchmarkHistorySize)
+	for i := 0; i < benchmarkHistorySize+benchmarkHistorySize/3; i++ {
+		ring.Push(historySample(i))
+	}
+	return ring
+}
+
+func BenchmarkHistorySliceCopy(b *testing.B) {
+	history := fullHistorySlice()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		snapshot := make([]RocmData, len(history))
+		copy(snapshot, history)
+		benchmarkSink = len(snapshot)
+	}
+}
+
+func BenchmarkHistoryRingCopy(b *testing.B) {
+	ring := fullHistoryRing()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchmarkSink = len(ring.AppendTo(make([]RocmData, 0, ring.Len()), 0))
+	}
+}
+
+func BenchmarkHistoryViewRange(b *testing.B) {
+	c := &Collector{history: fullHistoryRing()}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.ViewHistory(func(view HistoryView) {
+			n := 0
+			view.Range(func(data *RocmData) bool {
+				n += len(data.GPUs)
+				return true
+			})
+			benchmarkSink = n
+		})
+	}
+}
+
+func BenchmarkHistorySliceWindowCopy(b *testing.B) {
+	history := fullHistorySlice()
+	cutoff := history[len(history)-60].Timestamp
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var window []RocmData
+		for _, data := range history {
+			if data.Timestamp.After(cutoff) {
+				window = append(window, data)
+			}
+		}
+		benchmarkSink = len(window)
+	}
+}
+
+func BenchmarkHistoryViewRangeSince(b *testing.B) {
+	c := &Collector{history: fullHistoryRing()}
+	var cutoff time.Time
+	c.ViewHistory(func(view HistoryView) { cutoff = view.At(view.Len() - 60).Timestamp })
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.ViewHistory(func(view HistoryView) {
+			n := 0
+			view.RangeSince(cutoff, func(data *RocmData) bool {
+				n++
+				return true
+			})
+			benchmarkSink = n
+		})
+	}
+}
+
+// benchmarkSink keeps the compiler from discarding benchmark results
+var benchmarkSink int
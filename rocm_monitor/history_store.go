@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Segment files hold a sequence of records, each framed as
+//
+//	length  uint32 little endian, payload size in bytes
+//	crc     uint32 little endian, CRC-32C of the payload
+//	payload JSON encoded RocmData
+//
+// A crash can leave a partial record at the end of the newest segment; it is cut off on open.
+// Damaged records elsewhere are skipped: reading resumes at the next offset that holds a
+// record whose length, checksum and payload are intact.
+const (
+	historySegmentExt     = ".seg"
+	historyRecordHeader   = 8
+	historyMaxRecordBytes = 16 << 20 // Larger length fields are treated as corruption
+)
+
+var historyCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// HistoryStoreConfig holds settings for the on-disk history
+type HistoryStoreConfig struct {
+	Dir          string
+	SegmentBytes int64 // Segment size that triggers rotation
+	MaxSegments  int   // Oldest segments beyond this count are deleted
+}
+
+// HistoryStore is an append-only on-disk log of samples split into rotating segments
+type HistoryStore struct {
+	mutex        sync.Mutex
+	dir          string
+	segmentBytes int64
+	maxSegments  int
+	segments     []uint64 // Sequence numbers of the segment files, oldest first
+	file         *os.File // Newest segment, open for appending
+	size         int64    // Size of the newest segment
+}
+
+// OpenHistoryStore opens or creates the store in config.Dir and repairs the newest segment
+func OpenHistoryStore(config HistoryStoreConfig) (*HistoryStore, error) {
+	if config.Dir == "" {
+		return nil, fmt.Errorf("history directory not set")
+	}
+	if config.SegmentBytes <= 0 {
+		config.SegmentBytes = 8 << 20
+	}
+	if config.MaxSegments <= 0 {
+		config.MaxSegments = 16
+	}
+
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	s := &HistoryStore{
+		dir:          config.Dir,
+		segmentBytes: config.SegmentBytes,
+		maxSegments:  config.MaxSegments,
+	}
+
+	segments, err := s.listSegments()
+	if err != nil {
+		return nil, err
+	}
+	s.segments = segments
+
+	if len(s.segments) == 0 {
+		if err := s.openSegment(1); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	// Only the newest segment can have been interrupted mid-write
+	newest := s.segments[len(s.segments)-1]
+	validSize, err := s.recoverSegment(newest)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(s.segmentPath(newest), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history segment: %w", err)
+	}
+	s.file = file
+	s.size = validSize
+	return s, nil
+}
+
+// segmentPath returns the file name of a segment
+func (s *HistoryStore) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, historySegmentExt))
+}
+
+// listSegments returns the sequence numbers of the segment files in ascending order
+func (s *HistoryStore) listSegments() ([]uint64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history directory: %w", err)
+	}
+
+	var segments []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, historySegmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, historySegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, seq)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+// recoverSegment truncates a segment after its last intact record and returns the new size.
+// Damaged records before it are left for readers to skip.
+func (s *HistoryStore) recoverSegment(seq uint64) (int64, error) {
+	path := s.segmentPath(seq)
+	_, validSize, readErr := readHistorySegment(path)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat history segment: %w", err)
+	}
+	if info.Size() == validSize {
+		if readErr != nil {
+			log.Printf("History segment %s has damaged records, skipping them: %v", filepath.Base(path), readErr)
+		}
+		return validSize, nil
+	}
+
+	log.Printf("History segment %s damaged (%v), truncating from %d to %d bytes",
+		filepath.Base(path), readErr, info.Size(), validSize)
+	if err := os.Truncate(path, validSize); err != nil {
+		return 0, fmt.Errorf("failed to truncate history segment: %w", err)
+	}
+	return validSize, nil
+}
+
+// openSegment creates a new segment and makes it the append target
+func (s *HistoryStore) openSegment(seq uint64) error {
+	file, err := os.OpenFile(s.segmentPath(seq), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create history segment: %w", err)
+	}
+	s.file = file
+	s.size = 0
+	s.segments = append(s.segments, seq)
+	return nil
+}
+
+// rotate closes the current segment, starts the next one and drops segments over the limit
+func (s *HistoryStore) rotate() error {
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync history segment: %w", err)
+	}
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("failed to close history segment: %w", err)
+	}
+
+	if err := s.openSegment(s.segments[len(s.segments)-1] + 1); err != nil {
+		return err
+	}
+
+	for len(s.segments) > s.maxSegments {
+		if err := os.Remove(s.segmentPath(s.segments[0])); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove old history segment: %w", err)
+		}
+		s.segments = s.segments[1:]
+	}
+	return nil
+}
+
+// Append writes one sample to the newest segment, rotating it when it is full
+func (s *HistoryStore) Append(data *RocmData) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode sample: %w", err)
+	}
+
+	record := make([]byte, historyRecordHeader+len(payload))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.Checksum(payload, historyCRCTable))
+	copy(record[historyRecordHeader:], payload)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("history store is closed")
+	}
+	if s.size > 0 && s.size+int64(len(record)) > s.segmentBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	// A single write keeps a record contiguous; drop a short write so later records stay readable
+	if _, err := s.file.Write(record); err != nil {
+		s.file.Truncate(s.size)
+		return fmt.Errorf("failed to write history record: %w", err)
+	}
+	s.size += int64(len(record))
+	return nil
+}
+
+// Scan calls fn for every stored sample, oldest first. Damaged records are skipped.
+func (s *HistoryStore) Scan(fn func(data *RocmData)) error {
+	s.mutex.Lock()
+	segments := append([]uint64(nil), s.segments...)
+	s.mutex.Unlock()
+
+	for _, seq := range segments {
+		path := s.segmentPath(seq)
+		records, _, err := readHistorySegment(path)
+		var pathErr *os.PathError
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			// Removed by rotation since the list was taken
+		case errors.As(err, &pathErr):
+			return fmt.Errorf("failed to read history segment: %w", err)
+		case err != nil:
+			log.Printf("History segment %s damaged, using %d intact records: %v", filepath.Base(path), len(records), err)
+		}
+		for i := range records {
+			fn(&records[i])
+		}
+	}
+	return nil
+}
+
+// Close flushes and closes the newest segment
+func (s *HistoryStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	syncErr := s.file.Sync()
+	closeErr := s.file.Close()
+	s.file = nil
+	if syncErr != nil {
+		return fmt.Errorf("failed to sync history segment: %w", syncErr)
+	}
+	return closeErr
+}
+
+// readHistorySegment decodes the records of a segment file, skipping damaged ones. It returns
+// the intact records, the offset just past the last of them and a description of the damage,
+// if any.
+func readHistorySegment(path string) ([]RocmData, int64, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var records []RocmData
+	var validSize int64
+	var damage []string
+	for offset := 0; offset < len(content); {
+		data, size, err := decodeHistoryRecord(content[offset:])
+		if err == nil {
+			records = append(records, data)
+			offset += size
+			validSize = int64(offset)
+			continue
+		}
+
+		// Resync on the next offset that frames an intact record
+		next := offset + 1
+		for next < len(content) {
+			if _, _, err := decodeHistoryRecord(content[next:]); err == nil {
+				break
+			}
+			next++
+		}
+		damage = append(damage, fmt.Sprintf("%v at offset %d, skipped %d bytes", err, offset, next-offset))
+		offset = next
+	}
+
+	if len(damage) > 0 {
+		return records, validSize, errors.New(strings.Join(damage, "; "))
+	}
+	return records, validSize, nil
+}
+
+// decodeHistoryRecord decodes the record at the start of buf and returns it with its size
+func decodeHistoryRecord(buf []byte) (RocmData, int, error) {
+	var data RocmData
+	if len(buf) < historyRecordHeader {
+		return data, 0, fmt.Errorf("truncated record header")
+	}
+
+	length := binary.LittleEndian.Uint32(buf[0:4])
+	checksum := binary.LittleEndian.Uint32(buf[4:8])
+	if length == 0 || length > historyMaxRecordBytes {
+		return data, 0, fmt.Errorf("invalid record length %d", length)
+	}
+	size := historyRecordHeader + int(length)
+	if len(buf) < size {
+		return data, 0, fmt.Errorf("truncated record")
+	}
+
+	payload := buf[historyRecordHeader:size]
+	if crc32.Checksum(payload, historyCRCTable) != checksum {
+		return data, 0, fmt.Errorf("checksum mismatch")
+	}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return data, 0, fmt.Errorf("invalid record: %w", err)
+	}
+	return data, size, nil
+}
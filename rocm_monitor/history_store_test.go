@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+)
+
+// historyRecordOffsets returns the offset of every record in an undamaged segment
+func historyRecordOffsets(t *testing.T, content []byte) []int {
+	t.Helper()
+	var offsets []int
+	for offset := 0; offset < len(content); {
+		offsets = append(offsets, offset)
+		offset += historyRecordHeader + int(binary.LittleEndian.Uint32(content[offset:]))
+	}
+	return offsets
+}
+
+func TestHistoryStoreSkipsDamagedRecords(t *testing.T) {
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		damage  func(content []byte, offsets []int) []byte
+		wantSec []int // Seconds after base of the samples read back
+	}{
+		{
+			name: "flipped payload byte",
+			damage: func(content []byte, offsets []int) []byte {
+				content[offsets[1]+historyRecordHeader+3] ^= 0xff
+				return content
+			},
+			wantSec: []int{0, 2, 3, 4},
+		},
+		{
+			name: "corrupt length field",
+			damage: func(content []byte, offsets []int) []byte {
+				binary.LittleEndian.PutUint32(content[offsets[2]:], 1<<30)
+				return content
+			},
+			wantSec: []int{0, 1, 3, 4},
+		},
+		{
+			name: "garbage between records",
+			damage: func(content []byte, offsets []int) []byte {
+				garbage := []byte("\x10\x00\x00\x00not a record at all")
+				damaged := append([]byte(nil), content[:offsets[3]]...)
+				damaged = append(damaged, garbage...)
+				return append(damaged, content[offsets[3]:]...)
+			},
+			wantSec: []int{0, 1, 2, 3, 4},
+		},
+		{
+			name: "partial record at the end",
+			damage: func(content []byte, offsets []int) []byte {
+				return append(content, content[offsets[0]:offsets[0]+20]...)
+			},
+			wantSec: []int{0, 1, 2, 3, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := OpenHistoryStore(HistoryStoreConfig{Dir: dir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 5; i++ {
+				data := &RocmData{Timestamp: base.Add(time.Duration(i) * time.Second), GPUs: []GPU{fakeGPU(0, "")}}
+				if err := store.Append(data); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			path := store.segmentPath(1)
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, tt.damage(content, historyRecordOffsets(t, content)), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			// Reopen, then append one more sample behind the damage
+			store, err = OpenHistoryStore(HistoryStoreConfig{Dir: dir})
+			if err != nil {
+				t.Fatalf("OpenHistoryStore: %v", err)
+			}
+			defer store.Close()
+			if err := store.Append(&RocmData{Timestamp: base.Add(5 * time.Second)}); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []int
+			err = store.Scan(func(data *RocmData) {
+				got = append(got, int(data.Timestamp.Sub(base)/time.Second))
+			})
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			want := append(append([]int(nil), tt.wantSec...), 5)
+			if len(got) != len(want) {
+				t.Fatalf("read samples %v, want %v", got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("read samples %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// InfluxDB line protocol output. Each sample becomes one line per measurement and GPU, tagged
+// with gpu_id and product_name, plus a rocm_system line; field keys are the JSON names of the
+// GPU fields and timestamps are in nanoseconds.
+
+// influxMeasurement groups the GPU fields written on one line
+type influxMeasurement struct {
+	name   string
+	fields []GPUField
+}
+
+// influxMeasurements lists the per-GPU measurements in output order
+var influxMeasurements = []influxMeasurement{
+	{"rocm_gpu_thermal", []GPUField{FieldTemperature, FieldFanSpeed}},
+	{"rocm_gpu_power", []GPUField{FieldPower}},
+	{"rocm_gpu_utilization", []GPUField{FieldGPUUsage}},
+	{"rocm_gpu_clock", []GPUField{FieldSCLKFreq, FieldMCLKFreq}},
+	{"rocm_gpu_memory", []GPUField{
+		FieldVRAMUsage, FieldVRAMTotal,
+		FieldVisVRAMUsage, FieldVisVRAMTotal,
+		FieldGTTUsage, FieldGTTTotal,
+		FieldAccessibleMemUsage, FieldAccessibleMemTotal,
+	}},
+}
+
+// influxTagEscaper escapes tag values. Backslashes are doubled, as a trailing one would
+// otherwise escape the space ending the tag set; line protocol has no escape for line feeds.
+var influxTagEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `, "\n", "")
+
+// ExportInflux writes the selected data history as InfluxDB line protocol
+func (e *Exporter) ExportInflux(w io.Writer, filter ExportFilter) error {
+	if e.collector.HistoryLen() == 0 {
+		return fmt.Errorf("no data to export")
+	}
+
+	staticInfoByID := e.staticInfoByID()
+	out := bufio.NewWriter(w)
+	var line []byte
+	err := e.eachSample(filter, func(data *RocmData) error {
+		line = appendInfluxLines(line[:0], data, staticInfoByID)
+		if _, err := out.Write(line); err != nil {
+			return fmt.Errorf("failed to write line protocol: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write line protocol: %w", err)
+	}
+	return nil
+}
+
+// appendInfluxLines appends the lines of one sample. Missing metrics are left out, as are
+// lines without any field.
+func appendInfluxLines(b []byte, data *RocmData, staticInfoByID map[int]GPUStaticInfo) []byte {
+	timestamp := strconv.FormatInt(data.Timestamp.UnixNano(), 10)
+
+	for _, gpu := range data.GPUs {
+		tags := ",gpu_id=" + strconv.Itoa(gpu.ID)
+		if name := influxTagEscaper.Replace(staticInfoByID[gpu.ID].ProductName); name != "" {
+			tags += ",product_name=" + name
+		}
+
+		for _, measurement := range influxMeasurements {
+			fields := 0
+			for _, field := range measurement.fields {
+				value, ok := gpu.Value(field)
+				if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+					continue
+				}
+				if fields == 0 {
+					b = append(b, measurement.name...)
+					b = append(b, tags...)
+					b = append(b, ' ')
+				} else {
+					b = append(b, ',')
+				}
+				b = append(b, field.String()...)
+				b = append(b, '=')
+				b = strconv.AppendFloat(b, value, 'g', -1, 64)
+				fields++
+			}
+			if fields > 0 {
+				b = append(b, ' ')
+				b = append(b, timestamp...)
+				b = append(b, '\n')
+			}
+		}
+	}
+
+	b = append(b, "rocm_system gpu_count="...)
+	b = strconv.AppendInt(b, int64(len(data.GPUs)), 10)
+	b = append(b, 'i')
+	if data.CPUAvailable {
+		b = append(b, ",cpu_usage="...)
+		b = strconv.AppendFloat(b, data.CPUUsage, 'g', -1, 64)
+	}
+	b = append(b, ' ')
+	b = append(b, timestamp...)
+	return append(b, '\n')
+}
+
+// InfluxConfig holds settings for the InfluxDB pusher
+type InfluxConfig struct {
+	URL    string // Server URL, such as http://localhost:8086
+	Org    string
+	Bucket string
+	Token  string // API token; empty sends no Authorization header
+	PushConfig
+}
+
+// influxTarget encodes and sends InfluxDB v2 write requests
+type influxTarget struct {
+	writeURL string
+	token    string
+	exporter *Exporter
+	client   *http.Client
+}
+
+// NewInfluxWriter creates a pusher that writes every sample to an InfluxDB v2 bucket
+func NewInfluxWriter(exporter *Exporter, config InfluxConfig) (*Pusher, error) {
+	endpoint, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid InfluxDB URL: %w", err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("invalid InfluxDB URL %q: scheme must be http or https", endpoint.Redacted())
+	}
+	if config.Org == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("InfluxDB org and bucket must be set")
+	}
+
+	endpoint = endpoint.JoinPath("api", "v2", "write")
+	endpoint.RawQuery = url.Values{
+		"org":       {config.Org},
+		"bucket":    {config.Bucket},
+		"precision": {"ns"},
+	}.Encode()
+
+	target := &influxTarget{
+		writeURL: endpoint.String(),
+		token:    config.Token,
+		exporter: exporter,
+		client:   &http.Client{Timeout: pushTimeout},
+	}
+	return newPusher("InfluxDB push", exporter.collector, target, config.PushConfig)
+}
+
+// Encode builds a gzip-compressed batch of lines
+func (t *influxTarget) Encode(samples []RocmData) ([]byte, error) {
+	staticInfoByID := t.exporter.staticInfoByID()
+	var lines []byte
+	for i := range samples {
+		lines = appendInfluxLines(lines, &samples[i], staticInfoByID)
+	}
+	return gzipBytes(lines)
+}
+
+// Send POSTs one batch to the write endpoint
+func (t *influxTarget) Send(ctx context.Context, payload []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.writeURL, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("Content-Encoding", "gzip")
+	if t.token != "" {
+		req.Header.Set("Authorization", "Token "+t.token)
+	}
+	return sendPushRequest(t.client, req)
+}
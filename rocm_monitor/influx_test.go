@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendInfluxLines(t *testing.T) {
+	timestamp := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ts := " 1772366400000000000\n"
+
+	busy := GPU{ID: 0}
+	busy.Set(FieldTemperature, 45.5)
+	busy.Set(FieldPower, 120)
+	busy.Set(FieldSCLKFreq, 2100)
+	busy.Set(FieldVRAMUsage, 1<<30)
+	busy.Set(FieldVRAMTotal, 16<<30)
+
+	tests := []struct {
+		name       string
+		data       RocmData
+		staticInfo map[int]GPUStaticInfo
+		want       string
+	}{
+		{
+			name:       "escaped product name",
+			data:       RocmData{Timestamp: timestamp, GPUs: []GPU{busy}, CPUUsage: 12.5, CPUAvailable: true},
+			staticInfo: map[int]GPUStaticInfo{0: {ID: 0, ProductName: "Radeon, Pro=W7900 \\"}},
+			want: `rocm_gpu_thermal,gpu_id=0,product_name=Radeon\,\ Pro\=W7900\ \\ temperature=45.5` + ts +
+				`rocm_gpu_power,gpu_id=0,product_name=Radeon\,\ Pro\=W7900\ \\ power=120` + ts +
+				`rocm_gpu_clock,gpu_id=0,product_name=Radeon\,\ Pro\=W7900\ \\ sclk_freq=2100` + ts +
+				`rocm_gpu_memory,gpu_id=0,product_name=Radeon\,\ Pro\=W7900\ \\ vram_usage=1.073741824e+09,vram_total=1.7179869184e+10` + ts +
+				`rocm_system gpu_count=1i,cpu_usage=12.5` + ts,
+		},
+		{
+			name: "GPU without fields and no CPU usage",
+			data: RocmData{Timestamp: timestamp, GPUs: []GPU{{ID: 1}}},
+			want: `rocm_system gpu_count=1i` + ts,
+		},
+		{
+			name: "no static info",
+			data: RocmData{Timestamp: timestamp, GPUs: []GPU{{ID: 1}, fakeGPU(2, "")}},
+			want: `rocm_gpu_thermal,gpu_id=2 temperature=40` + ts +
+				`rocm_system gpu_count=2i` + ts,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(appendInfluxLines([]byte("previous\n"), &tt.data, tt.staticInfo))
+			if want := "previous\n" + tt.want; got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestNewInfluxWriter(t *testing.T) {
+	exporter := NewExporter(&Collector{source: &fakeSource{}, history: NewHistoryRing(1), stats: newCollectionStats()}, nil)
+
+	pusher, err := NewInfluxWriter(exporter, InfluxConfig{URL: "https://influx.example:8086/prefix/", Org: "lab & co", Bucket: "gpus", Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := pusher.target.(*influxTarget)
+	if want := "https://influx.example:8086/prefix/api/v2/write?bucket=gpus&org=lab+%26+co&precision=ns"; target.writeURL != want {
+		t.Errorf("write URL %q, want %q", target.writeURL, want)
+	}
+	if target.token != "secret" {
+		t.Errorf("token %q, want secret", target.token)
+	}
+
+	for _, config := range []InfluxConfig{
+		{URL: "ftp://influx.example", Org: "lab", Bucket: "gpus"},
+		{URL: "influx.example:8086", Org: "lab", Bucket: "gpus"},
+		{URL: "http://influx.example", Bucket: "gpus"},
+		{URL: "http://influx.example", Org: "lab"},
+	} {
+		if _, err := NewInfluxWriter(exporter, config); err == nil {
+			t.Errorf("NewInfluxWriter(%+v) succeeded, want an error", config)
+		} else if !strings.Contains(err.Error(), "InfluxDB") {
+			t.Errorf("NewInfluxWriter(%+v) error %q does not name InfluxDB", config, err)
+		}
+	}
+}
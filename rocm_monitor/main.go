@@ -1,9 +1,11 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -17,6 +19,7 @@ import (
 var (
 	collector *Collector
 	exporter  *Exporter
+	rocmTests *ROCmTestStore
 )
 
 // Config holds application configuration
@@ -24,35 +27,162 @@ type Config struct {
 	Port          int
 	Interval      time.Duration
 	MaxHistory    int
+	StaticRefresh time.Duration
 	AllowedOrigin string
 	EnableMetrics bool
+	Source        string
+	RocmSMIPath   string
+	AmdSMIPath    string
+	SysfsRoot     string
+	QueryTimeout  time.Duration
+	HistoryDir    string
+	SegmentMB     int
+	MaxSegments   int
+	Retention1m   time.Duration
+	Retention15m  time.Duration
+	TestInterval  time.Duration
+
+	RemoteWriteURL       string
+	RemoteWriteInterval  time.Duration
+	RemoteWriteQueueDir  string
+	RemoteWriteQueueSize int
+
+	InfluxURL       string
+	InfluxOrg       string
+	InfluxBucket    string
+	InfluxToken     string
+	InfluxInterval  time.Duration
+	InfluxQueueDir  string
+	InfluxQueueSize int
+
+	OTLPEndpoint  string
+	OTLPHeaders   string
+	OTLPInterval  time.Duration
+	OTLPQueueDir  string
+	OTLPQueueSize int
 }
 
 func main() {
 	// Parse command line flags
 	config := parseFlags()
 
+	// Select the GPU data source
+	source, err := NewSource(config.Source, SourceConfig{
+		RocmSMIPath:  config.RocmSMIPath,
+		AmdSMIPath:   config.AmdSMIPath,
+		SysfsRoot:    config.SysfsRoot,
+		QueryTimeout: config.QueryTimeout,
+	})
+	if err != nil {
+		log.Fatalf("Invalid data source: %v", err)
+	}
+
+	// Open the on-disk history if requested
+	var store *HistoryStore
+	if config.HistoryDir != "" {
+		store, err = OpenHistoryStore(HistoryStoreConfig{
+			Dir:          config.HistoryDir,
+			SegmentBytes: int64(config.SegmentMB) << 20,
+			MaxSegments:  config.MaxSegments,
+		})
+		if err != nil {
+			log.Fatalf("Failed to open history store: %v", err)
+		}
+	}
+
 	// Initialize collector with error handling
 	collector = NewCollector(CollectorConfig{
-		MaxHistory: config.MaxHistory,
-		Interval:   config.Interval,
+		MaxHistory:        config.MaxHistory,
+		Interval:          config.Interval,
+		Source:            source,
+		StaticInfoRefresh: config.StaticRefresh,
+		Store:             store,
+		Tiers: []TierConfig{
+			{Name: "1m", Resolution: time.Minute, Retention: config.Retention1m},
+			{Name: "15m", Resolution: 15 * time.Minute, Retention: config.Retention15m},
+		},
 		ErrorCallback: func(err error) {
 			log.Printf("Collector error: %v", err)
 		},
 	})
 
+	// Keep ROCm diagnostics results for /metrics, running them on a schedule if requested
+	rocmTests = NewROCmTestStore()
+	if config.TestInterval > 0 {
+		rocmTests.Schedule(config.TestInterval)
+		log.Printf("🧪 Running ROCm diagnostics every %v", config.TestInterval)
+	}
+
 	// Initialize exporter
-	exporter = NewExporter(collector)
+	exporter = NewExporter(collector, rocmTests)
+
+	// Push samples to Prometheus remote_write, InfluxDB and OTLP receivers if requested
+	var pushers []*Pusher
+	if config.RemoteWriteURL != "" {
+		remoteWriter, err := NewRemoteWriter(exporter, RemoteWriteConfig{
+			URL: config.RemoteWriteURL,
+			PushConfig: PushConfig{
+				FlushInterval: config.RemoteWriteInterval,
+				QueueDir:      config.RemoteWriteQueueDir,
+				MaxQueued:     config.RemoteWriteQueueSize,
+			},
+		})
+		if err != nil {
+			log.Fatalf("Failed to set up remote write: %v", err)
+		}
+		pushers = append(pushers, remoteWriter)
+	}
+	if config.InfluxURL != "" {
+		influxWriter, err := NewInfluxWriter(exporter, InfluxConfig{
+			URL:    config.InfluxURL,
+			Org:    config.InfluxOrg,
+			Bucket: config.InfluxBucket,
+			Token:  config.InfluxToken,
+			PushConfig: PushConfig{
+				FlushInterval: config.InfluxInterval,
+				QueueDir:      config.InfluxQueueDir,
+				MaxQueued:     config.InfluxQueueSize,
+			},
+		})
+		if err != nil {
+			log.Fatalf("Failed to set up InfluxDB push: %v", err)
+		}
+		pushers = append(pushers, influxWriter)
+	}
+	if config.OTLPEndpoint != "" {
+		headers, err := ParseOTLPHeaders(config.OTLPHeaders)
+		if err != nil {
+			log.Fatalf("Failed to set up OTLP export: %v", err)
+		}
+		otlpWriter, err := NewOTLPWriter(exporter, OTLPConfig{
+			Endpoint: config.OTLPEndpoint,
+			Headers:  headers,
+			PushConfig: PushConfig{
+				FlushInterval: config.OTLPInterval,
+				QueueDir:      config.OTLPQueueDir,
+				MaxQueued:     config.OTLPQueueSize,
+			},
+		})
+		if err != nil {
+			log.Fatalf("Failed to set up OTLP export: %v", err)
+		}
+		pushers = append(pushers, otlpWriter)
+	}
 
 	// Start data collection
 	collector.Start()
-	log.Printf("🚀 Started ROCm monitoring with interval: %v", config.Interval)
+	log.Printf("🚀 Started ROCm monitoring with interval: %v (source: %s)", config.Interval, source.Name())
+
+	for _, pusher := range pushers {
+		pusher.Start()
+		log.Printf("📤 %s enabled, sending every %v", pusher.name, pusher.config.FlushInterval)
+	}
 
 	// Setup HTTP routes
 	setupRoutes(config)
 
 	// Setup graceful shutdown
-	setupGracefulShutdown()
+	setupGracefulShutdown(store, pushers)
 
 	// Start HTTP server
 	addr := fmt.Sprintf(":%d", config.Port)
@@ -69,10 +199,46 @@ func parseFlags() Config {
 	flag.IntVar(&config.Port, "port", 8080, "HTTP server port")
 	flag.DurationVar(&config.Interval, "interval", 5*time.Second, "Collection interval")
 	flag.IntVar(&config.MaxHistory, "history", 1000, "Maximum history size")
+	flag.DurationVar(&config.StaticRefresh, "static-refresh", 10*time.Minute, "Refresh interval for cached static GPU info")
 	flag.StringVar(&config.AllowedOrigin, "cors", "*", "CORS allowed origin")
 	flag.BoolVar(&config.EnableMetrics, "metrics", false, "Enable Prometheus metrics endpoint")
+	flag.StringVar(&config.Source, "source", "auto", "GPU data source ("+strings.Join(SourceNames(), ", ")+")")
+	flag.StringVar(&config.RocmSMIPath, "rocm-smi-path", "rocm-smi", "Path to the rocm-smi binary")
+	flag.StringVar(&config.AmdSMIPath, "amd-smi-path", "amd-smi", "Path to the amd-smi binary")
+	flag.StringVar(&config.SysfsRoot, "sysfs-root", "/sys", "Root of the sysfs tree used by the sysfs source")
+	flag.DurationVar(&config.QueryTimeout, "query-timeout", 3*time.Second, "Timeout for each rocm-smi sub-query")
+	flag.StringVar(&config.HistoryDir, "history-dir", "", "Directory for persistent history (disabled if empty)")
+	flag.IntVar(&config.SegmentMB, "history-segment-mb", 8, "Size in MB at which history segments are rotated")
+	flag.IntVar(&config.MaxSegments, "history-segments", 16, "Number of history segments kept on disk")
+	flag.DurationVar(&config.Retention1m, "rollup-1m-retention", 48*time.Hour, "Retention of 1-minute history rollups")
+	flag.DurationVar(&config.Retention15m, "rollup-15m-retention", 14*24*time.Hour, "Retention of 15-minute history rollups")
+	flag.DurationVar(&config.TestInterval, "rocm-test-interval", 0, "Run ROCm diagnostics periodically (disabled if 0)")
+	flag.StringVar(&config.RemoteWriteURL, "remote-write-url", "", "Prometheus remote_write URL to push samples to (disabled if empty)")
+	flag.DurationVar(&config.RemoteWriteInterval, "remote-write-interval", 15*time.Second, "Longest time samples wait before they are pushed")
+	flag.StringVar(&config.RemoteWriteQueueDir, "remote-write-queue-dir", "", "Directory that keeps unsent remote_write requests across restarts (memory if empty)")
+	flag.IntVar(&config.RemoteWriteQueueSize, "remote-write-queue-size", 1000, "Maximum unsent remote_write requests kept during outages")
+	flag.StringVar(&config.InfluxURL, "influx-url", "", "InfluxDB v2 server URL to push samples to (disabled if empty)")
+	flag.StringVar(&config.InfluxOrg, "influx-org", "", "InfluxDB organization")
+	flag.StringVar(&config.InfluxBucket, "influx-bucket", "", "InfluxDB bucket")
+	flag.StringVar(&config.InfluxToken, "influx-token", "", "InfluxDB API token (default $INFLUX_TOKEN)")
+	flag.DurationVar(&config.InfluxInterval, "influx-interval", 15*time.Second, "Longest time samples wait before they are pushed to InfluxDB")
+	flag.StringVar(&config.InfluxQueueDir, "influx-queue-dir", "", "Directory that keeps unsent InfluxDB writes across restarts (memory if empty)")
+	flag.IntVar(&config.InfluxQueueSize, "influx-queue-size", 1000, "Maximum unsent InfluxDB writes kept during outages")
+	flag.StringVar(&config.OTLPEndpoint, "otlp-endpoint", "", "OTLP/HTTP endpoint to push metrics to, such as http://localhost:4318 (disabled if empty)")
+	flag.StringVar(&config.OTLPHeaders, "otlp-headers", "", "OTLP request headers as key=value pairs separated by commas (default $OTEL_EXPORTER_OTLP_HEADERS)")
+	flag.DurationVar(&config.OTLPInterval, "otlp-interval", 15*time.Second, "Longest time samples wait before they are pushed via OTLP")
+	flag.StringVar(&config.OTLPQueueDir, "otlp-queue-dir", "", "Directory that keeps unsent OTLP requests across restarts (memory if empty)")
+	flag.IntVar(&config.OTLPQueueSize, "otlp-queue-size", 1000, "Maximum unsent OTLP requests kept during outages")
 	
 	flag.Parse()
+
+	// Keep credentials out of the process list when they come from the environment
+	if config.InfluxToken == "" {
+		config.InfluxToken = os.Getenv("INFLUX_TOKEN")
+	}
+	if config.OTLPHeaders == "" {
+		config.OTLPHeaders = os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")
+	}
 	
 	return config
 }
@@ -81,9 +247,15 @@ func setupRoutes(config Config) {
 	// API routes
 	http.HandleFunc("/api/stats", withCORS(statsHandler, config.AllowedOrigin))
 	http.HandleFunc("/api/latest", withCORS(latestHandler, config.AllowedOrigin))
+	http.HandleFunc("/api/query", withCORS(queryHandler, config.AllowedOrigin))
+	http.HandleFunc("/api/stream", withCORS(streamHandler, config.AllowedOrigin))
+	http.HandleFunc("/api/ws", websocketHandler(config.AllowedOrigin))
 	http.HandleFunc("/api/gpuinfo", withCORS(gpuInfoHandler, config.AllowedOrigin))
-	http.HandleFunc("/api/export.csv", withCORS(exportCSVHandler, config.AllowedOrigin))
-	http.HandleFunc("/api/export.json", withCORS(exportJSONHandler, config.AllowedOrigin))
+	http.HandleFunc("/api/export.csv", withCORS(withGzip(exportCSVHandler), config.AllowedOrigin))
+	http.HandleFunc("/api/export.json", withCORS(withGzip(exportJSONHandler), config.AllowedOrigin))
+	http.HandleFunc("/api/export.ndjson", withCORS(withGzip(exportNDJSONHandler), config.AllowedOrigin))
+	http.HandleFunc("/api/export.influx", withCORS(withGzip(exportInfluxHandler), config.AllowedOrigin))
+	http.HandleFunc("/api/export.parquet", withCORS(exportParquetHandler, config.AllowedOrigin))
 	http.HandleFunc("/api/config", withCORS(configHandler, config.AllowedOrigin))
 	http.HandleFunc("/api/health", withCORS(healthHandler, config.AllowedOrigin))
 	http.HandleFunc("/api/rocm-test", withCORS(rocmTestHandler, config.AllowedOrigin))
@@ -119,39 +291,93 @@ func withCORS(handler http.HandlerFunc, allowedOrigin string) http.HandlerFunc {
 }
 
 func statsHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters for time window
+	// Parse query parameters for time window and resolution (raw or a rollup tier)
 	query := r.URL.Query()
+	resolution := query.Get("resolution")
+	var cutoff time.Time
 	if windowStr := query.Get("window"); windowStr != "" {
 		duration, err := time.ParseDuration(windowStr)
 		if err == nil {
-			// Get windowed data and return as simple array
-			history := collector.GetHistory()
-			if len(history) == 0 {
-				http.Error(w, "No data available", http.StatusNotFound)
-				return
-			}
-
-			// Filter history by time window
-			cutoff := time.Now().Add(-duration)
-			var filtered []RocmData
-			for _, data := range history {
-				if data.Timestamp.After(cutoff) {
-					filtered = append(filtered, data)
-				}
+			cutoff = time.Now().Add(-duration)
+			if resolution == "" {
+				// Long windows are served from the downsampled tiers
+				resolution = collector.SelectResolution(duration)
 			}
+		}
+	}
+	if resolution == "" {
+		resolution = ResolutionRaw
+	}
+	w.Header().Set("X-Resolution", resolution)
 
-			w.Header().Set("Content-Type", "application/json")
-			if err := json.NewEncoder(w).Encode(filtered); err != nil {
-				http.Error(w, "Failed to encode data", http.StatusInternalServerError)
-			}
+	if resolution != ResolutionRaw {
+		points, err := collector.GetRollups(resolution, cutoff)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if points == nil {
+			points = []RollupPoint{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(points); err != nil {
+			http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// A window over an empty history is reported as missing data
+	if !cutoff.IsZero() && collector.HistoryLen() == 0 {
+		http.Error(w, "No data available", http.StatusNotFound)
+		return
+	}
+
+	// Encode the samples inside the window (or the full history) without copying them
+	body, err := encodeHistorySince(cutoff)
+	if err != nil {
+		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
+		return
 	}
-	
-	// Return full history
-	history := collector.GetHistory()
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(history); err != nil {
+	w.Write(body)
+}
+
+// encodeHistorySince encodes the samples taken after cutoff as a JSON array, reading them
+// through the history view. Only the encoding runs under the read lock; the caller writes
+// the result to the network.
+func encodeHistorySince(cutoff time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	collector.ViewHistory(func(view HistoryView) {
+		buf.WriteByte('[')
+		first := true
+		view.RangeSince(cutoff, func(data *RocmData) bool {
+			var sample []byte
+			if sample, err = json.Marshal(data); err != nil {
+				return false
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			buf.Write(sample)
+			return true
+		})
+		buf.WriteString("]\n")
+	})
+	return buf.Bytes(), err
+}
+
+// queryHandler returns one metric over a time range, aggregated per step
+func queryHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := ParseRangeQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(collector.Query(query)); err != nil {
 		http.Error(w, "Failed to encode data", http.StatusInternalServerError)
 	}
 }
@@ -164,12 +390,17 @@ func latestHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func gpuInfoHandler(w http.ResponseWriter, r *http.Request) {
-	gpuInfo, err := GetGPUStaticInfo()
+	gpuInfo, err := collector.GetStaticInfo()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to get GPU info: %v", err), http.StatusInternalServerError)
 		return
 	}
 	
+	// Report the age of the cached static info in seconds
+	if age := collector.GetStaticInfoAge(); age >= 0 {
+		w.Header().Set("Age", strconv.Itoa(int(age.Seconds())))
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(gpuInfo); err != nil {
 		http.Error(w, "Failed to encode GPU info", http.StatusInternalServerError)
@@ -177,23 +408,67 @@ func gpuInfoHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func exportCSVHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/csv")
-	w.Header().Set("Content-Disposition", "attachment;filename=rocm_stats.csv")
-	
-	if err := exporter.ExportCSV(w); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	writeExport(w, r, "text/csv", "rocm_stats.csv", exporter.ExportCSV)
 }
 
 func exportJSONHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Content-Disposition", "attachment;filename=rocm_stats.json")
-	
-	if err := exporter.ExportJSON(w); err != nil {
+	writeExport(w, r, "application/json", "rocm_stats.json", exporter.ExportJSON)
+}
+
+func exportNDJSONHandler(w http.ResponseWriter, r *http.Request) {
+	writeExport(w, r, "application/x-ndjson", "rocm_stats.ndjson", exporter.ExportNDJSON)
+}
+
+func exportInfluxHandler(w http.ResponseWriter, r *http.Request) {
+	writeExport(w, r, "text/plain; charset=utf-8", "rocm_stats.lp", exporter.ExportInflux)
+}
+
+// exportParquetHandler is not gzip-wrapped, as the pages are already compressed
+func exportParquetHandler(w http.ResponseWriter, r *http.Request) {
+	writeExport(w, r, "application/vnd.apache.parquet", "rocm_stats.parquet", exporter.ExportParquet)
+}
+
+// writeExport streams a history export as a download. Bad filters and an empty history are
+// reported before any output; once the body has started the status is sent, so a later
+// error is only logged and the download ends early.
+func writeExport(w http.ResponseWriter, r *http.Request, contentType, filename string, export func(io.Writer, ExportFilter) error) {
+	filter, err := ParseExportFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if exporter.collector.HistoryLen() == 0 {
+		http.Error(w, "no data to export", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment;filename="+filename)
+
+	out := &exportWriter{w: w}
+	if err := export(out, filter); err != nil {
+		if out.started {
+			log.Printf("Export of %s failed after the response started: %v", filename, err)
+			return
+		}
+		w.Header().Del("Content-Disposition")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+// exportWriter records whether an export has written any of the response body
+type exportWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (e *exportWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		e.started = true
+	}
+	return e.w.Write(p)
+}
+
 func configHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodPost {
 		// Update configuration
@@ -207,14 +482,10 @@ func configHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		
 		if update.Interval != "" {
-			duration, err := time.ParseDuration(update.Interval)
-			if err != nil {
-				http.Error(w, "Invalid interval format", http.StatusBadRequest)
+			if _, err := setCollectionInterval(update.Interval); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
-			
-			collector.SetInterval(duration)
-			log.Printf("Updated collection interval to: %v", duration)
 		}
 		
 		w.WriteHeader(http.StatusOK)
@@ -227,38 +498,71 @@ func configHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(stats)
 }
 
+// setCollectionInterval parses and applies a new collection interval; it backs both
+// POST /api/config and the WebSocket set_interval command
+func setCollectionInterval(value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval format: %w", err)
+	}
+	if err := collector.Reconfigure(duration); err != nil {
+		return 0, err
+	}
+	log.Printf("Updated collection interval to: %v", duration)
+	return duration, nil
+}
+
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	latest, err := collector.GetLatest()
+	collection := collector.GetCollectionStats()
 	health := struct {
-		Status    string    `json:"status"`
-		Timestamp time.Time `json:"timestamp"`
-		GPUCount  int       `json:"gpu_count"`
-		Error     string    `json:"error,omitempty"`
+		Status        string          `json:"status"`
+		Timestamp     time.Time       `json:"timestamp"`
+		GPUCount      int             `json:"gpu_count"`
+		Error         string          `json:"error,omitempty"`
+		UptimeSeconds float64         `json:"uptime_seconds"`
+		MemoryUsageMB float64         `json:"memory_usage_mb"`
+		Collection    CollectionStats `json:"collection"`
 	}{
-		Status:    "healthy",
-		Timestamp: time.Now(),
+		Status:        "healthy",
+		Timestamp:     time.Now(),
+		UptimeSeconds: time.Since(collection.StartTime).Seconds(),
+		MemoryUsageMB: memoryUsageMB(),
+		Collection:    collection,
 	}
 	
+	status := http.StatusOK
 	if err != nil {
 		health.Status = "unhealthy"
 		health.Error = err.Error()
-		w.WriteHeader(http.StatusServiceUnavailable)
+		status = http.StatusServiceUnavailable
 	} else {
 		health.GPUCount = len(latest.GPUs)
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(health)
 }
 
 func prometheusHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/plain")
-	if err := exporter.ExportPrometheus(w); err != nil {
+	// Serve OpenMetrics to scrapers that ask for it, the classic text format otherwise
+	contentType, export := ContentTypePrometheusText, exporter.ExportPrometheus
+	if PreferOpenMetrics(r.Header.Get("Accept")) {
+		contentType, export = ContentTypeOpenMetrics, exporter.ExportOpenMetrics
+	}
+
+	var buf bytes.Buffer
+	if err := export(&buf); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
+	w.Write(buf.Bytes())
 }
 
-func setupGracefulShutdown() {
+func setupGracefulShutdown(store *HistoryStore, pushers []*Pusher) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	
@@ -266,6 +570,14 @@ func setupGracefulShutdown() {
 		<-sigChan
 		log.Println("🛑 Shutting down gracefully...")
 		collector.Stop()
+		for _, pusher := range pushers {
+			pusher.Stop()
+		}
+		if store != nil {
+			if err := store.Close(); err != nil {
+				log.Printf("Failed to close history store: %v", err)
+			}
+		}
 		os.Exit(0)
 	}()
 }
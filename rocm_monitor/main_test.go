@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useCollector installs c as the collector the handlers read from for the rest of the test
+func useCollector(t *testing.T, c *Collector) {
+	t.Helper()
+	previous := collector
+	collector = c
+	t.Cleanup(func() { collector = previous })
+}
+
+func TestStatsHandlerRawHistory(t *testing.T) {
+	ring := NewHistoryRing(5)
+	now := time.Now()
+	for i := 7; i >= 0; i-- {
+		data := RocmData{Timestamp: now.Add(-time.Duration(i) * time.Minute), GPUs: []GPU{fakeGPU(0, "")}}
+		ring.Push(data)
+	}
+	useCollector(t, &Collector{history: ring})
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 5},
+		{"?window=150s", 3},
+		{"?window=10s", 1},
+		{"?resolution=raw&window=1h", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			statsHandler(w, httptest.NewRequest(http.MethodGet, "/api/stats"+tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var samples []RocmData
+			if err := json.Unmarshal(w.Body.Bytes(), &samples); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if len(samples) != tt.want {
+				t.Fatalf("got %d samples, want %d", len(samples), tt.want)
+			}
+			if !samples[len(samples)-1].Timestamp.Equal(now) || !samples[0].GPUs[0].Has(FieldTemperature) {
+				t.Errorf("samples = %+v", samples)
+			}
+		})
+	}
+
+	// An empty history is an empty array, and a window over it is missing data
+	useCollector(t, &Collector{history: NewHistoryRing(5)})
+	w := httptest.NewRecorder()
+	statsHandler(w, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "[]\n" {
+		t.Errorf("empty history: status %d, body %q", w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	statsHandler(w, httptest.NewRequest(http.MethodGet, "/api/stats?window=1h", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("empty window: status %d, want 404", w.Code)
+	}
+}
+
+func TestExportHandlers(t *testing.T) {
+	c := &Collector{source: &fakeSource{}, history: NewHistoryRing(5), stats: newCollectionStats()}
+	useCollector(t, c)
+	previous := exporter
+	exporter = NewExporter(c, nil)
+	t.Cleanup(func() { exporter = previous })
+
+	get := func(handler http.HandlerFunc, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/api/export"+query, nil))
+		return w
+	}
+
+	if w := get(exportNDJSONHandler, ""); w.Code != http.StatusNotFound {
+		t.Errorf("empty history: status %d, want 404", w.Code)
+	}
+
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		c.history.Push(RocmData{Timestamp: now.Add(time.Duration(i) * time.Second), GPUs: []GPU{fakeGPU(0, "")}})
+	}
+	for _, handler := range []http.HandlerFunc{exportCSVHandler, exportJSONHandler, exportNDJSONHandler, exportInfluxHandler, exportParquetHandler} {
+		if w := get(handler, "?fields=voltage"); w.Code != http.StatusBadRequest || w.Header().Get("Content-Disposition") != "" {
+			t.Errorf("invalid filter: status %d, Content-Disposition %q", w.Code, w.Header().Get("Content-Disposition"))
+		}
+		if w := get(handler, "?gpu=0"); w.Code != http.StatusOK || w.Body.Len() == 0 || !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment") {
+			t.Errorf("export: status %d, %d bytes, Content-Disposition %q", w.Code, w.Body.Len(), w.Header().Get("Content-Disposition"))
+		}
+	}
+	if w := get(exportNDJSONHandler, ""); strings.Count(w.Body.String(), "\n") != 2 {
+		t.Errorf("NDJSON export %q, want 2 lines", w.Body)
+	}
+
+	// Errors become a response only while nothing has been written
+	failed := errors.New("disk on fire")
+	w := httptest.NewRecorder()
+	writeExport(w, httptest.NewRequest(http.MethodGet, "/api/export.ndjson", nil), "application/x-ndjson", "rocm_stats.ndjson", func(io.Writer, ExportFilter) error {
+		return failed
+	})
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Disposition") != "" || !strings.Contains(w.Body.String(), "disk on fire") {
+		t.Errorf("failure before output: status %d, Content-Disposition %q, body %q", w.Code, w.Header().Get("Content-Disposition"), w.Body)
+	}
+	w = httptest.NewRecorder()
+	writeExport(w, httptest.NewRequest(http.MethodGet, "/api/export.ndjson", nil), "application/x-ndjson", "rocm_stats.ndjson", func(out io.Writer, _ ExportFilter) error {
+		io.WriteString(out, "{}\n")
+		return failed
+	})
+	if w.Code != http.StatusOK || w.Body.String() != "{}\n" || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("failure after output: status %d, Content-Type %q, body %q; want the partial body alone", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+}
+
+func TestStatsHandlerTierSelection(t *testing.T) {
+	// Ten minutes of raw samples, an hour at 1m and everything at 15m over the last five hours
+	c := &Collector{history: NewHistoryRing(10)}
+	c.tiers = []*RollupTier{NewRollupTier("1m", time.Minute, time.Hour), NewRollupTier("15m", 15*time.Minute, 48*time.Hour)}
+	now := time.Now()
+	for i := 300; i >= 0; i-- {
+		data := RocmData{Timestamp: now.Add(-time.Duration(i) * time.Minute), GPUs: []GPU{fakeGPU(0, "")}}
+		c.appendHistory(&data)
+	}
+	useCollector(t, c)
+
+	tests := []struct {
+		query      string
+		resolution string
+		window     time.Duration
+	}{
+		{"", ResolutionRaw, 0},
+		{"?window=5m", ResolutionRaw, 5 * time.Minute},
+		{"?window=30m", "1m", 30 * time.Minute},
+		{"?window=3h", "15m", 3 * time.Hour},
+		{"?window=24h", "15m", 24 * time.Hour},
+		{"?resolution=raw&window=3h", ResolutionRaw, 3 * time.Hour},
+		{"?resolution=1m&window=3h", "1m", 3 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			statsHandler(w, httptest.NewRequest(http.MethodGet, "/api/stats"+tt.query, nil))
+			if w.Code != http.StatusOK || w.Header().Get("X-Resolution") != tt.resolution {
+				t.Fatalf("status %d, resolution %q; want %q", w.Code, w.Header().Get("X-Resolution"), tt.resolution)
+			}
+			if tt.resolution == ResolutionRaw {
+				return
+			}
+
+			// Rollup points cover the window at the tier's resolution
+			var points []RollupPoint
+			if err := json.Unmarshal(w.Body.Bytes(), &points); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			resolution := time.Minute
+			if tt.resolution == "15m" {
+				resolution = 15 * time.Minute
+			}
+			if len(points) == 0 || points[0].Resolution != resolution.Seconds() {
+				t.Fatalf("%d points, first %+v", len(points), points)
+			}
+			if end := points[0].Timestamp.Add(resolution); !end.After(now.Add(-tt.window)) {
+				t.Errorf("first point ends at %v, before the window", end)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	statsHandler(w, httptest.NewRequest(http.MethodGet, "/api/stats?resolution=1h", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown resolution: status %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricType is the type of a metric family
+type MetricType string
+
+const (
+	MetricGauge     MetricType = "gauge"
+	MetricCounter   MetricType = "counter"
+	MetricHistogram MetricType = "histogram"
+)
+
+// Label is a metric label; values are escaped on output
+type Label struct {
+	Name  string
+	Value string
+}
+
+// MetricSample is one series of a family. Suffix distinguishes the series of histograms
+// ("_bucket", "_sum", "_count") and is empty for gauges and counters.
+type MetricSample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// MetricFamily groups the samples of one metric name under a single HELP and TYPE.
+// Counter names include the _total suffix.
+type MetricFamily struct {
+	Name      string
+	Help      string
+	Type      MetricType
+	Timestamp time.Time // Applied to every sample; zero writes no timestamp
+	Created   time.Time // Start of counting for counters and histograms; OpenMetrics only
+	Samples   []MetricSample
+}
+
+// Add appends a sample
+func (f *MetricFamily) Add(value float64, labels ...Label) {
+	f.AddSuffixed("", value, labels...)
+}
+
+// AddSuffixed appends a sample of a histogram series such as "_bucket"
+func (f *MetricFamily) AddSuffixed(suffix string, value float64, labels ...Label) {
+	f.Samples = append(f.Samples, MetricSample{Suffix: suffix, Labels: labels, Value: value})
+}
+
+// MetricSet is an ordered collection of metric families
+type MetricSet struct {
+	timestamp time.Time
+	families  []*MetricFamily
+	index     map[string]*MetricFamily
+}
+
+// NewMetricSet creates an empty set whose families default to the given sample timestamp
+func NewMetricSet(timestamp time.Time) *MetricSet {
+	return &MetricSet{timestamp: timestamp, index: make(map[string]*MetricFamily)}
+}
+
+// Family returns the family with the given name, creating it on first use. Families are
+// written in order of creation.
+func (s *MetricSet) Family(name string, metricType MetricType, help string) *MetricFamily {
+	if family, ok := s.index[name]; ok {
+		return family
+	}
+	family := &MetricFamily{Name: name, Help: help, Type: metricType, Timestamp: s.timestamp}
+	s.families = append(s.families, family)
+	s.index[name] = family
+	return family
+}
+
+// Families returns the families in output order
+func (s *MetricSet) Families() []*MetricFamily {
+	return s.families
+}
+
+// WriteText writes the set in the Prometheus text exposition format 0.0.4. Families without
+// samples are left out.
+func (s *MetricSet) WriteText(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	for _, family := range s.families {
+		if len(family.Samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "# HELP %s %s\n", family.Name, escapeMetricHelp(family.Help))
+		fmt.Fprintf(out, "# TYPE %s %s\n", family.Name, family.Type)
+		for _, sample := range family.Samples {
+			out.WriteString(family.Name)
+			out.WriteString(sample.Suffix)
+			writeMetricLabels(out, sample.Labels)
+			out.WriteByte(' ')
+			out.WriteString(formatMetricValue(sample.Value))
+			if !family.Timestamp.IsZero() {
+				out.WriteByte(' ')
+				out.WriteString(strconv.FormatInt(family.Timestamp.UnixMilli(), 10))
+			}
+			out.WriteByte('\n')
+		}
+	}
+	return out.Flush()
+}
+
+// writeMetricLabels writes {name="value",...}, or nothing for an empty label set
+func writeMetricLabels(out *bufio.Writer, labels []Label) {
+	if len(labels) == 0 {
+		return
+	}
+	out.WriteByte('{')
+	for i, label := range labels {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		out.WriteString(label.Name)
+		out.WriteString(`="`)
+		out.WriteString(escapeLabelValue(label.Value))
+		out.WriteByte('"')
+	}
+	out.WriteByte('}')
+}
+
+// Content types of the two exposition formats
+const (
+	ContentTypePrometheusText = "text/plain; version=0.0.4; charset=utf-8"
+	ContentTypeOpenMetrics    = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// metricUnits are the name suffixes reported as OpenMetrics units. OpenMetrics units must be
+// base units, so families in ms, MB, GB, MHz or percent are written without a UNIT line.
+var metricUnits = []string{"celsius", "watts", "seconds"}
+
+// WriteOpenMetrics writes the set in the OpenMetrics 1.0 text format, including units,
+// _created series and the closing # EOF
+func (s *MetricSet) WriteOpenMetrics(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	for _, family := range s.families {
+		if len(family.Samples) == 0 {
+			continue
+		}
+
+		// OpenMetrics names a counter family without _total and adds it to the samples
+		name := family.Name
+		if family.Type == MetricCounter {
+			name = strings.TrimSuffix(name, "_total")
+		}
+		fmt.Fprintf(out, "# TYPE %s %s\n", name, family.Type)
+		if unit := metricUnit(name); unit != "" {
+			fmt.Fprintf(out, "# UNIT %s %s\n", name, unit)
+		}
+		fmt.Fprintf(out, "# HELP %s %s\n", name, escapeOpenMetricsHelp(family.Help))
+
+		for i, sample := range family.Samples {
+			suffix := sample.Suffix
+			labels := sample.Labels
+			if family.Type == MetricCounter {
+				suffix = "_total"
+			}
+			if family.Type == MetricHistogram && suffix == "_bucket" {
+				labels = canonicalBucketLabels(labels)
+			}
+			writeOpenMetricsSample(out, name+suffix, labels, formatMetricValue(sample.Value), family.Timestamp)
+
+			// _created closes each labelled series; the samples of a series are adjacent
+			if family.Created.IsZero() || family.Type == MetricGauge {
+				continue
+			}
+			key := labelKey(sample.Labels, "le")
+			if i+1 < len(family.Samples) && labelKey(family.Samples[i+1].Labels, "le") == key {
+				continue
+			}
+			writeOpenMetricsSample(out, name+"_created", withoutLabel(sample.Labels, "le"), formatUnixSeconds(family.Created), family.Timestamp)
+		}
+	}
+	out.WriteString("# EOF\n")
+	return out.Flush()
+}
+
+// writeOpenMetricsSample writes one sample line; timestamps are in seconds
+func writeOpenMetricsSample(out *bufio.Writer, name string, labels []Label, value string, timestamp time.Time) {
+	out.WriteString(name)
+	writeMetricLabels(out, labels)
+	out.WriteByte(' ')
+	out.WriteString(value)
+	if !timestamp.IsZero() {
+		out.WriteByte(' ')
+		out.WriteString(formatUnixSeconds(timestamp))
+	}
+	out.WriteByte('\n')
+}
+
+// formatUnixSeconds formats a time as Unix seconds with millisecond precision
+func formatUnixSeconds(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', 3, 64)
+}
+
+// metricUnit returns the unit a metric name ends in, or "" if it has none
+func metricUnit(name string) string {
+	for _, unit := range metricUnits {
+		if strings.HasSuffix(name, "_"+unit) {
+			return unit
+		}
+	}
+	return ""
+}
+
+// canonicalBucketLabels rewrites the le label as an OpenMetrics canonical float such as 1.0
+func canonicalBucketLabels(labels []Label) []Label {
+	result := make([]Label, len(labels))
+	copy(result, labels)
+	for i, label := range result {
+		if label.Name != "le" {
+			continue
+		}
+		bound, err := strconv.ParseFloat(label.Value, 64)
+		if err != nil || math.IsInf(bound, 0) {
+			continue
+		}
+		value := strconv.FormatFloat(bound, 'g', -1, 64)
+		if !strings.ContainsAny(value, ".e") {
+			value += ".0"
+		}
+		result[i].Value = value
+	}
+	return result
+}
+
+// withoutLabel returns labels minus the named one
+func withoutLabel(labels []Label, name string) []Label {
+	var result []Label
+	for _, label := range labels {
+		if label.Name != name {
+			result = append(result, label)
+		}
+	}
+	return result
+}
+
+// labelKey identifies a label set, ignoring the named label
+func labelKey(labels []Label, ignore string) string {
+	var key strings.Builder
+	for _, label := range labels {
+		if label.Name == ignore {
+			continue
+		}
+		key.WriteString(label.Name)
+		key.WriteByte(0)
+		key.WriteString(label.Value)
+		key.WriteByte(0)
+	}
+	return key.String()
+}
+
+// PreferOpenMetrics reports whether an Accept header ranks OpenMetrics at least as high as
+// the classic text format
+func PreferOpenMetrics(accept string) bool {
+	var openMetricsQ, textQ float64
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		for _, param := range params[1:] {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.EqualFold(key, "q") {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		switch mediaType {
+		case "application/openmetrics-text":
+			if q > openMetricsQ {
+				openMetricsQ = q
+			}
+		case "text/plain", "text/*", "*/*":
+			if q > textQ {
+				textQ = q
+			}
+		}
+	}
+	return openMetricsQ > 0 && openMetricsQ >= textQ
+}
+
+var (
+	labelValueEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	metricHelpEscaper      = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	openMetricsHelpEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// escapeLabelValue escapes backslashes, double quotes and line feeds
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+// escapeMetricHelp escapes backslashes and line feeds
+func escapeMetricHelp(help string) string {
+	return metricHelpEscaper.Replace(help)
+}
+
+// escapeOpenMetricsHelp escapes backslashes, double quotes and line feeds
+func escapeOpenMetricsHelp(help string) string {
+	return openMetricsHelpEscaper.Replace(help)
+}
+
+// formatMetricValue formats a sample value, spelling out infinities and NaN
+func formatMetricValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// boolMetric converts a condition to a 0/1 gauge value
+func boolMetric(condition bool) float64 {
+	if condition {
+		return 1
+	}
+	return 0
+}
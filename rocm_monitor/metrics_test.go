@@ -0,0 +1,596 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files under testdata")
+
+// exposition is a parsed Prometheus text or OpenMetrics document
+type exposition struct {
+	families []*expositionFamily
+	index    map[string]*expositionFamily
+}
+
+// expositionFamily is a metric family with its metadata and samples
+type expositionFamily struct {
+	name, typ, help, unit string
+	samples               []expositionSample
+}
+
+// expositionSample is one sample line
+type expositionSample struct {
+	name      string
+	labels    []Label // Unescaped values
+	value     float64
+	timestamp string
+}
+
+// label returns the value of the named label
+func (s expositionSample) label(name string) (string, bool) {
+	for _, label := range s.labels {
+		if label.Name == name {
+			return label.Value, true
+		}
+	}
+	return "", false
+}
+
+var (
+	metricNameRegex     = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*`)
+	labelNameRegex      = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*`)
+	textTimestampRegex  = regexp.MustCompile(`^-?[0-9]+$`)
+	openMetricsTSRegex  = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+	metricTypeSuffixes  = map[string][]string{"gauge": {""}, "counter": {""}, "histogram": {"_bucket", "_sum", "_count"}}
+	openMetricsSuffixes = map[string][]string{"gauge": {""}, "counter": {"_total", "_created"}, "histogram": {"_bucket", "_sum", "_count", "_created"}}
+	// openMetricsBaseUnits are the units OpenMetrics allows in a UNIT line
+	openMetricsBaseUnits = map[string]bool{"seconds": true, "bytes": true, "celsius": true, "watts": true, "volts": true, "amperes": true, "joules": true, "hertz": true, "meters": true, "grams": true, "ratio": true}
+)
+
+// parseExposition checks a document against the grammar of the Prometheus text format 0.0.4
+// or OpenMetrics 1.0 as far as this exporter uses it: every family has exactly one HELP and
+// TYPE ahead of its samples, families are not split, label values are correctly escaped and
+// histogram buckets are cumulative and end in +Inf
+func parseExposition(document string, openMetrics bool) (*exposition, error) {
+	if !strings.HasSuffix(document, "\n") {
+		return nil, errors.New("document does not end in a line feed")
+	}
+	lines := strings.Split(strings.TrimSuffix(document, "\n"), "\n")
+	if openMetrics {
+		if lines[len(lines)-1] != "# EOF" {
+			return nil, errors.New("document does not end in # EOF")
+		}
+		lines = lines[:len(lines)-1]
+	}
+
+	exp := &exposition{index: make(map[string]*expositionFamily)}
+	var current *expositionFamily
+	series := make(map[string]bool)
+	for i, line := range lines {
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("line %d %q: %s", i+1, line, fmt.Sprintf(format, args...))
+		}
+
+		if strings.HasPrefix(line, "#") {
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) < 4 && !(len(fields) == 3 && fields[1] == "HELP") {
+				return nil, fail("malformed comment")
+			}
+			keyword, name := fields[1], fields[2]
+			text := ""
+			if len(fields) == 4 {
+				text = fields[3]
+			}
+			if !metricNameRegex.MatchString(name) || metricNameRegex.FindString(name) != name {
+				return nil, fail("invalid metric name")
+			}
+
+			family := exp.index[name]
+			if family == nil {
+				if current != nil && len(current.samples) == 0 {
+					return nil, fail("family %s has no samples", current.name)
+				}
+				family = &expositionFamily{name: name}
+				exp.index[name] = family
+				exp.families = append(exp.families, family)
+				current = family
+			} else if family != current || len(family.samples) > 0 {
+				return nil, fail("metadata for family %s after its samples or another family", name)
+			}
+
+			switch keyword {
+			case "HELP":
+				if family.help != "" {
+					return nil, fail("second HELP")
+				}
+				if err := checkEscapes(text, openMetrics); err != nil {
+					return nil, fail("HELP %v", err)
+				}
+				family.help = text
+				if family.help == "" {
+					family.help = " "
+				}
+			case "TYPE":
+				if family.typ != "" {
+					return nil, fail("second TYPE")
+				}
+				if _, ok := metricTypeSuffixes[text]; !ok {
+					return nil, fail("unknown type %q", text)
+				}
+				family.typ = text
+			case "UNIT":
+				if !openMetrics {
+					return nil, fail("UNIT is OpenMetrics only")
+				}
+				if family.unit != "" || !strings.HasSuffix(name, "_"+text) || !openMetricsBaseUnits[text] {
+					return nil, fail("invalid UNIT")
+				}
+				family.unit = text
+			default:
+				return nil, fail("unexpected comment")
+			}
+			continue
+		}
+
+		sample, err := parseExpositionSample(line, openMetrics)
+		if err != nil {
+			return nil, fail("%v", err)
+		}
+		if current == nil || current.typ == "" || current.help == "" {
+			return nil, fail("sample without a preceding HELP and TYPE")
+		}
+		suffixes := metricTypeSuffixes[current.typ]
+		if openMetrics {
+			suffixes = openMetricsSuffixes[current.typ]
+		}
+		belongs := false
+		for _, suffix := range suffixes {
+			belongs = belongs || sample.name == current.name+suffix
+		}
+		if !belongs {
+			return nil, fail("sample does not belong to family %s (%s)", current.name, current.typ)
+		}
+		key := sample.name + labelKey(sample.labels, "")
+		if series[key] {
+			return nil, fail("duplicate series")
+		}
+		series[key] = true
+		current.samples = append(current.samples, sample)
+	}
+	if current != nil && len(current.samples) == 0 {
+		return nil, fmt.Errorf("family %s has no samples", current.name)
+	}
+
+	for _, family := range exp.families {
+		if family.typ == "histogram" {
+			if err := checkHistogram(family, openMetrics); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return exp, nil
+}
+
+// parseExpositionSample parses name{labels} value [timestamp]
+func parseExpositionSample(line string, openMetrics bool) (expositionSample, error) {
+	var sample expositionSample
+	sample.name = metricNameRegex.FindString(line)
+	if sample.name == "" {
+		return sample, errors.New("missing metric name")
+	}
+	rest := line[len(sample.name):]
+
+	if strings.HasPrefix(rest, "{") {
+		rest = rest[1:]
+		seen := make(map[string]bool)
+		for !strings.HasPrefix(rest, "}") {
+			name := labelNameRegex.FindString(rest)
+			if name == "" || seen[name] {
+				return sample, errors.New("missing or repeated label name")
+			}
+			seen[name] = true
+			rest = rest[len(name):]
+			if !strings.HasPrefix(rest, `="`) {
+				return sample, errors.New(`label name not followed by ="`)
+			}
+			rest = rest[2:]
+
+			var value strings.Builder
+			closed := false
+			for len(rest) > 0 && !closed {
+				c := rest[0]
+				rest = rest[1:]
+				switch c {
+				case '"':
+					closed = true
+				case '\\':
+					if len(rest) == 0 {
+						return sample, errors.New("dangling backslash in label value")
+					}
+					switch rest[0] {
+					case '\\', '"':
+						value.WriteByte(rest[0])
+					case 'n':
+						value.WriteByte('\n')
+					default:
+						return sample, fmt.Errorf("invalid escape \\%c in label value", rest[0])
+					}
+					rest = rest[1:]
+				default:
+					value.WriteByte(c)
+				}
+			}
+			if !closed {
+				return sample, errors.New("unterminated label value")
+			}
+			sample.labels = append(sample.labels, Label{name, value.String()})
+
+			if strings.HasPrefix(rest, ",") {
+				rest = rest[1:]
+				if strings.HasPrefix(rest, "}") {
+					return sample, errors.New("trailing comma in label set")
+				}
+			} else if !strings.HasPrefix(rest, "}") {
+				return sample, errors.New("labels not separated by commas")
+			}
+		}
+		rest = rest[1:]
+	}
+
+	fields := strings.Split(strings.TrimPrefix(rest, " "), " ")
+	if !strings.HasPrefix(rest, " ") || len(fields) > 2 || fields[0] == "" {
+		return sample, errors.New("expected a value and an optional timestamp")
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, fmt.Errorf("invalid value %q", fields[0])
+	}
+	sample.value = value
+	if len(fields) == 2 {
+		sample.timestamp = fields[1]
+		pattern := textTimestampRegex
+		if openMetrics {
+			pattern = openMetricsTSRegex
+		}
+		if !pattern.MatchString(sample.timestamp) {
+			return sample, fmt.Errorf("invalid timestamp %q", sample.timestamp)
+		}
+	}
+	return sample, nil
+}
+
+// checkEscapes verifies that a HELP text only uses the escapes its format allows
+func checkEscapes(text string, openMetrics bool) error {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '"' && openMetrics {
+			return errors.New("unescaped double quote")
+		}
+		if text[i] != '\\' {
+			continue
+		}
+		i++
+		if i == len(text) || !(text[i] == '\\' || text[i] == 'n' || (openMetrics && text[i] == '"')) {
+			return errors.New("invalid escape")
+		}
+	}
+	return nil
+}
+
+// checkHistogram verifies the buckets of every series of a histogram family
+func checkHistogram(family *expositionFamily, openMetrics bool) error {
+	type histogramSeries struct {
+		bounds []float64
+		counts []float64
+		count  float64
+		hasSum bool
+	}
+	seriesByKey := make(map[string]*histogramSeries)
+	var keys []string
+	for _, sample := range family.samples {
+		key := labelKey(sample.labels, "le")
+		series := seriesByKey[key]
+		if series == nil {
+			series = &histogramSeries{count: -1}
+			seriesByKey[key] = series
+			keys = append(keys, key)
+		}
+		switch strings.TrimPrefix(sample.name, family.name) {
+		case "_bucket":
+			le, ok := sample.label("le")
+			if !ok {
+				return fmt.Errorf("%s: bucket without le", family.name)
+			}
+			if openMetrics && le != "+Inf" && !strings.ContainsAny(le, ".e") {
+				return fmt.Errorf("%s: le %q is not a canonical OpenMetrics float", family.name, le)
+			}
+			bound, err := strconv.ParseFloat(le, 64)
+			if err != nil {
+				return fmt.Errorf("%s: invalid le %q", family.name, le)
+			}
+			series.bounds = append(series.bounds, bound)
+			series.counts = append(series.counts, sample.value)
+		case "_sum":
+			series.hasSum = true
+		case "_count":
+			series.count = sample.value
+		}
+	}
+
+	for _, key := range keys {
+		series := seriesByKey[key]
+		n := len(series.bounds)
+		if n == 0 || !math.IsInf(series.bounds[n-1], 1) || !series.hasSum {
+			return fmt.Errorf("%s: series without a +Inf bucket or _sum", family.name)
+		}
+		for i := 1; i < n; i++ {
+			if series.bounds[i] <= series.bounds[i-1] || series.counts[i] < series.counts[i-1] {
+				return fmt.Errorf("%s: buckets not ascending and cumulative", family.name)
+			}
+		}
+		if series.counts[n-1] != series.count {
+			return fmt.Errorf("%s: +Inf bucket %v differs from _count %v", family.name, series.counts[n-1], series.count)
+		}
+	}
+	return nil
+}
+
+// goldenMetricSet builds a fixed metric set covering every kind of family the exporter writes
+func goldenMetricSet() *MetricSet {
+	timestamp := time.Date(2026, 3, 1, 12, 0, 0, 123e6, time.UTC)
+
+	gpu0 := GPU{ID: 0}
+	for field, value := range map[GPUField]float64{
+		FieldTemperature: 54.5, FieldPower: 212, FieldGPUUsage: 37, FieldFanSpeed: 40,
+		FieldVRAMUsage: 2, FieldVRAMTotal: 24, FieldVisVRAMUsage: 1, FieldVisVRAMTotal: 24,
+		FieldGTTUsage: 0.5, FieldGTTTotal: 31, FieldSCLKFreq: 2304, FieldMCLKFreq: 1249,
+	} {
+		gpu0.Set(field, value)
+	}
+	gpu1 := GPU{ID: 1}
+	for field, value := range map[GPUField]float64{
+		FieldTemperature: 81, FieldPower: 15.25, FieldGPUUsage: 0,
+		FieldVRAMUsage: 0.4, FieldVRAMTotal: 0.5, FieldGTTUsage: 6, FieldGTTTotal: 15,
+	} {
+		gpu1.Set(field, value)
+	}
+	data := &RocmData{Timestamp: timestamp, GPUs: []GPU{gpu0, gpu1}, CPUUsage: 12.5, CPUAvailable: true}
+	for i := range data.GPUs {
+		data.GPUs[i].UpdateAccessibleMemory()
+	}
+	static := map[int]GPUStaticInfo{
+		0: {ID: 0, ProductName: `Radeon "Pro" W7900`, VendorName: "AMD", SerialNumber: `SN\42`, VRAMVendor: "samsung"},
+		1: {ID: 1, ProductName: "Phoenix1\nAPU", VendorName: "AMD", SerialNumber: "Not Available", VRAMVendor: `hynix\`},
+	}
+
+	stats := newCollectionStats()
+	for _, latency := range []time.Duration{30 * time.Millisecond, 70 * time.Millisecond, 400 * time.Millisecond, 3 * time.Second, 12 * time.Second} {
+		stats.observe(latency)
+	}
+	for i := 0; i < 4; i++ {
+		stats.success()
+	}
+	stats.failure(ErrorExec, errors.New("rocm-smi failed"), true)
+	stats.failure(ErrorValidate, errors.New("dropped power"), false)
+	collection := stats.snapshot()
+	collection.StartTime = timestamp.Add(-time.Hour)
+
+	suite := &ROCmTestSuite{
+		OverallSuccess: false,
+		Timestamp:      timestamp.Add(-10 * time.Minute),
+		Duration:       1500,
+		TestResults: []ROCmTestResult{
+			{Name: `Device "list"`, Command: "rocminfo", Success: true, Duration: 900, Issues: []string{"slow"}},
+			{Name: "Kernel module", Command: `lsmod | grep "amdgpu"`, Success: false, Duration: 600},
+		},
+	}
+
+	// As in PrometheusMetrics, only the sample families carry a timestamp
+	metrics := NewMetricSet(time.Time{})
+	addSampleMetrics(metrics, data, static)
+	addThresholdMetrics(metrics, data)
+	addCollectionMetrics(metrics, collection)
+	addROCmTestMetrics(metrics, suite)
+	return metrics
+}
+
+func TestMetricsGolden(t *testing.T) {
+	tests := []struct {
+		file        string
+		openMetrics bool
+	}{
+		{"sample.prom", false},
+		{"sample.om", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			var buf bytes.Buffer
+			var err error
+			if tt.openMetrics {
+				err = goldenMetricSet().WriteOpenMetrics(&buf)
+			} else {
+				err = goldenMetricSet().WriteText(&buf)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join("testdata", "metrics", tt.file)
+			if *updateGolden {
+				if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			golden := readFixture(t, "metrics", tt.file)
+			if !bytes.Equal(buf.Bytes(), golden) {
+				t.Errorf("output differs from %s (rerun with -update to accept):\n%s", path, buf.String())
+			}
+
+			// The golden file itself must parse
+			exp, err := parseExposition(string(golden), tt.openMetrics)
+			if err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+
+			// Label values survive escaping
+			temperature := exp.index["rocm_gpu_temperature_celsius"]
+			if temperature == nil || len(temperature.samples) != 2 {
+				t.Fatalf("temperature family = %+v", temperature)
+			}
+			for i, want := range []struct{ product, serial, vramVendor string }{
+				{`Radeon "Pro" W7900`, `SN\42`, "samsung"},
+				{"Phoenix1\nAPU", "Not Available", `hynix\`},
+			} {
+				sample := temperature.samples[i]
+				product, _ := sample.label("product_name")
+				serial, _ := sample.label("serial_number")
+				vramVendor, _ := sample.label("vram_vendor")
+				if product != want.product || serial != want.serial || vramVendor != want.vramVendor {
+					t.Errorf("GPU %d labels %q, %q, %q", i, product, serial, vramVendor)
+				}
+			}
+
+			// The histogram counts every observation
+			latency := exp.index["rocm_monitor_collection_latency_seconds"]
+			if latency == nil || latency.typ != "histogram" {
+				t.Fatalf("latency family = %+v", latency)
+			}
+			wantBuckets := []float64{1, 2, 2, 3, 3, 3, 4, 4, 5}
+			var buckets []float64
+			for _, sample := range latency.samples {
+				if sample.name == latency.name+"_bucket" {
+					buckets = append(buckets, sample.value)
+				}
+			}
+			if fmt.Sprint(buckets) != fmt.Sprint(wantBuckets) {
+				t.Errorf("latency buckets %v, want %v", buckets, wantBuckets)
+			}
+		})
+	}
+}
+
+func TestParseExpositionRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+	}{
+		{"second HELP", "# HELP a x\n# HELP a y\n# TYPE a gauge\na 1\n"},
+		{"second TYPE", "# HELP a x\n# TYPE a gauge\n# TYPE a gauge\na 1\n"},
+		{"split family", "# HELP a x\n# TYPE a gauge\na 1\n# HELP b x\n# TYPE b gauge\nb 1\n# HELP a x\n"},
+		{"sample of another family", "# HELP a x\n# TYPE a gauge\nb 1\n"},
+		{"raw quote in label", "# HELP a x\n# TYPE a gauge\na{l=\"x\"y\"} 1\n"},
+		{"invalid escape", "# HELP a x\n# TYPE a gauge\na{l=\"x\\ty\"} 1\n"},
+		{"descending buckets", "# HELP h x\n# TYPE h histogram\nh_bucket{le=\"1\"} 2\nh_bucket{le=\"0.5\"} 2\nh_bucket{le=\"+Inf\"} 2\nh_sum 1\nh_count 2\n"},
+		{"missing +Inf", "# HELP h x\n# TYPE h histogram\nh_bucket{le=\"1\"} 2\nh_sum 1\nh_count 2\n"},
+		{"count mismatch", "# HELP h x\n# TYPE h histogram\nh_bucket{le=\"+Inf\"} 2\nh_sum 1\nh_count 3\n"},
+	}
+	for _, tt := range tests {
+		if _, err := parseExposition(tt.document, false); err == nil {
+			t.Errorf("%s: accepted %q", tt.name, tt.document)
+		}
+	}
+	if _, err := parseExposition("# HELP a x\n# TYPE a gauge\na 1\n", true); err == nil {
+		t.Error("accepted OpenMetrics without # EOF")
+	}
+}
+
+func TestPrometheusMetricsGrammar(t *testing.T) {
+	data := exporterSample()
+	ring := NewHistoryRing(10)
+	ring.Push(*data)
+	c := &Collector{
+		source:     &fakeSource{},
+		history:    ring,
+		staticInfo: []GPUStaticInfo{{ID: 0, ProductName: "Radeon \"Pro\"\n\\W7900", VendorName: "AMD"}},
+		stats:      newCollectionStats(),
+	}
+	metrics, err := NewExporter(c, nil).PrometheusMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, openMetrics := range []bool{false, true} {
+		var buf bytes.Buffer
+		if openMetrics {
+			err = metrics.WriteOpenMetrics(&buf)
+		} else {
+			err = metrics.WriteText(&buf)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp, err := parseExposition(buf.String(), openMetrics)
+		if err != nil {
+			t.Fatalf("OpenMetrics %v: %v\n%s", openMetrics, err, buf.String())
+		}
+		if len(exp.families) != len(metrics.Families()) {
+			t.Errorf("OpenMetrics %v: %d families written, %d built", openMetrics, len(exp.families), len(metrics.Families()))
+		}
+	}
+}
+
+// exporterSample returns a single-GPU sample for tests that go through the Exporter
+func exporterSample() *RocmData {
+	gpu := fakeGPU(0, "")
+	gpu.Set(FieldVRAMUsage, 1)
+	gpu.Set(FieldVRAMTotal, 16)
+	return &RocmData{Timestamp: time.Now(), GPUs: []GPU{gpu}}
+}
+
+func TestPrometheusMetricsWithoutSample(t *testing.T) {
+	tests := &ROCmTestStore{latest: &ROCmTestSuite{
+		OverallSuccess: true,
+		Timestamp:      time.Now().Add(-time.Hour),
+		TestResults:    []ROCmTestResult{{Name: "rocminfo", Command: "rocminfo", Success: true}},
+	}}
+	c := &Collector{source: &fakeSource{}, history: NewHistoryRing(10), stats: newCollectionStats()}
+	previous := exporter
+	exporter = NewExporter(c, tests)
+	t.Cleanup(func() { exporter = previous })
+
+	for _, accept := range []string{"", "application/openmetrics-text"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		r.Header.Set("Accept", accept)
+		prometheusHandler(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Accept %q: status %d: %s", accept, w.Code, w.Body)
+		}
+
+		exp, err := parseExposition(w.Body.String(), accept != "")
+		if err != nil {
+			t.Fatalf("Accept %q: %v\n%s", accept, err, w.Body)
+		}
+		for _, name := range []string{"rocm_monitor_failed_collections", "rocm_monitor_uptime_seconds", "rocm_test_suite_success", "rocm_test_success"} {
+			if accept == "" && name == "rocm_monitor_failed_collections" {
+				name += "_total"
+			}
+			if exp.index[name] == nil {
+				t.Errorf("Accept %q: family %s missing", accept, name)
+			}
+		}
+		for _, family := range exp.families {
+			if strings.HasPrefix(family.name, "rocm_gpu_") || strings.HasPrefix(family.name, "rocm_system_") {
+				t.Errorf("Accept %q: per-sample family %s reported without a sample", accept, family.name)
+			}
+			for _, sample := range family.samples {
+				if sample.timestamp != "" {
+					t.Errorf("Accept %q: %s stamped with %s", accept, sample.name, sample.timestamp)
+				}
+			}
+		}
+	}
+}
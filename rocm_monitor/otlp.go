@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// OpenTelemetry metrics export over OTLP/HTTP with the JSON encoding. Each GPU is a resource
+// described by hw.* attributes, with its metrics as gauges named after the OpenTelemetry
+// hardware semantic conventions where one exists; the host's own metrics form one more
+// resource. Values are converted to the conventional base units: bytes, hertz and ratios.
+const (
+	otlpMetricsPath  = "/v1/metrics"
+	otlpScopeName    = "rocm-monitor"
+	otlpScopeVersion = "1.0.0"
+	bytesPerGB       = 1 << 30 // Memory values are collected in GiB
+)
+
+// otlpGPUMetric describes a per-GPU gauge
+type otlpGPUMetric struct {
+	name        string
+	description string
+	unit        string
+	value       func(gpu GPU) (float64, bool)
+}
+
+// otlpGPUMetrics lists the per-GPU gauges in output order
+var otlpGPUMetrics = []otlpGPUMetric{
+	{"hw.temperature", "GPU edge temperature", "Cel", gpuFieldMetric(FieldTemperature)},
+	{"hw.power", "GPU power consumption", "W", gpuFieldMetric(FieldPower)},
+	{"hw.gpu.utilization", "Fraction of time the GPU was busy", "1", scaledGPUMetric(FieldGPUUsage, 0.01)},
+	{"hw.gpu.memory.usage", "VRAM in use", "By", scaledGPUMetric(FieldVRAMUsage, bytesPerGB)},
+	{"hw.gpu.memory.limit", "VRAM size", "By", scaledGPUMetric(FieldVRAMTotal, bytesPerGB)},
+	{"hw.gpu.memory.utilization", "Fraction of VRAM in use", "1", scaledMetric(gpuUtilizationMetric(FieldVRAMUsage, FieldVRAMTotal), 0.01)},
+	{"rocm.gpu.vis_vram.usage", "CPU-visible VRAM in use", "By", scaledGPUMetric(FieldVisVRAMUsage, bytesPerGB)},
+	{"rocm.gpu.vis_vram.limit", "CPU-visible VRAM size", "By", scaledGPUMetric(FieldVisVRAMTotal, bytesPerGB)},
+	{"rocm.gpu.gtt.usage", "GTT memory in use", "By", scaledGPUMetric(FieldGTTUsage, bytesPerGB)},
+	{"rocm.gpu.gtt.limit", "GTT memory size", "By", scaledGPUMetric(FieldGTTTotal, bytesPerGB)},
+	{"rocm.gpu.accessible_memory.usage", "GPU-accessible memory (VRAM + GTT) in use", "By", scaledGPUMetric(FieldAccessibleMemUsage, bytesPerGB)},
+	{"rocm.gpu.accessible_memory.limit", "GPU-accessible memory (VRAM + GTT) size", "By", scaledGPUMetric(FieldAccessibleMemTotal, bytesPerGB)},
+	{"rocm.gpu.sclk.frequency", "GPU system clock frequency", "Hz", scaledGPUMetric(FieldSCLKFreq, 1e6)},
+	{"rocm.gpu.mclk.frequency", "GPU memory clock frequency", "Hz", scaledGPUMetric(FieldMCLKFreq, 1e6)},
+	{"hw.fan.speed_ratio", "GPU fan speed as a fraction of its maximum", "1", scaledGPUMetric(FieldFanSpeed, 0.01)},
+}
+
+// scaledGPUMetric reads a metric from the sample and converts its unit
+func scaledGPUMetric(field GPUField, factor float64) func(gpu GPU) (float64, bool) {
+	return scaledMetric(gpuFieldMetric(field), factor)
+}
+
+// scaledMetric converts the unit of a derived metric
+func scaledMetric(metric func(gpu GPU) (float64, bool), factor float64) func(gpu GPU) (float64, bool) {
+	return func(gpu GPU) (float64, bool) {
+		value, ok := metric(gpu)
+		return value * factor, ok
+	}
+}
+
+// OTLP/JSON messages, limited to what gauges need. 64-bit integers are strings in the JSON
+// encoding.
+type otlpMetricsRequest struct {
+	ResourceMetrics []*otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope     `json:"scope"`
+	Metrics []*otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpMetric struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Unit        string    `json:"unit"`
+	Gauge       otlpGauge `json:"gauge"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpDataPoint struct {
+	TimeUnixNano string  `json:"timeUnixNano"`
+	AsDouble     float64 `json:"asDouble"`
+}
+
+type otlpAttribute struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// otlpResourceBuilder collects the gauges of one resource, keeping metrics in first-seen order
+type otlpResourceBuilder struct {
+	resource *otlpResourceMetrics
+	metrics  map[string]*otlpMetric
+}
+
+// newOTLPResourceBuilder starts a resource with the given attributes. Empty values and the
+// "Not Available" placeholder of unknown static info are left out.
+func newOTLPResourceBuilder(attributes ...[2]string) *otlpResourceBuilder {
+	resource := &otlpResourceMetrics{
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: otlpScopeName, Version: otlpScopeVersion}}},
+	}
+	for _, attribute := range attributes {
+		if attribute[1] != "" && attribute[1] != "Not Available" {
+			resource.Resource.Attributes = append(resource.Resource.Attributes,
+				otlpAttribute{Key: attribute[0], Value: otlpAnyValue{StringValue: attribute[1]}})
+		}
+	}
+	return &otlpResourceBuilder{resource: resource, metrics: make(map[string]*otlpMetric)}
+}
+
+// add appends a data point; OTLP/JSON cannot carry NaN or infinities, so those are skipped
+func (b *otlpResourceBuilder) add(name, description, unit string, value float64, timeUnixNano string) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+	metric, ok := b.metrics[name]
+	if !ok {
+		metric = &otlpMetric{Name: name, Description: description, Unit: unit}
+		b.metrics[name] = metric
+		scope := &b.resource.ScopeMetrics[0]
+		scope.Metrics = append(scope.Metrics, metric)
+	}
+	metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, otlpDataPoint{TimeUnixNano: timeUnixNano, AsDouble: value})
+}
+
+// OTLPMetricsRequest encodes samples as an OTLP/JSON ExportMetricsServiceRequest with one
+// resource per GPU and one for the host
+func (e *Exporter) OTLPMetricsRequest(samples []RocmData, hostName string) ([]byte, error) {
+	staticInfoByID := e.staticInfoByID()
+	service := [][2]string{
+		{"service.name", "rocm-monitor"},
+		{"service.version", otlpScopeVersion},
+		{"host.name", hostName},
+	}
+
+	host := newOTLPResourceBuilder(service...)
+	gpus := make(map[int]*otlpResourceBuilder)
+	var order []*otlpResourceBuilder
+	for i := range samples {
+		data := &samples[i]
+		timeUnixNano := strconv.FormatInt(data.Timestamp.UnixNano(), 10)
+
+		for _, gpu := range data.GPUs {
+			resource, ok := gpus[gpu.ID]
+			if !ok {
+				info := staticInfoByID[gpu.ID]
+				resource = newOTLPResourceBuilder(append(service,
+					[2]string{"hw.type", "gpu"},
+					[2]string{"hw.id", strconv.Itoa(gpu.ID)},
+					[2]string{"hw.model", info.ProductName},
+					[2]string{"hw.vendor", info.VendorName},
+					[2]string{"hw.serial_number", info.SerialNumber},
+					[2]string{"hw.gpu.bus_info", info.BusInfo},
+				)...)
+				gpus[gpu.ID] = resource
+				order = append(order, resource)
+			}
+
+			// Metrics missing from the sample are skipped rather than reported as 0
+			for _, metric := range otlpGPUMetrics {
+				if value, ok := metric.value(gpu); ok {
+					resource.add(metric.name, metric.description, metric.unit, value, timeUnixNano)
+				}
+			}
+		}
+
+		if data.CPUAvailable {
+			host.add("system.cpu.utilization", "System CPU utilization", "1", data.CPUUsage/100, timeUnixNano)
+		}
+		host.add("rocm.gpu.count", "Number of detected GPUs", "{gpu}", float64(len(data.GPUs)), timeUnixNano)
+	}
+
+	var request otlpMetricsRequest
+	for _, resource := range append(order, host) {
+		request.ResourceMetrics = append(request.ResourceMetrics, resource.resource)
+	}
+	return json.Marshal(request)
+}
+
+// OTLPConfig holds settings for the OTLP pusher
+type OTLPConfig struct {
+	Endpoint string            // Receiver base URL, or the full metrics URL if it has a path
+	Headers  map[string]string // Extra request headers, typically for authentication
+	PushConfig
+}
+
+// otlpTarget encodes and sends OTLP/HTTP metrics requests
+type otlpTarget struct {
+	url      string
+	headers  map[string]string
+	hostName string
+	exporter *Exporter
+	client   *http.Client
+}
+
+// NewOTLPWriter creates a pusher that exports every sample to an OTLP/HTTP receiver
+func NewOTLPWriter(exporter *Exporter, config OTLPConfig) (*Pusher, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP endpoint: %w", err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: scheme must be http or https", endpoint.Redacted())
+	}
+	// Like OTEL_EXPORTER_OTLP_ENDPOINT, a bare base URL gets the metrics path appended
+	if endpoint.Path == "" || endpoint.Path == "/" {
+		endpoint.Path = otlpMetricsPath
+	}
+
+	// Without a host name the attribute is left out
+	hostName, _ := os.Hostname()
+
+	target := &otlpTarget{
+		url:      endpoint.String(),
+		headers:  config.Headers,
+		hostName: hostName,
+		exporter: exporter,
+		client:   &http.Client{Timeout: pushTimeout},
+	}
+	return newPusher("OTLP export", exporter.collector, target, config.PushConfig)
+}
+
+// ParseOTLPHeaders parses headers in the OTEL_EXPORTER_OTLP_HEADERS format,
+// key1=value1,key2=value2 with percent-encoded values; a plus sign is kept as is, as
+// base64 tokens often contain one
+func ParseOTLPHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, encoded, found := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid OTLP header %q: expected key=value", pair)
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTLP header %q: %w", key, err)
+		}
+		headers[key] = decoded
+	}
+	return headers, nil
+}
+
+// Encode builds a gzip-compressed OTLP/JSON request
+func (t *otlpTarget) Encode(samples []RocmData) ([]byte, error) {
+	body, err := t.exporter.OTLPMetricsRequest(samples, t.hostName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OTLP request: %w", err)
+	}
+	return gzipBytes(body)
+}
+
+// Send POSTs one request to the metrics endpoint
+func (t *otlpTarget) Send(ctx context.Context, payload []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	return sendPushRequest(t.client, req)
+}
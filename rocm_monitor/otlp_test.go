@@ -0,0 +1,207 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseOTLPHeaders(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[string]string
+	}{
+		{"", map[string]string{}},
+		{"api-key=secret", map[string]string{"api-key": "secret"}},
+		{" a = 1 , b=2,, ", map[string]string{"a": "1", "b": "2"}},
+		{"Authorization=Bearer%20abc", map[string]string{"Authorization": "Bearer abc"}},
+		{"Authorization=Basic dXNlcjpw+YXNz==", map[string]string{"Authorization": "Basic dXNlcjpw+YXNz=="}},
+		{"x-token=a%2Cb%3Dc", map[string]string{"x-token": "a,b=c"}},
+		{"empty=", map[string]string{"empty": ""}},
+	}
+	for _, tt := range tests {
+		got, err := ParseOTLPHeaders(tt.value)
+		if err != nil {
+			t.Errorf("ParseOTLPHeaders(%q): %v", tt.value, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseOTLPHeaders(%q) = %v, want %v", tt.value, got, tt.want)
+			continue
+		}
+		for key, value := range tt.want {
+			if got[key] != value {
+				t.Errorf("ParseOTLPHeaders(%q)[%q] = %q, want %q", tt.value, key, got[key], value)
+			}
+		}
+	}
+
+	for _, value := range []string{"novalue", "=value", "a=1,b", "a=%zz"} {
+		if got, err := ParseOTLPHeaders(value); err == nil {
+			t.Errorf("ParseOTLPHeaders(%q) = %v, want an error", value, got)
+		}
+	}
+}
+
+// otlpJSON mirrors the OTLP/JSON request for decoding in tests, independently of the
+// writer's own types; 64-bit integers must arrive as strings
+type otlpJSON struct {
+	ResourceMetrics []struct {
+		Resource struct {
+			Attributes []struct {
+				Key   string `json:"key"`
+				Value struct {
+					StringValue string `json:"stringValue"`
+				} `json:"value"`
+			} `json:"attributes"`
+		} `json:"resource"`
+		ScopeMetrics []struct {
+			Scope struct {
+				Name string `json:"name"`
+			} `json:"scope"`
+			Metrics []struct {
+				Name  string `json:"name"`
+				Unit  string `json:"unit"`
+				Gauge struct {
+					DataPoints []struct {
+						TimeUnixNano string  `json:"timeUnixNano"`
+						AsDouble     float64 `json:"asDouble"`
+					} `json:"dataPoints"`
+				} `json:"gauge"`
+			} `json:"metrics"`
+		} `json:"scopeMetrics"`
+	} `json:"resourceMetrics"`
+}
+
+func TestOTLPMetricsRequest(t *testing.T) {
+	var body []byte
+	var path, encoding, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, encoding, auth = r.URL.Path, r.Header.Get("Content-Encoding"), r.Header.Get("Authorization")
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("request body: %v", err)
+			return
+		}
+		body, _ = io.ReadAll(zr)
+	}))
+	defer server.Close()
+
+	c := &Collector{
+		source:  &fakeSource{},
+		history: NewHistoryRing(10),
+		stats:   newCollectionStats(),
+		staticInfo: []GPUStaticInfo{
+			{ID: 0, ProductName: "Radeon RX 7900 XTX", VendorName: "AMD", SerialNumber: "Not Available", BusInfo: "0000:03:00.0"},
+		},
+	}
+	pusher, err := NewOTLPWriter(NewExporter(c, nil), OTLPConfig{Endpoint: server.URL, Headers: map[string]string{"Authorization": "Bearer abc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := pusher.target.(*otlpTarget)
+	target.hostName = "node1"
+
+	// The fan speed is not reported
+	gpu := GPU{ID: 0}
+	gpu.Set(FieldTemperature, 45)
+	gpu.Set(FieldPower, 100)
+	gpu.Set(FieldGPUUsage, 50)
+	gpu.Set(FieldVRAMUsage, 2)
+	gpu.Set(FieldVRAMTotal, 8)
+	gpu.Set(FieldSCLKFreq, 2000)
+	sample := RocmData{Timestamp: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), GPUs: []GPU{gpu}, CPUUsage: 25, CPUAvailable: true}
+
+	payload, err := target.Encode([]RocmData{sample})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := target.Send(context.Background(), payload); err != nil {
+		t.Fatal(err)
+	}
+	if path != otlpMetricsPath || encoding != "gzip" || auth != "Bearer abc" {
+		t.Errorf("request to %q with Content-Encoding %q, Authorization %q", path, encoding, auth)
+	}
+
+	var request otlpJSON
+	if err := json.Unmarshal(body, &request); err != nil {
+		t.Fatalf("%v\n%s", err, body)
+	}
+	if len(request.ResourceMetrics) != 2 {
+		t.Fatalf("got %d resources, want the GPU and the host", len(request.ResourceMetrics))
+	}
+
+	type point struct {
+		unit  string
+		value float64
+	}
+	tests := []struct {
+		attributes map[string]string
+		metrics    map[string]point
+	}{
+		{
+			// The serial number placeholder is left out
+			attributes: map[string]string{
+				"service.name": "rocm-monitor", "service.version": otlpScopeVersion, "host.name": "node1",
+				"hw.type": "gpu", "hw.id": "0", "hw.model": "Radeon RX 7900 XTX", "hw.vendor": "AMD", "hw.gpu.bus_info": "0000:03:00.0",
+			},
+			metrics: map[string]point{
+				"hw.temperature":            {"Cel", 45},
+				"hw.power":                  {"W", 100},
+				"hw.gpu.utilization":        {"1", 0.5},
+				"hw.gpu.memory.usage":       {"By", 2 << 30},
+				"hw.gpu.memory.limit":       {"By", 8 << 30},
+				"hw.gpu.memory.utilization": {"1", 0.25},
+				"rocm.gpu.sclk.frequency":   {"Hz", 2e9},
+			},
+		},
+		{
+			attributes: map[string]string{"service.name": "rocm-monitor", "service.version": otlpScopeVersion, "host.name": "node1"},
+			metrics: map[string]point{
+				"system.cpu.utilization": {"1", 0.25},
+				"rocm.gpu.count":         {"{gpu}", 1},
+			},
+		},
+	}
+	wantTime := strconv.FormatInt(sample.Timestamp.UnixNano(), 10)
+	for i, want := range tests {
+		resource := request.ResourceMetrics[i]
+		attributes := make(map[string]string)
+		for _, attribute := range resource.Resource.Attributes {
+			attributes[attribute.Key] = attribute.Value.StringValue
+		}
+		if len(attributes) != len(want.attributes) {
+			t.Errorf("resource %d attributes = %v, want %v", i, attributes, want.attributes)
+		}
+		for key, value := range want.attributes {
+			if attributes[key] != value {
+				t.Errorf("resource %d %s = %q, want %q", i, key, attributes[key], value)
+			}
+		}
+
+		if len(resource.ScopeMetrics) != 1 || resource.ScopeMetrics[0].Scope.Name != otlpScopeName {
+			t.Fatalf("resource %d scopes = %+v", i, resource.ScopeMetrics)
+		}
+		metrics := resource.ScopeMetrics[0].Metrics
+		if len(metrics) != len(want.metrics) {
+			t.Errorf("resource %d has %d metrics, want %d: missing fields must be skipped", i, len(metrics), len(want.metrics))
+		}
+		for _, metric := range metrics {
+			expected, ok := want.metrics[metric.Name]
+			if !ok {
+				t.Errorf("resource %d: unexpected metric %s", i, metric.Name)
+				continue
+			}
+			points := metric.Gauge.DataPoints
+			if metric.Unit != expected.unit || len(points) != 1 || points[0].AsDouble != expected.value || points[0].TimeUnixNano != wantTime {
+				t.Errorf("%s = %s %+v, want %v %s at %s", metric.Name, metric.Unit, points, expected.value, expected.unit, wantTime)
+			}
+		}
+	}
+}
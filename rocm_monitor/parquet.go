@@ -0,0 +1,517 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Parquet export. A file holds one row per sample and GPU with the columns of the CSV export:
+// Timestamp as a UTC timestamp in microseconds, GPU_ID as a 32-bit integer and one optional
+// double per metric, null where the metric was not reported. Rows are grouped by hour of
+// collection, and every column chunk carries min/max statistics so readers can skip row
+// groups outside a time range. Each column chunk is a single PLAIN-encoded, snappy-compressed
+// data page; the metadata is written with a minimal Thrift compact protocol encoder.
+const (
+	parquetMagic        = "PAR1"
+	parquetRowGroupSpan = time.Hour
+	parquetCreatedBy    = "rocm-monitor version 1.0.0"
+)
+
+// Values of the Parquet format enums used by the writer
+const (
+	parquetInt32  = 1 // Physical types
+	parquetInt64  = 2
+	parquetDouble = 5
+
+	parquetRequired = 0 // Field repetition types
+	parquetOptional = 1
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+	parquetCodecSnappy   = 1
+	parquetDataPage      = 0
+
+	parquetTimestampMicros = 10 // Converted types, for readers without logical types
+	parquetInt32Converted  = 17
+)
+
+// parquetColumn buffers the values of one column for the current row group
+type parquetColumn struct {
+	name     string
+	kind     int32 // Physical type
+	optional bool
+	values   []byte  // PLAIN-encoded values, nulls left out
+	levels   []uint8 // Definition levels of an optional column: 1 for a value, 0 for null
+	rows     int
+	nulls    int64
+
+	// Statistics of the row group; NaN is left out of min and max
+	hasStats         bool
+	minInt, maxInt   int64
+	minReal, maxReal float64
+}
+
+// addInt64 appends a value to an INT64 column
+func (c *parquetColumn) addInt64(v int64) {
+	c.values = binary.LittleEndian.AppendUint64(c.values, uint64(v))
+	c.rows++
+	if !c.hasStats || v < c.minInt {
+		c.minInt = v
+	}
+	if !c.hasStats || v > c.maxInt {
+		c.maxInt = v
+	}
+	c.hasStats = true
+}
+
+// addInt32 appends a value to an INT32 column
+func (c *parquetColumn) addInt32(v int32) {
+	c.values = binary.LittleEndian.AppendUint32(c.values, uint32(v))
+	c.rows++
+	if !c.hasStats || int64(v) < c.minInt {
+		c.minInt = int64(v)
+	}
+	if !c.hasStats || int64(v) > c.maxInt {
+		c.maxInt = int64(v)
+	}
+	c.hasStats = true
+}
+
+// addDouble appends a value, or a null if ok is false, to an optional DOUBLE column
+func (c *parquetColumn) addDouble(v float64, ok bool) {
+	c.rows++
+	if !ok {
+		c.levels = append(c.levels, 0)
+		c.nulls++
+		return
+	}
+	c.levels = append(c.levels, 1)
+	c.values = binary.LittleEndian.AppendUint64(c.values, math.Float64bits(v))
+	if math.IsNaN(v) {
+		return
+	}
+	if !c.hasStats || v < c.minReal {
+		c.minReal = v
+	}
+	if !c.hasStats || v > c.maxReal {
+		c.maxReal = v
+	}
+	c.hasStats = true
+}
+
+// stat returns a PLAIN-encoded statistics value
+func (c *parquetColumn) stat(i int64, f float64) []byte {
+	switch c.kind {
+	case parquetInt32:
+		return binary.LittleEndian.AppendUint32(nil, uint32(i))
+	case parquetInt64:
+		return binary.LittleEndian.AppendUint64(nil, uint64(i))
+	default:
+		return binary.LittleEndian.AppendUint64(nil, math.Float64bits(f))
+	}
+}
+
+// reset empties the column for the next row group
+func (c *parquetColumn) reset() {
+	c.values = c.values[:0]
+	c.levels = c.levels[:0]
+	c.rows = 0
+	c.nulls = 0
+	c.hasStats = false
+}
+
+// parquetWriter writes a Parquet file a row group at a time
+type parquetWriter struct {
+	out       *bufio.Writer
+	offset    int64
+	columns   []*parquetColumn
+	metrics   []csvColumn // Source of each column after Timestamp and GPU_ID
+	rows      int64       // Rows in the current row group
+	totalRows int64
+	rowGroups [][]byte // Encoded RowGroup metadata
+}
+
+// newParquetWriter creates a writer for the given metric columns
+func newParquetWriter(w io.Writer, metrics []csvColumn) *parquetWriter {
+	pw := &parquetWriter{
+		out:     bufio.NewWriter(w),
+		metrics: metrics,
+		columns: []*parquetColumn{
+			{name: "Timestamp", kind: parquetInt64},
+			{name: "GPU_ID", kind: parquetInt32},
+		},
+	}
+	for _, metric := range metrics {
+		pw.columns = append(pw.columns, &parquetColumn{name: metric.header, kind: parquetDouble, optional: true})
+	}
+	return pw
+}
+
+// writeRows appends one row per GPU of a sample
+func (pw *parquetWriter) writeRows(data *RocmData) {
+	timestamp := data.Timestamp.UnixNano() / int64(time.Microsecond)
+	for _, gpu := range data.GPUs {
+		pw.columns[0].addInt64(timestamp)
+		pw.columns[1].addInt32(int32(gpu.ID))
+		for i, metric := range pw.metrics {
+			column := pw.columns[i+2]
+			if metric.field == 0 {
+				column.addDouble(data.CPUUsage, data.CPUAvailable)
+			} else {
+				column.addDouble(gpu.Value(metric.field))
+			}
+		}
+		pw.rows++
+	}
+}
+
+// write writes raw bytes to the file, tracking the offset
+func (pw *parquetWriter) write(b []byte) error {
+	n, err := pw.out.Write(b)
+	pw.offset += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write Parquet: %w", err)
+	}
+	return nil
+}
+
+// flushRowGroup writes the buffered rows as a row group
+func (pw *parquetWriter) flushRowGroup() error {
+	if pw.rows == 0 {
+		return nil
+	}
+	if pw.offset == 0 {
+		if err := pw.write([]byte(parquetMagic)); err != nil {
+			return err
+		}
+	}
+
+	var group thriftWriter
+	group.begin()
+	group.listBegin(1, thriftStruct, len(pw.columns))
+	groupOffset := pw.offset
+	var totalSize, totalCompressed int64
+	for _, column := range pw.columns {
+		pageOffset := pw.offset
+		uncompressed, compressed, err := pw.writePage(column)
+		if err != nil {
+			return err
+		}
+		totalSize += uncompressed
+		totalCompressed += compressed
+		column.encodeChunk(&group, pageOffset, uncompressed, compressed)
+		column.reset()
+	}
+	group.i64(2, totalSize)
+	group.i64(3, pw.rows)
+	group.i64(5, groupOffset)
+	group.i64(6, totalCompressed)
+	group.end()
+
+	pw.rowGroups = append(pw.rowGroups, group.b)
+	pw.totalRows += pw.rows
+	pw.rows = 0
+	return nil
+}
+
+// writePage writes a column as one data page and returns its sizes including the header
+func (pw *parquetWriter) writePage(column *parquetColumn) (uncompressed, compressed int64, err error) {
+	var page []byte
+	if column.optional {
+		levels := appendParquetLevels(nil, column.levels)
+		page = binary.LittleEndian.AppendUint32(page, uint32(len(levels)))
+		page = append(page, levels...)
+	}
+	page = append(page, column.values...)
+	body := snappyEncode(page)
+
+	var header thriftWriter
+	header.begin()
+	header.i32(1, parquetDataPage)
+	header.i32(2, int32(len(page)))
+	header.i32(3, int32(len(body)))
+	header.structBegin(5)
+	header.i32(1, int32(column.rows))
+	header.i32(2, parquetEncodingPlain)
+	header.i32(3, parquetEncodingRLE)
+	header.i32(4, parquetEncodingRLE)
+	header.end()
+	header.end()
+
+	if err := pw.write(header.b); err != nil {
+		return 0, 0, err
+	}
+	if err := pw.write(body); err != nil {
+		return 0, 0, err
+	}
+	return int64(len(header.b) + len(page)), int64(len(header.b) + len(body)), nil
+}
+
+// encodeChunk appends the ColumnChunk metadata of the column's current row group
+func (c *parquetColumn) encodeChunk(t *thriftWriter, pageOffset, uncompressed, compressed int64) {
+	t.elemBegin()
+	t.i64(2, pageOffset)
+	t.structBegin(3)
+	t.i32(1, c.kind)
+	t.listBegin(2, thriftI32, 2)
+	t.elemI32(parquetEncodingPlain)
+	t.elemI32(parquetEncodingRLE)
+	t.listBegin(3, thriftBinary, 1)
+	t.elemString(c.name)
+	t.i32(4, parquetCodecSnappy)
+	t.i64(5, int64(c.rows))
+	t.i64(6, uncompressed)
+	t.i64(7, compressed)
+	t.i64(9, pageOffset)
+	t.structBegin(12)
+	t.i64(3, c.nulls)
+	if c.hasStats {
+		t.binary(5, c.stat(c.maxInt, c.maxReal))
+		t.binary(6, c.stat(c.minInt, c.minReal))
+	}
+	t.end()
+	t.end()
+	t.end()
+}
+
+// Close writes the last row group and the footer. A file without rows still gets a valid
+// schema.
+func (pw *parquetWriter) Close() error {
+	if err := pw.flushRowGroup(); err != nil {
+		return err
+	}
+	if pw.offset == 0 {
+		if err := pw.write([]byte(parquetMagic)); err != nil {
+			return err
+		}
+	}
+
+	var footer thriftWriter
+	footer.begin()
+	footer.i32(1, 1)
+	footer.listBegin(2, thriftStruct, len(pw.columns)+1)
+	footer.elemBegin()
+	footer.binary(4, []byte("schema"))
+	footer.i32(5, int32(len(pw.columns)))
+	footer.end()
+	for _, column := range pw.columns {
+		column.encodeSchema(&footer)
+	}
+	footer.i64(3, pw.totalRows)
+	footer.listBegin(4, thriftStruct, len(pw.rowGroups))
+	for _, group := range pw.rowGroups {
+		footer.b = append(footer.b, group...)
+	}
+	footer.binary(6, []byte(parquetCreatedBy))
+	// Type-defined sort order for every column, so readers trust min and max
+	footer.listBegin(7, thriftStruct, len(pw.columns))
+	for range pw.columns {
+		footer.elemBegin()
+		footer.structBegin(1)
+		footer.end()
+		footer.end()
+	}
+	footer.end()
+
+	if err := pw.write(footer.b); err != nil {
+		return err
+	}
+	trailer := binary.LittleEndian.AppendUint32(nil, uint32(len(footer.b)))
+	if err := pw.write(append(trailer, parquetMagic...)); err != nil {
+		return err
+	}
+	if err := pw.out.Flush(); err != nil {
+		return fmt.Errorf("failed to write Parquet: %w", err)
+	}
+	return nil
+}
+
+// encodeSchema appends the SchemaElement of the column
+func (c *parquetColumn) encodeSchema(t *thriftWriter) {
+	t.elemBegin()
+	t.i32(1, c.kind)
+	if c.optional {
+		t.i32(3, parquetOptional)
+	} else {
+		t.i32(3, parquetRequired)
+	}
+	t.binary(4, []byte(c.name))
+	switch c.kind {
+	case parquetInt64:
+		// TIMESTAMP(isAdjustedToUTC=true, unit=MICROS)
+		t.i32(6, parquetTimestampMicros)
+		t.structBegin(10)
+		t.structBegin(8)
+		t.boolean(1, true)
+		t.structBegin(2)
+		t.structBegin(2)
+		t.end()
+		t.end()
+		t.end()
+		t.end()
+	case parquetInt32:
+		// INTEGER(bitWidth=32, isSigned=true)
+		t.i32(6, parquetInt32Converted)
+		t.structBegin(10)
+		t.structBegin(10)
+		t.i8(1, 32)
+		t.boolean(2, true)
+		t.end()
+		t.end()
+	}
+	t.end()
+}
+
+// appendParquetLevels appends definition levels in the RLE/bit-packing hybrid encoding, as
+// one RLE run per stretch of equal levels
+func appendParquetLevels(b []byte, levels []uint8) []byte {
+	for i := 0; i < len(levels); {
+		j := i + 1
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		b = binary.AppendUvarint(b, uint64(j-i)<<1)
+		b = append(b, levels[i])
+		i = j
+	}
+	return b
+}
+
+// Thrift compact protocol type IDs
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs in the Thrift compact protocol. Calls mirror the nesting of the
+// structs: begin, structBegin or elemBegin open a struct and end closes it.
+type thriftWriter struct {
+	b    []byte
+	last []int16 // ID of the last field written, per open struct
+}
+
+// begin opens the top-level struct
+func (t *thriftWriter) begin() {
+	t.last = append(t.last, 0)
+}
+
+// end closes the innermost struct
+func (t *thriftWriter) end() {
+	t.b = append(t.b, 0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+// field writes a field header, using the short form when the ID delta allows it
+func (t *thriftWriter) field(id int16, kind byte) {
+	top := len(t.last) - 1
+	if delta := id - t.last[top]; delta > 0 && delta <= 15 {
+		t.b = append(t.b, byte(delta)<<4|kind)
+	} else {
+		t.b = append(t.b, kind)
+		t.b = binary.AppendVarint(t.b, int64(id))
+	}
+	t.last[top] = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.b = binary.AppendVarint(t.b, int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.b = binary.AppendVarint(t.b, v)
+}
+
+func (t *thriftWriter) i8(id int16, v int8) {
+	t.field(id, thriftByte)
+	t.b = append(t.b, byte(v))
+}
+
+func (t *thriftWriter) boolean(id int16, v bool) {
+	if v {
+		t.field(id, thriftTrue)
+	} else {
+		t.field(id, thriftFalse)
+	}
+}
+
+func (t *thriftWriter) binary(id int16, v []byte) {
+	t.field(id, thriftBinary)
+	t.b = binary.AppendUvarint(t.b, uint64(len(v)))
+	t.b = append(t.b, v...)
+}
+
+// structBegin opens a struct-valued field
+func (t *thriftWriter) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.last = append(t.last, 0)
+}
+
+// listBegin writes the header of a list field; its n elements follow
+func (t *thriftWriter) listBegin(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.b = append(t.b, byte(n)<<4|elem)
+	} else {
+		t.b = append(t.b, 0xf0|elem)
+		t.b = binary.AppendUvarint(t.b, uint64(n))
+	}
+}
+
+// elemBegin opens a struct element of a list
+func (t *thriftWriter) elemBegin() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) elemI32(v int32) {
+	t.b = binary.AppendVarint(t.b, int64(v))
+}
+
+func (t *thriftWriter) elemString(v string) {
+	t.b = binary.AppendUvarint(t.b, uint64(len(v)))
+	t.b = append(t.b, v...)
+}
+
+// ExportParquet writes the selected data history as a Parquet file with the columns of the
+// CSV export, one row group per hour of samples
+func (e *Exporter) ExportParquet(w io.Writer, filter ExportFilter) error {
+	if e.collector.HistoryLen() == 0 {
+		return fmt.Errorf("no data to export")
+	}
+
+	var metrics []csvColumn
+	for _, column := range csvColumns {
+		if filter.Selects(column.name()) {
+			metrics = append(metrics, column)
+		}
+	}
+
+	pw := newParquetWriter(w, metrics)
+	var chunk time.Time
+	err := e.eachSample(filter, func(data *RocmData) error {
+		start := data.Timestamp.Truncate(parquetRowGroupSpan)
+		if !start.Equal(chunk) {
+			if err := pw.flushRowGroup(); err != nil {
+				return err
+			}
+			chunk = start
+		}
+		pw.writeRows(data)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return pw.Close()
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// thriftStructValue is a decoded Thrift struct: field ID to an int64, bool, []byte,
+// []interface{} or nested thriftStructValue
+type thriftStructValue map[int16]interface{}
+
+func (s thriftStructValue) i64(id int16) int64 {
+	v, _ := s[id].(int64)
+	return v
+}
+
+func (s thriftStructValue) str(id int16) string {
+	v, _ := s[id].([]byte)
+	return string(v)
+}
+
+func (s thriftStructValue) child(id int16) thriftStructValue {
+	v, _ := s[id].(thriftStructValue)
+	return v
+}
+
+func (s thriftStructValue) list(id int16) []interface{} {
+	v, _ := s[id].([]interface{})
+	return v
+}
+
+// thriftReader decodes the Thrift compact protocol without a schema
+type thriftReader struct {
+	b   []byte
+	err error
+}
+
+func (r *thriftReader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf(format, args...)
+	}
+	r.b = nil
+}
+
+func (r *thriftReader) byte() byte {
+	if len(r.b) == 0 {
+		r.fail("unexpected end of data")
+		return 0
+	}
+	v := r.b[0]
+	r.b = r.b[1:]
+	return v
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.fail("bad varint")
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.fail("bad varint")
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+// readStruct reads fields up to the closing stop byte
+func (r *thriftReader) readStruct() thriftStructValue {
+	s := make(thriftStructValue)
+	var last int16
+	for r.err == nil {
+		header := r.byte()
+		if header == 0 {
+			return s
+		}
+		kind := header & 0x0f
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.varint())
+		}
+		last = id
+		switch kind {
+		case thriftTrue:
+			s[id] = true
+		case thriftFalse:
+			s[id] = false
+		default:
+			s[id] = r.readValue(kind)
+		}
+	}
+	return s
+}
+
+// readValue reads a value of a type outside a struct field header
+func (r *thriftReader) readValue(kind byte) interface{} {
+	switch kind {
+	case thriftTrue, thriftFalse:
+		return r.byte() == thriftTrue
+	case thriftByte:
+		return int64(int8(r.byte()))
+	case 4, thriftI32, thriftI64: // i16, i32, i64
+		return r.varint()
+	case 7: // double
+		if len(r.b) < 8 {
+			r.fail("short double")
+			return 0.0
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.b))
+		r.b = r.b[8:]
+		return v
+	case thriftBinary:
+		n := r.uvarint()
+		if uint64(len(r.b)) < n {
+			r.fail("short binary")
+			return []byte(nil)
+		}
+		v := r.b[:n]
+		r.b = r.b[n:]
+		return v
+	case thriftList:
+		header := r.byte()
+		n := uint64(header >> 4)
+		if n == 15 {
+			n = r.uvarint()
+		}
+		list := make([]interface{}, 0, n)
+		for i := uint64(0); i < n && r.err == nil; i++ {
+			list = append(list, r.readValue(header&0x0f))
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	r.fail("unsupported type %d", kind)
+	return nil
+}
+
+// parquetFile is a Parquet file split into its footer and, per row group and column, the
+// decoded values of its single data page
+type parquetFile struct {
+	meta   thriftStructValue
+	groups [][]parquetChunk
+}
+
+// parquetChunk is the content of a column chunk
+type parquetChunk struct {
+	meta   thriftStructValue // ColumnMetaData
+	levels []uint8           // Definition levels; nil for a required column
+	values []byte            // PLAIN-encoded values
+}
+
+// readParquet decodes a file written by parquetWriter
+func readParquet(file []byte) (*parquetFile, error) {
+	if len(file) < 12 || string(file[:4]) != parquetMagic || string(file[len(file)-4:]) != parquetMagic {
+		return nil, fmt.Errorf("missing magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	if footerLen > len(file)-12 {
+		return nil, fmt.Errorf("footer length %d out of range", footerLen)
+	}
+	r := &thriftReader{b: file[len(file)-8-footerLen : len(file)-8]}
+	result := &parquetFile{meta: r.readStruct()}
+	if r.err != nil || len(r.b) != 0 {
+		return nil, fmt.Errorf("footer: %v, %d bytes left", r.err, len(r.b))
+	}
+
+	optional := map[string]bool{}
+	for _, element := range result.meta.list(2)[1:] {
+		schema := element.(thriftStructValue)
+		optional[schema.str(4)] = schema.i64(3) == parquetOptional
+	}
+
+	for _, g := range result.meta.list(4) {
+		var chunks []parquetChunk
+		for _, c := range g.(thriftStructValue).list(1) {
+			meta := c.(thriftStructValue).child(3)
+			offset := meta.i64(9)
+			if offset <= 0 || offset >= int64(len(file)) {
+				return nil, fmt.Errorf("page offset %d out of range", offset)
+			}
+			r := &thriftReader{b: file[offset:]}
+			header := r.readStruct()
+			if r.err != nil {
+				return nil, fmt.Errorf("page header: %w", r.err)
+			}
+			compressed := header.i64(3)
+			if int64(len(r.b)) < compressed {
+				return nil, fmt.Errorf("page of %d bytes runs past the end", compressed)
+			}
+			page, err := snappyDecode(r.b[:compressed])
+			if err != nil {
+				return nil, fmt.Errorf("page: %w", err)
+			}
+			if int64(len(page)) != header.i64(2) {
+				return nil, fmt.Errorf("page decompressed to %d bytes, header says %d", len(page), header.i64(2))
+			}
+
+			rows := int(header.child(5).i64(1))
+			chunk := parquetChunk{meta: meta, values: page}
+			name := string(meta.list(3)[0].([]byte))
+			if optional[name] {
+				if len(page) < 4 || int(binary.LittleEndian.Uint32(page)) > len(page)-4 {
+					return nil, fmt.Errorf("%s: bad level length", name)
+				}
+				n := int(binary.LittleEndian.Uint32(page))
+				if chunk.levels, err = readParquetLevels(page[4:4+n], rows); err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+				chunk.values = page[4+n:]
+			}
+			chunks = append(chunks, chunk)
+		}
+		result.groups = append(result.groups, chunks)
+	}
+	return result, nil
+}
+
+// readParquetLevels decodes rows 1-bit definition levels in the RLE/bit-packing hybrid
+func readParquetLevels(b []byte, rows int) ([]uint8, error) {
+	var levels []uint8
+	for len(b) > 0 {
+		header, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("bad run header")
+		}
+		b = b[n:]
+		if header&1 == 0 {
+			if len(b) < 1 {
+				return nil, fmt.Errorf("short RLE run")
+			}
+			for i := uint64(0); i < header>>1; i++ {
+				levels = append(levels, b[0])
+			}
+			b = b[1:]
+			continue
+		}
+		groups := int(header >> 1)
+		if len(b) < groups {
+			return nil, fmt.Errorf("short bit-packed run")
+		}
+		for _, packed := range b[:groups] {
+			for bit := 0; bit < 8; bit++ {
+				levels = append(levels, packed>>bit&1)
+			}
+		}
+		b = b[groups:]
+	}
+	if len(levels) < rows {
+		return nil, fmt.Errorf("%d levels for %d rows", len(levels), rows)
+	}
+	return levels[:rows], nil
+}
+
+func TestExportParquet(t *testing.T) {
+	// Five samples over three hours; GPU 1 never reports power and the CPU usage is missing
+	// from one sample
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	offsets := []time.Duration{59 * time.Minute, 59*time.Minute + 30*time.Second, time.Hour, 90 * time.Minute, 3*time.Hour + 5*time.Minute}
+	c := &Collector{source: &fakeSource{}, history: NewHistoryRing(10), stats: newCollectionStats()}
+	for i, offset := range offsets {
+		gpu0 := GPU{ID: 0}
+		gpu0.Set(FieldTemperature, 40+float64(i))
+		gpu0.Set(FieldPower, 100+float64(i))
+		gpu1 := GPU{ID: 1}
+		gpu1.Set(FieldTemperature, 60-float64(i))
+		c.history.Push(RocmData{
+			Timestamp:    base.Add(offset),
+			GPUs:         []GPU{gpu0, gpu1},
+			CPUUsage:     float64(10 * i),
+			CPUAvailable: i != 2,
+		})
+	}
+
+	var buf bytes.Buffer
+	filter := ExportFilter{Fields: map[string]bool{"temperature": true, "power": true, queryMetricCPU: true}}
+	if err := NewExporter(c, nil).ExportParquet(&buf, filter); err != nil {
+		t.Fatal(err)
+	}
+	file, err := readParquet(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.meta.i64(1) != 1 || file.meta.i64(3) != 10 || file.meta.str(6) != parquetCreatedBy {
+		t.Errorf("file metadata: version %d, %d rows, created by %q", file.meta.i64(1), file.meta.i64(3), file.meta.str(6))
+	}
+
+	// Schema: the root, then Timestamp and GPU_ID with their logical types, then the metrics
+	schema := file.meta.list(2)
+	if len(schema) != 6 {
+		t.Fatalf("got %d schema elements, want 6", len(schema))
+	}
+	root := schema[0].(thriftStructValue)
+	if root.str(4) != "schema" || root.i64(5) != 5 {
+		t.Errorf("root = %v", root)
+	}
+	wantSchema := []struct {
+		name               string
+		kind, repetition   int64
+		converted          int64
+		logicalType, param int16
+	}{
+		{"Timestamp", parquetInt64, parquetRequired, parquetTimestampMicros, 8, 1},
+		{"GPU_ID", parquetInt32, parquetRequired, parquetInt32Converted, 10, 1},
+		{"Temperature_C", parquetDouble, parquetOptional, 0, 0, 0},
+		{"Power_W", parquetDouble, parquetOptional, 0, 0, 0},
+		{"CPU_Usage_%", parquetDouble, parquetOptional, 0, 0, 0},
+	}
+	for i, want := range wantSchema {
+		element := schema[i+1].(thriftStructValue)
+		if element.str(4) != want.name || element.i64(1) != want.kind || element.i64(3) != want.repetition || element.i64(6) != want.converted {
+			t.Errorf("schema element %d = %v, want %+v", i+1, element, want)
+		}
+		if want.logicalType == 0 {
+			if element[10] != nil {
+				t.Errorf("%s has logical type %v", want.name, element[10])
+			}
+			continue
+		}
+		if element.child(10).child(want.logicalType) == nil {
+			t.Errorf("%s logical type = %v, want union field %d", want.name, element[10], want.logicalType)
+		}
+	}
+	timestampType := schema[1].(thriftStructValue).child(10).child(8)
+	if timestampType[1] != true || timestampType.child(2).child(2) == nil {
+		t.Errorf("Timestamp logical type = %v, want TIMESTAMP(UTC, MICROS)", timestampType)
+	}
+	intType := schema[2].(thriftStructValue).child(10).child(10)
+	if intType.i64(1) != 32 || intType[2] != true {
+		t.Errorf("GPU_ID logical type = %v, want INT(32, signed)", intType)
+	}
+	if orders := file.meta.list(7); len(orders) != 5 {
+		t.Errorf("got %d column orders, want 5", len(orders))
+	}
+
+	// One row group per hour of collection, each column a single snappy page
+	wantGroups := []struct {
+		rows     int64
+		from, to time.Duration
+		levels   [3][]uint8 // Temperature, power and CPU definition levels
+	}{
+		{4, 59 * time.Minute, 59*time.Minute + 30*time.Second, [3][]uint8{{1, 1, 1, 1}, {1, 0, 1, 0}, {1, 1, 1, 1}}},
+		{4, time.Hour, 90 * time.Minute, [3][]uint8{{1, 1, 1, 1}, {1, 0, 1, 0}, {0, 0, 1, 1}}},
+		{2, 3*time.Hour + 5*time.Minute, 3*time.Hour + 5*time.Minute, [3][]uint8{{1, 1}, {1, 0}, {1, 1}}},
+	}
+	if len(file.groups) != len(wantGroups) {
+		t.Fatalf("got %d row groups, want %d", len(file.groups), len(wantGroups))
+	}
+	groups := file.meta.list(4)
+	for g, want := range wantGroups {
+		if rows := groups[g].(thriftStructValue).i64(3); rows != want.rows {
+			t.Errorf("row group %d has %d rows, want %d", g, rows, want.rows)
+		}
+		chunks := file.groups[g]
+		for _, chunk := range chunks {
+			if chunk.meta.i64(4) != parquetCodecSnappy || chunk.meta.i64(5) != want.rows {
+				t.Errorf("row group %d column %s: codec %d, %d values", g, chunk.meta.list(3)[0], chunk.meta.i64(4), chunk.meta.i64(5))
+			}
+		}
+
+		// Timestamp statistics bound the hour
+		stats := chunks[0].meta.child(12)
+		min := int64(binary.LittleEndian.Uint64(stats[6].([]byte)))
+		max := int64(binary.LittleEndian.Uint64(stats[5].([]byte)))
+		if min != base.Add(want.from).UnixMicro() || max != base.Add(want.to).UnixMicro() {
+			t.Errorf("row group %d spans %v to %v", g, time.UnixMicro(min).UTC(), time.UnixMicro(max).UTC())
+		}
+		if first := int64(binary.LittleEndian.Uint64(chunks[0].values)); first != min {
+			t.Errorf("row group %d starts at %v", g, time.UnixMicro(first).UTC())
+		}
+		if chunks[0].levels != nil || len(chunks[0].values) != int(want.rows)*8 || len(chunks[1].values) != int(want.rows)*4 {
+			t.Errorf("row group %d: required columns hold %d and %d bytes", g, len(chunks[0].values), len(chunks[1].values))
+		}
+
+		// Nulls only show up as definition levels and in the null count
+		for i, levels := range want.levels {
+			chunk := chunks[i+2]
+			if !bytes.Equal(chunk.levels, levels) {
+				t.Errorf("row group %d column %d levels = %v, want %v", g, i+2, chunk.levels, levels)
+			}
+			present := bytes.Count(levels, []byte{1})
+			if len(chunk.values) != present*8 || chunk.meta.child(12).i64(3) != int64(len(levels)-present) {
+				t.Errorf("row group %d column %d: %d value bytes, null count %d", g, i+2, len(chunk.values), chunk.meta.child(12).i64(3))
+			}
+		}
+	}
+
+	// Statistics of the optional columns leave the nulls out
+	power := file.groups[0][3].meta.child(12)
+	if min, max := math.Float64frombits(binary.LittleEndian.Uint64(power[6].([]byte))), math.Float64frombits(binary.LittleEndian.Uint64(power[5].([]byte))); min != 100 || max != 101 {
+		t.Errorf("power statistics %v to %v, want 100 to 101", min, max)
+	}
+	gpuIDs := file.groups[1][1].meta.child(12)
+	if min, max := int32(binary.LittleEndian.Uint32(gpuIDs[6].([]byte))), int32(binary.LittleEndian.Uint32(gpuIDs[5].([]byte))); min != 0 || max != 1 {
+		t.Errorf("GPU_ID statistics %d to %d, want 0 to 1", min, max)
+	}
+	if stats := file.groups[1][4].meta.child(12); stats[5] == nil || stats.i64(3) != 2 {
+		t.Errorf("CPU statistics = %v", stats)
+	}
+}
+
+func TestParquetWriterWithoutRows(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newParquetWriter(&buf, csvColumns[:1]).Close(); err != nil {
+		t.Fatal(err)
+	}
+	file, err := readParquet(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.groups) != 0 || file.meta.i64(3) != 0 || len(file.meta.list(2)) != 4 {
+		t.Errorf("empty file metadata = %v", file.meta)
+	}
+}
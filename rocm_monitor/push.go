@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Push exporters send collected samples to a metrics backend instead of waiting to be
+// scraped. A Pusher batches samples, encodes each batch as one request with its target and
+// queues it; requests that cannot be delivered wait in the queue, on disk if a directory is
+// configured, and are retried oldest first with exponential backoff.
+const (
+	pushMaxBatch     = 100 // Samples per request
+	pushBuffer       = 64  // Samples a pusher may fall behind collection
+	pushTimeout      = 30 * time.Second
+	pushStopTimeout  = 5 * time.Second // Time allowed on shutdown to deliver the queue
+	pushMinBackoff   = time.Second
+	pushMaxBackoff   = time.Minute
+	pushQueueExt     = ".req"
+	pushErrorPreview = 256 // Bytes of a rejection response kept for the log
+)
+
+// PushConfig holds the batching and queueing settings shared by the push exporters
+type PushConfig struct {
+	FlushInterval time.Duration // Longest time a sample waits before it is sent
+	QueueDir      string        // Directory for undelivered requests; empty keeps them in memory
+	MaxQueued     int           // Undelivered requests kept; the oldest are dropped beyond this
+}
+
+// pushTarget turns batches into requests for one backend and delivers them
+type pushTarget interface {
+	// Encode builds the request body for a batch of samples
+	Encode(samples []RocmData) ([]byte, error)
+	// Send delivers a request body and reports whether a failure is worth retrying
+	Send(ctx context.Context, payload []byte) (retry bool, err error)
+}
+
+// Pusher delivers every collected sample to a push target in the background
+type Pusher struct {
+	name      string // Used in log messages
+	config    PushConfig
+	collector *Collector
+	target    pushTarget
+	queue     *pushQueue
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// newPusher applies defaults and opens the queue
+func newPusher(name string, collector *Collector, target pushTarget, config PushConfig) (*Pusher, error) {
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 15 * time.Second
+	}
+	if config.MaxQueued <= 0 {
+		config.MaxQueued = 1000
+	}
+
+	queue, err := openPushQueue(config.QueueDir, config.MaxQueued)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return &Pusher{
+		name:      name,
+		config:    config,
+		collector: collector,
+		target:    target,
+		queue:     queue,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// Start begins pushing samples in the background; a pusher runs at most once, so calls after
+// the first or after Stop do nothing
+func (p *Pusher) Start() {
+	p.startOnce.Do(func() {
+		if n := p.queue.Len(); n > 0 {
+			log.Printf("%s: %d queued requests from a previous run", p.name, n)
+		}
+		go p.run()
+	})
+}
+
+// Stop queues the samples not yet sent and makes a last attempt to deliver the queue. It is
+// safe to call more than once, and before Start.
+func (p *Pusher) Stop() {
+	p.stopOnce.Do(func() { close(p.stop) })
+	// Never started: there is no loop to wait for, and none may start now
+	p.startOnce.Do(func() { close(p.done) })
+	<-p.done
+}
+
+// run batches samples and delivers queued requests until stopped
+func (p *Pusher) run() {
+	defer close(p.done)
+
+	sub := p.collector.Subscribe(pushBuffer)
+	defer func() { p.collector.Unsubscribe(sub) }()
+
+	flush := time.NewTicker(p.config.FlushInterval)
+	defer flush.Stop()
+
+	// ready is always receivable; it stands in for the backoff timer when a send is due
+	ready := make(chan time.Time)
+	close(ready)
+	var backoffTimer <-chan time.Time
+	var backoff time.Duration
+
+	var batch []RocmData
+	for {
+		send := backoffTimer
+		if send == nil && p.queue.Len() > 0 {
+			send = ready
+		}
+
+		select {
+		case <-p.stop:
+			p.enqueue(batch)
+			p.deliverQueue()
+			return
+		case data, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the samples in between are lost
+				log.Printf("%s: fell behind collection, samples were skipped", p.name)
+				sub = p.collector.Subscribe(pushBuffer)
+				continue
+			}
+			batch = append(batch, data)
+			if len(batch) >= pushMaxBatch {
+				p.enqueue(batch)
+				batch = nil
+			}
+		case <-flush.C:
+			p.enqueue(batch)
+			batch = nil
+		case <-send:
+			backoffTimer = nil
+			if err := p.sendOldest(context.Background()); err != nil {
+				backoff = nextPushBackoff(backoff)
+				log.Printf("%s failed, %d requests queued, retrying in %v: %v", p.name, p.queue.Len(), backoff, err)
+				backoffTimer = time.After(backoff)
+			} else if backoff > 0 {
+				log.Printf("%s recovered, %d requests queued", p.name, p.queue.Len())
+				backoff = 0
+			}
+		}
+	}
+}
+
+// nextPushBackoff doubles the retry delay up to the maximum
+func nextPushBackoff(backoff time.Duration) time.Duration {
+	if backoff < pushMinBackoff {
+		return pushMinBackoff
+	}
+	backoff *= 2
+	if backoff > pushMaxBackoff {
+		backoff = pushMaxBackoff
+	}
+	return backoff
+}
+
+// enqueue encodes a batch as one request and appends it to the queue
+func (p *Pusher) enqueue(batch []RocmData) {
+	if len(batch) == 0 {
+		return
+	}
+	payload, err := p.target.Encode(batch)
+	if err != nil {
+		log.Printf("%s: failed to encode %d samples: %v", p.name, len(batch), err)
+		return
+	}
+	dropped, err := p.queue.Push(payload)
+	if err != nil {
+		log.Printf("%s: failed to queue %d samples: %v", p.name, len(batch), err)
+	}
+	if dropped > 0 {
+		log.Printf("%s: queue full, dropped %d oldest requests", p.name, dropped)
+	}
+}
+
+// sendOldest delivers the request at the head of the queue. Requests the target rejects as
+// invalid are dropped, as retrying them cannot succeed; other failures leave them queued.
+func (p *Pusher) sendOldest(ctx context.Context) error {
+	payload, err := p.queue.Peek()
+	if err != nil {
+		log.Printf("%s: dropping unreadable queued request: %v", p.name, err)
+		return p.queue.Pop()
+	}
+
+	retry, err := p.target.Send(ctx, payload)
+	if err != nil && retry {
+		return err
+	}
+	if err != nil {
+		log.Printf("%s: request rejected, dropping it: %v", p.name, err)
+	}
+	return p.queue.Pop()
+}
+
+// deliverQueue sends queued requests on shutdown until the queue is empty, a send fails or
+// the stop timeout expires
+func (p *Pusher) deliverQueue() {
+	ctx, cancel := context.WithTimeout(context.Background(), pushStopTimeout)
+	defer cancel()
+
+	for p.queue.Len() > 0 {
+		if err := p.sendOldest(ctx); err != nil {
+			break
+		}
+	}
+	if n := p.queue.Len(); n > 0 {
+		if p.config.QueueDir != "" {
+			log.Printf("%s: %d requests left in %s", p.name, n, p.config.QueueDir)
+		} else {
+			log.Printf("%s: discarding %d undelivered requests", p.name, n)
+		}
+	}
+}
+
+// sendPushRequest performs a push request. Network errors, 5xx and 429 responses are worth
+// retrying; other error responses mean the request itself was rejected.
+func sendPushRequest(client *http.Client, req *http.Request) (retry bool, err error) {
+	req.Header.Set("User-Agent", "rocm-monitor/1.0.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, pushErrorPreview))
+	err = fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+}
+
+// gzipBytes compresses a request body for targets that accept Content-Encoding: gzip
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pushQueue holds encoded requests awaiting delivery, oldest first. On disk each request is
+// a file named by its sequence number, so the queue survives restarts. It is only used by the
+// pusher's goroutine.
+type pushQueue struct {
+	dir     string
+	max     int
+	memory  [][]byte // Requests when there is no directory
+	files   []uint64 // Sequence numbers of the queued files
+	nextSeq uint64
+}
+
+// openPushQueue creates the queue, picking up requests left in dir by a previous run
+func openPushQueue(dir string, max int) (*pushQueue, error) {
+	q := &pushQueue{dir: dir, max: max, nextSeq: 1}
+	if dir == "" {
+		return q, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, pushQueueExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, pushQueueExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		q.files = append(q.files, seq)
+	}
+	sort.Slice(q.files, func(i, j int) bool { return q.files[i] < q.files[j] })
+	if len(q.files) > 0 {
+		q.nextSeq = q.files[len(q.files)-1] + 1
+	}
+	return q, nil
+}
+
+// Len returns the number of queued requests
+func (q *pushQueue) Len() int {
+	if q.dir == "" {
+		return len(q.memory)
+	}
+	return len(q.files)
+}
+
+// Push appends a request, dropping the oldest ones beyond the limit; it returns how many
+// were dropped
+func (q *pushQueue) Push(payload []byte) (dropped int, err error) {
+	if q.dir == "" {
+		q.memory = append(q.memory, payload)
+	} else {
+		// Write under a temporary name so a crash never leaves a partial request behind
+		seq := q.nextSeq
+		path := q.path(seq)
+		if err := os.WriteFile(path+".tmp", payload, 0o644); err != nil {
+			return 0, fmt.Errorf("failed to write queued request: %w", err)
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return 0, fmt.Errorf("failed to write queued request: %w", err)
+		}
+		q.files = append(q.files, seq)
+		q.nextSeq++
+	}
+
+	for q.Len() > q.max {
+		if err := q.Pop(); err != nil {
+			return dropped, err
+		}
+		dropped++
+	}
+	return dropped, nil
+}
+
+// Peek returns the oldest request
+func (q *pushQueue) Peek() ([]byte, error) {
+	if q.dir == "" {
+		return q.memory[0], nil
+	}
+	return os.ReadFile(q.path(q.files[0]))
+}
+
+// Pop removes the oldest request
+func (q *pushQueue) Pop() error {
+	if q.dir == "" {
+		q.memory[0] = nil
+		q.memory = q.memory[1:]
+		return nil
+	}
+	seq := q.files[0]
+	q.files = q.files[1:]
+	if err := os.Remove(q.path(seq)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove queued request: %w", err)
+	}
+	return nil
+}
+
+// path returns the file name of a queued request
+func (q *pushQueue) path(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, pushQueueExt))
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubReceiver is a remote_write endpoint that answers with scripted status codes and
+// records the samples of every request, by their second offset from pushTestTime
+type stubReceiver struct {
+	t        *testing.T
+	mu       sync.Mutex
+	statuses []int   // Answers to the first requests; 200 after that
+	requests [][]int // Samples of every request received
+	accepted [][]int // Samples of the requests answered with 200
+}
+
+var pushTestTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func (s *stubReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.t.Errorf("reading request: %v", err)
+		return
+	}
+	request, err := snappyDecode(body)
+	if err != nil {
+		s.t.Errorf("request body: %v", err)
+		return
+	}
+	series, err := decodeWriteRequest(request)
+	if err != nil || len(series) == 0 {
+		s.t.Errorf("WriteRequest: %v, %d series", err, len(series))
+		return
+	}
+	var samples []int
+	for _, sample := range series[0].samples {
+		samples = append(samples, int(time.UnixMilli(sample.timestamp).Sub(pushTestTime)/time.Second))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := http.StatusOK
+	if n := len(s.requests); n < len(s.statuses) {
+		status = s.statuses[n]
+	}
+	s.requests = append(s.requests, samples)
+	if status == http.StatusOK {
+		s.accepted = append(s.accepted, samples)
+	}
+	w.WriteHeader(status)
+}
+
+// received returns copies of the requests and accepted requests so far
+func (s *stubReceiver) received() (requests, accepted [][]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]int(nil), s.requests...), append([][]int(nil), s.accepted...)
+}
+
+// startPusher runs a remote_write pusher against receiver and returns its collector
+func startPusher(t *testing.T, receiver *stubReceiver) (*Collector, *Pusher) {
+	t.Helper()
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	c := &Collector{source: &fakeSource{}, history: NewHistoryRing(10), stats: newCollectionStats()}
+	pusher, err := NewRemoteWriter(NewExporter(c, nil), RemoteWriteConfig{
+		URL:        server.URL,
+		PushConfig: PushConfig{FlushInterval: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pusher.Start()
+	waitFor(t, "the pusher to subscribe", func() bool {
+		live, _ := c.subscriberCounts()
+		return live == 1
+	})
+	return c, pusher
+}
+
+// publishPushSample hands the collector's subscribers the sample taken i seconds after
+// pushTestTime
+func publishPushSample(c *Collector, i int) {
+	c.publish(RocmData{Timestamp: pushTestTime.Add(time.Duration(i) * time.Second), GPUs: []GPU{fakeGPU(0, "")}})
+}
+
+// flatten concatenates the samples of several requests
+func flatten(requests [][]int) []int {
+	var all []int
+	for _, samples := range requests {
+		all = append(all, samples...)
+	}
+	return all
+}
+
+func TestPusherRetriesInOrder(t *testing.T) {
+	receiver := &stubReceiver{t: t, statuses: []int{http.StatusServiceUnavailable}}
+	c, pusher := startPusher(t, receiver)
+	defer pusher.Stop()
+
+	// Later samples queue up behind the failed request while it waits for its retry
+	for i := 0; i < 3; i++ {
+		publishPushSample(c, i)
+		time.Sleep(30 * time.Millisecond)
+	}
+	waitFor(t, "all samples to be accepted", func() bool {
+		_, accepted := receiver.received()
+		return len(flatten(accepted)) >= 3
+	})
+
+	requests, accepted := receiver.received()
+	if got := flatten(accepted); !equalInts(got, []int{0, 1, 2}) {
+		t.Errorf("accepted samples %v, want [0 1 2] once each and in order", got)
+	}
+	if len(requests) != len(accepted)+1 || !equalInts(requests[0], requests[1]) {
+		t.Errorf("requests %v, want the rejected first one sent again before the others", requests)
+	}
+}
+
+func TestPusherDropsRejectedRequests(t *testing.T) {
+	receiver := &stubReceiver{t: t, statuses: []int{http.StatusBadRequest}}
+	c, pusher := startPusher(t, receiver)
+	defer pusher.Stop()
+
+	publishPushSample(c, 0)
+	waitFor(t, "the first request", func() bool {
+		requests, _ := receiver.received()
+		return len(requests) == 1
+	})
+	publishPushSample(c, 1)
+	waitFor(t, "the second request", func() bool {
+		_, accepted := receiver.received()
+		return len(accepted) == 1
+	})
+
+	requests, accepted := receiver.received()
+	if len(requests) != 2 || !equalInts(requests[0], []int{0}) || !equalInts(flatten(accepted), []int{1}) {
+		t.Errorf("requests %v, accepted %v; want the rejected request dropped, not retried", requests, accepted)
+	}
+}
+
+func TestPusherStopIsIdempotent(t *testing.T) {
+	receiver := &stubReceiver{t: t}
+	_, pusher := startPusher(t, receiver)
+	pusher.Stop()
+	pusher.Stop()
+
+	// A pusher stopped before it started has nothing to wait for
+	c := &Collector{source: &fakeSource{}, history: NewHistoryRing(10), stats: newCollectionStats()}
+	idle, err := NewRemoteWriter(NewExporter(c, nil), RemoteWriteConfig{URL: "http://localhost:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle.Stop()
+	idle.Start()
+	idle.Stop()
+}
+
+func TestNextPushBackoff(t *testing.T) {
+	tests := []struct{ backoff, want time.Duration }{
+		{0, pushMinBackoff},
+		{pushMinBackoff, 2 * pushMinBackoff},
+		{40 * time.Second, pushMaxBackoff},
+		{pushMaxBackoff, pushMaxBackoff},
+	}
+	for _, tt := range tests {
+		if got := nextPushBackoff(tt.backoff); got != tt.want {
+			t.Errorf("nextPushBackoff(%v) = %v, want %v", tt.backoff, got, tt.want)
+		}
+	}
+}
+
+func TestPushQueueReopen(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openPushQueue(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, payload := range []string{"a", "b", "c"} {
+		if _, err := q.Push([]byte(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Pop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests of an earlier run, and an interrupted write to ignore
+	for name, payload := range map[string]string{"00000000000000000007.req": "x", "00000000000000000012.req": "y", "00000000000000000013.req.tmp": "partial"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(payload), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A new queue picks the requests up in sequence order and continues after the last one
+	reopened, err := openPushQueue(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Push([]byte("d")); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for reopened.Len() > 0 {
+		payload, err := reopened.Peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(payload))
+		if err := reopened.Pop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !equalStrings(got, []string{"b", "c", "x", "y", "d"}) {
+		t.Errorf("reopened queue held %q, want [b c x y d]", got)
+	}
+}
+
+func TestPushQueueMaxQueued(t *testing.T) {
+	for _, dir := range []string{"", t.TempDir()} {
+		q, err := openPushQueue(dir, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dropped := 0
+		for _, payload := range []string{"a", "b", "c", "d"} {
+			n, err := q.Push([]byte(payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dropped += n
+		}
+		payload, err := q.Peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.Len() != 2 || dropped != 2 || string(payload) != "c" {
+			t.Errorf("dir %q: %d queued, %d dropped, oldest %q; want 2, 2 and \"c\"", dir, q.Len(), dropped, payload)
+		}
+		if dir != "" {
+			if files, _ := filepath.Glob(filepath.Join(dir, "*"+pushQueueExt)); len(files) != 2 {
+				t.Errorf("%d request files left, want 2", len(files))
+			}
+		}
+	}
+}
+
+// equalInts reports whether two int slices hold the same elements in order
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
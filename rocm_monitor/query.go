@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Range queries read the in-memory history and return one series per GPU (or a single series
+// for cpu_usage) with one point per step that holds samples.
+const (
+	queryMetricCPU    = "cpu_usage"
+	queryDefaultRange = time.Hour
+	queryDefaultStep  = time.Minute
+	queryMaxPoints    = 11000 // Per series; smaller steps over long ranges are rejected
+)
+
+// queryAggregations maps the supported agg values to their functions; values are never empty
+var queryAggregations = map[string]func(values []float64) float64{
+	"avg": func(values []float64) float64 {
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	},
+	"min": func(values []float64) float64 {
+		result := values[0]
+		for _, v := range values[1:] {
+			if v < result {
+				result = v
+			}
+		}
+		return result
+	},
+	"max": func(values []float64) float64 {
+		result := values[0]
+		for _, v := range values[1:] {
+			if v > result {
+				result = v
+			}
+		}
+		return result
+	},
+	"p95": func(values []float64) float64 {
+		return percentile(values, 0.95)
+	},
+	"last": func(values []float64) float64 {
+		return values[len(values)-1]
+	},
+}
+
+// RangeQuery selects one metric over a time range, aggregated per step
+type RangeQuery struct {
+	Metric string
+	GPUs   []int // Empty selects every GPU; ignored for cpu_usage
+	Start  time.Time
+	End    time.Time
+	Step   time.Duration
+	Agg    string
+}
+
+// QueryPoint is a [unix seconds, value] pair; the timestamp is the start of the step
+type QueryPoint [2]float64
+
+// QuerySeries holds the points of one GPU, or of the CPU when GPU is nil
+type QuerySeries struct {
+	GPU    *int         `json:"gpu,omitempty"`
+	Points []QueryPoint `json:"points"`
+}
+
+// QueryResult is the response to a range query
+type QueryResult struct {
+	Metric string        `json:"metric"`
+	Agg    string        `json:"agg"`
+	Start  time.Time     `json:"start"`
+	End    time.Time     `json:"end"`
+	Step   float64       `json:"step_seconds"`
+	Series []QuerySeries `json:"series"`
+}
+
+// ParseRangeQuery reads a range query from URL parameters. start and end accept RFC 3339
+// times or Unix seconds and default to the hour before now.
+func ParseRangeQuery(params url.Values, now time.Time) (RangeQuery, error) {
+	q := RangeQuery{
+		Metric: params.Get("metric"),
+		End:    now,
+		Step:   queryDefaultStep,
+		Agg:    params.Get("agg"),
+	}
+
+	if q.Metric == "" {
+		return q, fmt.Errorf("metric is required")
+	}
+	if q.Metric != queryMetricCPU && gpuFieldByName(q.Metric) == 0 {
+		return q, fmt.Errorf("unknown metric %q", q.Metric)
+	}
+
+	if q.Agg == "" {
+		q.Agg = "avg"
+	}
+	if _, ok := queryAggregations[q.Agg]; !ok {
+		return q, fmt.Errorf("unknown aggregation %q: use avg, min, max, p95 or last", q.Agg)
+	}
+
+	var err error
+	if q.GPUs, err = parseGPUList(params.Get("gpu")); err != nil {
+		return q, err
+	}
+
+	if value := params.Get("end"); value != "" {
+		if q.End, err = parseQueryTime(value); err != nil {
+			return q, fmt.Errorf("invalid end: %w", err)
+		}
+	}
+	q.Start = q.End.Add(-queryDefaultRange)
+	if value := params.Get("start"); value != "" {
+		if q.Start, err = parseQueryTime(value); err != nil {
+			return q, fmt.Errorf("invalid start: %w", err)
+		}
+	}
+	if !q.End.After(q.Start) {
+		return q, fmt.Errorf("end must be after start")
+	}
+
+	if value := params.Get("step"); value != "" {
+		if q.Step, err = time.ParseDuration(value); err != nil {
+			return q, fmt.Errorf("invalid step: %w", err)
+		}
+		if q.Step <= 0 {
+			return q, fmt.Errorf("invalid step %v: must be positive", q.Step)
+		}
+	}
+	if q.End.Sub(q.Start)/q.Step > queryMaxPoints {
+		return q, fmt.Errorf("step %v too small for the range: at most %d points per series", q.Step, queryMaxPoints)
+	}
+
+	return q, nil
+}
+
+// parseQueryTime reads an RFC 3339 time or Unix seconds with an optional fraction
+func parseQueryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}, fmt.Errorf("%q is neither RFC 3339 nor Unix seconds", value)
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)), nil
+}
+
+// parseGPUList reads a comma-separated list of GPU IDs; an empty value returns nil, which
+// selects every GPU
+func parseGPUList(value string) ([]int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var ids []int
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gpu %q", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// gpuFieldByName returns the field with the given JSON name, or 0 if there is none
+func gpuFieldByName(name string) GPUField {
+	for _, info := range gpuFields {
+		if info.name == name {
+			return info.field
+		}
+	}
+	return 0
+}
+
+// percentile returns the nearest-rank percentile p (0-1] of values without modifying them
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// queryBucket collects the values of one series within one step
+type queryBucket struct {
+	step   int64
+	values []float64
+}
+
+// Query evaluates a range query over the in-memory history
+func (c *Collector) Query(q RangeQuery) QueryResult {
+	field := gpuFieldByName(q.Metric)
+	selected := make(map[int]bool, len(q.GPUs))
+	for _, id := range q.GPUs {
+		selected[id] = true
+	}
+
+	// Gather raw values per series and step under the read lock; aggregate after releasing it
+	const cpuSeries = -1
+	buckets := make(map[int][]queryBucket)
+	var order []int // Series keys in order of first appearance
+	add := func(series int, step int64, value float64) {
+		list, ok := buckets[series]
+		if !ok {
+			order = append(order, series)
+		}
+		if n := len(list); n > 0 && list[n-1].step == step {
+			list[n-1].values = append(list[n-1].values, value)
+		} else {
+			list = append(list, queryBucket{step: step, values: []float64{value}})
+		}
+		buckets[series] = list
+	}
+
+	c.ViewHistory(func(view HistoryView) {
+		view.RangeSince(q.Start.Add(-time.Nanosecond), func(data *RocmData) bool {
+			if data.Timestamp.After(q.End) {
+				return false
+			}
+			step := int64(data.Timestamp.Sub(q.Start) / q.Step)
+			if field == 0 {
+				if data.CPUAvailable {
+					add(cpuSeries, step, data.CPUUsage)
+				}
+				return true
+			}
+			for i := range data.GPUs {
+				gpu := &data.GPUs[i]
+				if len(selected) > 0 && !selected[gpu.ID] {
+					continue
+				}
+				if value, ok := gpu.Value(field); ok {
+					add(gpu.ID, step, value)
+				}
+			}
+			return true
+		})
+	})
+
+	result := QueryResult{
+		Metric: q.Metric,
+		Agg:    q.Agg,
+		Start:  q.Start,
+		End:    q.End,
+		Step:   q.Step.Seconds(),
+		Series: make([]QuerySeries, 0, len(order)),
+	}
+	aggregate := queryAggregations[q.Agg]
+	for _, key := range order {
+		series := QuerySeries{Points: make([]QueryPoint, 0, len(buckets[key]))}
+		if key != cpuSeries {
+			id := key
+			series.GPU = &id
+		}
+		for _, bucket := range buckets[key] {
+			timestamp := q.Start.Add(time.Duration(bucket.step) * q.Step)
+			series.Points = append(series.Points, QueryPoint{
+				float64(timestamp.UnixNano()) / 1e9,
+				aggregate(bucket.values),
+			})
+		}
+		result.Series = append(result.Series, series)
+	}
+	return result
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// queryTestStart is the time of the first sample of queryTestCollector, 1772366400 in Unix seconds
+var queryTestStart = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestParseRangeQuery(t *testing.T) {
+	now := queryTestStart.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		params string
+		want   RangeQuery
+		err    string
+	}{
+		{
+			name:   "defaults",
+			params: "metric=temperature",
+			want:   RangeQuery{Metric: "temperature", Start: queryTestStart, End: now, Step: time.Minute, Agg: "avg"},
+		},
+		{
+			name:   "explicit range",
+			params: "metric=cpu_usage&gpu=0,+2&start=2026-03-01T12:00:00Z&end=1772366460.5&step=30s&agg=p95",
+			want: RangeQuery{
+				Metric: "cpu_usage",
+				GPUs:   []int{0, 2},
+				Start:  queryTestStart,
+				End:    time.Unix(1772366460, 5e8),
+				Step:   30 * time.Second,
+				Agg:    "p95",
+			},
+		},
+		{
+			name:   "at the point limit",
+			params: "metric=power&start=1772355400&end=1772366400&step=1s",
+			want:   RangeQuery{Metric: "power", Start: time.Unix(1772355400, 0), End: time.Unix(1772366400, 0), Step: time.Second, Agg: "avg"},
+		},
+		{name: "over the point limit", params: "metric=power&start=1772355400&end=1772366400&step=999ms", err: "too small"},
+		{name: "no metric", params: "agg=max", err: "metric is required"},
+		{name: "unknown metric", params: "metric=voltage", err: "unknown metric"},
+		{name: "unknown aggregation", params: "metric=power&agg=median", err: "unknown aggregation"},
+		{name: "invalid gpu", params: "metric=power&gpu=0,x", err: "invalid gpu"},
+		{name: "invalid end", params: "metric=power&end=yesterday", err: "invalid end"},
+		{name: "end before start", params: "metric=power&start=1772366400&end=1772366300", err: "end must be after start"},
+		{name: "zero step", params: "metric=power&step=0s", err: "must be positive"},
+		{name: "invalid step", params: "metric=power&step=10", err: "invalid step"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := url.ParseQuery(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseRangeQuery(params, now)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Start.Equal(tt.want.Start) || !got.End.Equal(tt.want.End) {
+				t.Errorf("range %v to %v, want %v to %v", got.Start, got.End, tt.want.Start, tt.want.End)
+			}
+			got.Start, got.End = tt.want.Start, tt.want.End
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// queryTestCollector holds a sample every 3 seconds for two minutes and one at the end of
+// them: GPU 0 reads i degrees and GPU 1 100+i, and the CPU usage i is only available for
+// even samples
+func queryTestCollector() *Collector {
+	c := &Collector{source: &fakeSource{}, history: NewHistoryRing(100), stats: newCollectionStats()}
+	for i := 0; i <= 40; i++ {
+		gpu0, gpu1 := GPU{ID: 0}, GPU{ID: 1}
+		gpu0.Set(FieldTemperature, float64(i))
+		gpu1.Set(FieldTemperature, float64(100+i))
+		c.history.Push(RocmData{
+			Timestamp:    queryTestStart.Add(time.Duration(3*i) * time.Second),
+			GPUs:         []GPU{gpu0, gpu1},
+			CPUUsage:     float64(i),
+			CPUAvailable: i%2 == 0,
+		})
+	}
+	return c
+}
+
+// querySeries is the form of a QuerySeries compared by TestCollectorQuery; gpu is -1 for the CPU
+type querySeries struct {
+	gpu    int
+	points []QueryPoint
+}
+
+func TestCollectorQuery(t *testing.T) {
+	c := queryTestCollector()
+	start := float64(queryTestStart.Unix())
+
+	tests := []struct {
+		name  string
+		query RangeQuery
+		want  []querySeries
+	}{
+		{
+			name:  "average per minute",
+			query: RangeQuery{Metric: "temperature", Start: queryTestStart, End: queryTestStart.Add(2 * time.Minute), Step: time.Minute, Agg: "avg"},
+			want: []querySeries{
+				{0, []QueryPoint{{start, 9.5}, {start + 60, 29.5}, {start + 120, 40}}},
+				{1, []QueryPoint{{start, 109.5}, {start + 60, 129.5}, {start + 120, 140}}},
+			},
+		},
+		{
+			name:  "p95 of one GPU",
+			query: RangeQuery{Metric: "temperature", GPUs: []int{1}, Start: queryTestStart, End: queryTestStart.Add(2 * time.Minute), Step: time.Minute, Agg: "p95"},
+			want:  []querySeries{{1, []QueryPoint{{start, 118}, {start + 60, 138}, {start + 120, 140}}}},
+		},
+		{
+			name:  "last per minute",
+			query: RangeQuery{Metric: "temperature", GPUs: []int{0}, Start: queryTestStart, End: queryTestStart.Add(119 * time.Second), Step: time.Minute, Agg: "last"},
+			want:  []querySeries{{0, []QueryPoint{{start, 19}, {start + 60, 39}}}},
+		},
+		{
+			name:  "steps start at the range start",
+			query: RangeQuery{Metric: "temperature", GPUs: []int{0}, Start: queryTestStart.Add(10 * time.Second), End: queryTestStart.Add(55 * time.Second), Step: 15 * time.Second, Agg: "min"},
+			want:  []querySeries{{0, []QueryPoint{{start + 10, 4}, {start + 25, 9}, {start + 40, 14}}}},
+		},
+		{
+			name:  "CPU usage skips unavailable samples and ignores gpu",
+			query: RangeQuery{Metric: queryMetricCPU, GPUs: []int{1}, Start: queryTestStart.Add(30 * time.Second), End: queryTestStart.Add(89 * time.Second), Step: 30 * time.Second, Agg: "max"},
+			want:  []querySeries{{-1, []QueryPoint{{start + 30, 18}, {start + 60, 28}}}},
+		},
+		{
+			name:  "unknown GPU",
+			query: RangeQuery{Metric: "temperature", GPUs: []int{7}, Start: queryTestStart, End: queryTestStart.Add(time.Minute), Step: time.Minute, Agg: "avg"},
+		},
+		{
+			name:  "before the history",
+			query: RangeQuery{Metric: "temperature", Start: queryTestStart.Add(-time.Hour), End: queryTestStart.Add(-time.Second), Step: time.Minute, Agg: "avg"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := c.Query(tt.query)
+			if result.Metric != tt.query.Metric || result.Agg != tt.query.Agg || result.Step != tt.query.Step.Seconds() {
+				t.Errorf("result describes %s, %s, step %v", result.Metric, result.Agg, result.Step)
+			}
+			if result.Series == nil {
+				t.Error("series is nil, want an empty list")
+			}
+			var got []querySeries
+			for _, series := range result.Series {
+				gpu := -1
+				if series.GPU != nil {
+					gpu = *series.GPU
+				}
+				got = append(got, querySeries{gpu, series.Points})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
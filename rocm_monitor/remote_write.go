@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// Prometheus remote_write push mode (protocol 1.0). Each batch of samples is converted to the
+// gauges served on /metrics and sent as a snappy-compressed protobuf WriteRequest.
+
+// RemoteWriteConfig holds settings for the remote_write pusher
+type RemoteWriteConfig struct {
+	URL string // Receiver endpoint; user info in the URL is sent as basic auth
+	PushConfig
+}
+
+// remoteWriteTarget encodes and sends remote_write requests
+type remoteWriteTarget struct {
+	url      string
+	exporter *Exporter
+	client   *http.Client
+}
+
+// NewRemoteWriter creates a pusher that sends every sample to a remote_write receiver
+func NewRemoteWriter(exporter *Exporter, config RemoteWriteConfig) (*Pusher, error) {
+	endpoint, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote write URL: %w", err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("invalid remote write URL %q: scheme must be http or https", endpoint.Redacted())
+	}
+
+	target := &remoteWriteTarget{
+		url:      config.URL,
+		exporter: exporter,
+		client:   &http.Client{Timeout: pushTimeout},
+	}
+	return newPusher("Remote write", exporter.collector, target, config.PushConfig)
+}
+
+// Encode builds a compressed WriteRequest
+func (t *remoteWriteTarget) Encode(samples []RocmData) ([]byte, error) {
+	return snappyEncode(t.exporter.RemoteWriteRequest(samples)), nil
+}
+
+// Send POSTs one WriteRequest
+func (t *remoteWriteTarget) Send(ctx context.Context, payload []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	return sendPushRequest(t.client, req)
+}
+
+// remoteSeries is one time series of a WriteRequest
+type remoteSeries struct {
+	labels  []Label // Sorted by name, starting with __name__
+	samples []remoteSample
+}
+
+// remoteSample is a value at a Unix time in milliseconds
+type remoteSample struct {
+	value     float64
+	timestamp int64
+}
+
+// RemoteWriteRequest encodes samples as an uncompressed remote_write WriteRequest holding the
+// per-sample gauges of /metrics. Each series carries its values from all the samples.
+func (e *Exporter) RemoteWriteRequest(samples []RocmData) []byte {
+	staticInfoByID := e.staticInfoByID()
+
+	var series []*remoteSeries
+	index := make(map[string]*remoteSeries)
+	for i := range samples {
+		metrics := NewMetricSet(samples[i].Timestamp)
+		addSampleMetrics(metrics, &samples[i], staticInfoByID)
+		timestamp := samples[i].Timestamp.UnixMilli()
+
+		for _, family := range metrics.Families() {
+			for _, sample := range family.Samples {
+				// Empty labels are the same as missing ones to Prometheus
+				labels := []Label{{"__name__", family.Name + sample.Suffix}}
+				for _, label := range sample.Labels {
+					if label.Value != "" {
+						labels = append(labels, label)
+					}
+				}
+				sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+
+				key := labelKey(labels, "")
+				s, ok := index[key]
+				if !ok {
+					s = &remoteSeries{labels: labels}
+					index[key] = s
+					series = append(series, s)
+				}
+				s.samples = append(s.samples, remoteSample{value: sample.Value, timestamp: timestamp})
+			}
+		}
+	}
+
+	// message WriteRequest { repeated TimeSeries timeseries = 1; }
+	// message TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+	// message Label        { string name = 1; string value = 2; }
+	// message Sample       { double value = 1; int64 timestamp = 2; }
+	var request, timeSeries, message []byte
+	for _, s := range series {
+		timeSeries = timeSeries[:0]
+		for _, label := range s.labels {
+			message = appendProtoString(message[:0], 1, label.Name)
+			message = appendProtoString(message, 2, label.Value)
+			timeSeries = appendProtoBytes(timeSeries, 1, message)
+		}
+		for _, sample := range s.samples {
+			message = appendProtoDouble(message[:0], 1, sample.value)
+			message = appendProtoVarint(message, 2, uint64(sample.timestamp))
+			timeSeries = appendProtoBytes(timeSeries, 2, message)
+		}
+		request = appendProtoBytes(request, 1, timeSeries)
+	}
+	return request
+}
+
+// Protocol buffer wire types
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+// appendProtoTag appends a field key
+func appendProtoTag(b []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wireType))
+}
+
+// appendProtoVarint appends an integer field; negative int64 values are passed as uint64
+func appendProtoVarint(b []byte, field int, value uint64) []byte {
+	b = appendProtoTag(b, field, protoVarint)
+	return binary.AppendUvarint(b, value)
+}
+
+// appendProtoDouble appends a double field
+func appendProtoDouble(b []byte, field int, value float64) []byte {
+	b = appendProtoTag(b, field, protoFixed64)
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(value))
+}
+
+// appendProtoBytes appends a bytes or embedded message field
+func appendProtoBytes(b []byte, field int, value []byte) []byte {
+	b = appendProtoTag(b, field, protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// appendProtoString appends a string field
+func appendProtoString(b []byte, field int, value string) []byte {
+	b = appendProtoTag(b, field, protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
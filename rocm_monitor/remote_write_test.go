@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// protoField is one field of an encoded protobuf message
+type protoField struct {
+	number   int
+	wireType int
+	bytes    []byte // Length-delimited payload
+	value    uint64 // Varint or fixed64 payload
+}
+
+// decodeProto splits a message into its fields in wire order
+func decodeProto(b []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("bad field key")
+		}
+		b = b[n:]
+		field := protoField{number: int(key >> 3), wireType: int(key & 7)}
+		switch field.wireType {
+		case protoVarint:
+			field.value, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, fmt.Errorf("bad varint in field %d", field.number)
+			}
+			b = b[n:]
+		case protoFixed64:
+			if len(b) < 8 {
+				return nil, fmt.Errorf("short fixed64 in field %d", field.number)
+			}
+			field.value = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case protoBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return nil, fmt.Errorf("bad length in field %d", field.number)
+			}
+			field.bytes = b[n : n+int(length)]
+			b = b[n+int(length):]
+		default:
+			return nil, fmt.Errorf("unexpected wire type %d in field %d", field.wireType, field.number)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// decodedSeries is a TimeSeries read back from a WriteRequest
+type decodedSeries struct {
+	labels  []Label
+	samples []remoteSample
+}
+
+// decodeWriteRequest parses a WriteRequest, failing on any field outside the remote_write
+// 1.0 layout or out of the order it is written in
+func decodeWriteRequest(b []byte) ([]decodedSeries, error) {
+	request, err := decodeProto(b)
+	if err != nil {
+		return nil, err
+	}
+	var result []decodedSeries
+	for _, ts := range request {
+		if ts.number != 1 || ts.wireType != protoBytes {
+			return nil, fmt.Errorf("WriteRequest field %d/%d, want timeseries 1", ts.number, ts.wireType)
+		}
+		fields, err := decodeProto(ts.bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		var s decodedSeries
+		for _, field := range fields {
+			message, err := decodeProto(field.bytes)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case field.number == 1 && field.wireType == protoBytes && len(s.samples) == 0:
+				if len(message) != 2 || message[0].number != 1 || message[0].wireType != protoBytes ||
+					message[1].number != 2 || message[1].wireType != protoBytes {
+					return nil, fmt.Errorf("label %+v, want name 1 and value 2", message)
+				}
+				s.labels = append(s.labels, Label{string(message[0].bytes), string(message[1].bytes)})
+			case field.number == 2 && field.wireType == protoBytes:
+				if len(message) != 2 || message[0].number != 1 || message[0].wireType != protoFixed64 ||
+					message[1].number != 2 || message[1].wireType != protoVarint {
+					return nil, fmt.Errorf("sample %+v, want double value 1 and int64 timestamp 2", message)
+				}
+				s.samples = append(s.samples, remoteSample{math.Float64frombits(message[0].value), int64(message[1].value)})
+			default:
+				return nil, fmt.Errorf("TimeSeries field %d/%d out of place", field.number, field.wireType)
+			}
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// remoteWriteExporter returns an Exporter over two samples of two GPUs, the second GPU
+// without a serial number
+func remoteWriteExporter() (*Exporter, []RocmData) {
+	c := &Collector{
+		source:  &fakeSource{},
+		history: NewHistoryRing(10),
+		stats:   newCollectionStats(),
+		staticInfo: []GPUStaticInfo{
+			{ID: 0, ProductName: "Radeon RX 7900 XTX", VendorName: "AMD", SerialNumber: "SN0", VRAMVendor: "samsung"},
+			{ID: 1, ProductName: "Phoenix1", VendorName: "AMD"},
+		},
+	}
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var samples []RocmData
+	for i := 0; i < 2; i++ {
+		gpu0 := GPU{ID: 0}
+		gpu0.Set(FieldTemperature, 40+float64(i))
+		gpu0.Set(FieldPower, 120.5)
+		gpu1 := GPU{ID: 1}
+		gpu1.Set(FieldTemperature, 50)
+		samples = append(samples, RocmData{
+			Timestamp:    start.Add(time.Duration(i) * time.Second),
+			GPUs:         []GPU{gpu0, gpu1},
+			CPUUsage:     12.5,
+			CPUAvailable: true,
+		})
+	}
+	return NewExporter(c, nil), samples
+}
+
+func TestRemoteWriteRequestGolden(t *testing.T) {
+	exporter, samples := remoteWriteExporter()
+	request := exporter.RemoteWriteRequest(samples)
+
+	path := filepath.Join("testdata", "remote-write", "request.pb")
+	if *updateGolden {
+		if err := os.WriteFile(path, request, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if golden := readFixture(t, "remote-write", "request.pb"); !bytes.Equal(request, golden) {
+		t.Errorf("request differs from %s (rerun with -update to accept)", path)
+	}
+
+	series, err := decodeWriteRequest(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string][]decodedSeries)
+	for _, s := range series {
+		// Labels are sorted by name, so __name__ leads and the rest follow in byte order
+		if len(s.labels) == 0 || s.labels[0].Name != "__name__" {
+			t.Fatalf("series %v does not start with __name__", s.labels)
+		}
+		for i, label := range s.labels {
+			if i > 0 && s.labels[i-1].Name >= label.Name {
+				t.Errorf("series %v: labels out of order", s.labels)
+			}
+			if label.Value == "" {
+				t.Errorf("series %v: empty %s label sent", s.labels, label.Name)
+			}
+		}
+		if len(s.samples) != len(samples) {
+			t.Errorf("series %v has %d samples, want %d", s.labels, len(s.samples), len(samples))
+		}
+		byName[s.labels[0].Value] = append(byName[s.labels[0].Value], s)
+	}
+
+	temperature := byName["rocm_gpu_temperature_celsius"]
+	if len(temperature) != 2 {
+		t.Fatalf("got %d temperature series, want 2", len(temperature))
+	}
+	want := []Label{
+		{"__name__", "rocm_gpu_temperature_celsius"},
+		{"gpu_id", "0"},
+		{"product_name", "Radeon RX 7900 XTX"},
+		{"serial_number", "SN0"},
+		{"vendor", "AMD"},
+		{"vram_vendor", "samsung"},
+	}
+	if fmt.Sprint(temperature[0].labels) != fmt.Sprint(want) {
+		t.Errorf("GPU 0 labels = %v, want %v", temperature[0].labels, want)
+	}
+	if len(temperature[1].labels) != 4 {
+		t.Errorf("GPU 1 labels = %v, want the empty ones dropped", temperature[1].labels)
+	}
+	for i, sample := range temperature[0].samples {
+		if sample.value != 40+float64(i) || sample.timestamp != samples[i].Timestamp.UnixMilli() {
+			t.Errorf("GPU 0 sample %d = %+v", i, sample)
+		}
+	}
+	if cpu := byName["rocm_system_cpu_usage_percent"]; len(cpu) != 1 || len(cpu[0].labels) != 1 || cpu[0].samples[0].value != 12.5 {
+		t.Errorf("CPU series = %+v", cpu)
+	}
+	if len(byName["rocm_gpu_power_watts"]) != 1 {
+		t.Errorf("power series = %+v, want GPU 0 only", byName["rocm_gpu_power_watts"])
+	}
+
+	// What goes on the wire is the same request, compressed
+	target := &remoteWriteTarget{exporter: exporter}
+	payload, err := target.Encode(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err := snappyDecode(payload); err != nil || !bytes.Equal(decoded, request) {
+		t.Errorf("payload does not decode to the request: %v", err)
+	}
+}
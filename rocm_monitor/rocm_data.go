@@ -2,10 +2,11 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -13,16 +14,36 @@ import (
 
 // GPU represents a single GPU device
 type GPU struct {
-	ID          int     `json:"id"`
-	Name        string  `json:"name"`
-	Temperature float64 `json:"temperature"`
-	Power       float64 `json:"power"`
-	VRAMUsage   float64 `json:"vram_usage"`
-	VRAMTotal   float64 `json:"vram_total"`
-	GPUUsage    float64 `json:"gpu_usage"`
-	FanSpeed    float64 `json:"fan_speed"`
-	SCLKFreq    float64 `json:"sclk_freq"`    // System Clock MHz
-	MCLKFreq    float64 `json:"mclk_freq"`    // Memory Clock MHz
+	ID                 int      `json:"id"`
+	Name               string   `json:"name"`
+	UniqueID           string   `json:"-"` // Stable device identifier, if the source reports one; not exported
+	Temperature        float64  `json:"temperature"`
+	Power              float64  `json:"power"`
+	VRAMUsage          float64  `json:"vram_usage"`
+	VRAMTotal          float64  `json:"vram_total"`
+	VisVRAMUsage       float64  `json:"vis_vram_usage"`       // CPU-visible VRAM used, GB
+	VisVRAMTotal       float64  `json:"vis_vram_total"`       // CPU-visible VRAM size, GB
+	GTTUsage           float64  `json:"gtt_usage"`            // GTT (system memory mapped to the GPU) used, GB
+	GTTTotal           float64  `json:"gtt_total"`            // GTT size, GB
+	AccessibleMemUsage float64  `json:"accessible_mem_usage"` // VRAM + GTT used, GB
+	AccessibleMemTotal float64  `json:"accessible_mem_total"` // VRAM + GTT size, GB
+	GPUUsage           float64  `json:"gpu_usage"`
+	FanSpeed           float64  `json:"fan_speed"`
+	SCLKFreq           float64  `json:"sclk_freq"` // System Clock MHz
+	MCLKFreq           float64  `json:"mclk_freq"` // Memory Clock MHz
+	Available          GPUField `json:"-"`         // Metrics reported in this sample, see Has
+}
+
+// UpdateAccessibleMemory derives the combined GPU-accessible memory from VRAM and GTT.
+// On APUs like Strix Halo most allocations live in GTT, so VRAM alone understates usage.
+// The sums are only available when both of their parts were reported.
+func (g *GPU) UpdateAccessibleMemory() {
+	if g.Has(FieldVRAMUsage) && g.Has(FieldGTTUsage) {
+		g.Set(FieldAccessibleMemUsage, g.VRAMUsage+g.GTTUsage)
+	}
+	if g.Has(FieldVRAMTotal) && g.Has(FieldGTTTotal) {
+		g.Set(FieldAccessibleMemTotal, g.VRAMTotal+g.GTTTotal)
+	}
 }
 
 // GPUStaticInfo holds static GPU information
@@ -39,9 +60,11 @@ type GPUStaticInfo struct {
 
 // RocmData represents a monitoring snapshot
 type RocmData struct {
-	Timestamp time.Time `json:"timestamp"`
-	GPUs      []GPU     `json:"gpus"`
-	CPUUsage  float64   `json:"cpu_usage"`
+	Timestamp    time.Time `json:"timestamp"`
+	GPUs         []GPU     `json:"gpus"`
+	CPUUsage     float64   `json:"cpu_usage"`
+	CPUAvailable bool      `json:"-"`                     // False when CPU usage could not be measured
+	Unavailable  []string  `json:"unavailable,omitempty"` // Metrics whose query failed for this sample
 }
 
 // Parser handles rocm-smi output parsing
@@ -55,6 +78,10 @@ type Parser struct {
 	deviceIDRegex    *regexp.Regexp
 	vramTotalRegex   *regexp.Regexp
 	vramUsedRegex    *regexp.Regexp
+	visTotalRegex    *regexp.Regexp
+	visUsedRegex     *regexp.Regexp
+	gttTotalRegex    *regexp.Regexp
+	gttUsedRegex     *regexp.Regexp
 	sclkRegex        *regexp.Regexp
 	mclkRegex        *regexp.Regexp
 }
@@ -70,6 +97,10 @@ func NewParser() *Parser {
 		deviceIDRegex:    regexp.MustCompile(`^(\d+)\s+`),                      // Device ID at start of line
 		vramTotalRegex:   regexp.MustCompile(`GPU\[(\d+)\]\s*:\s*VRAM Total Memory \(B\):\s*(\d+)`),
 		vramUsedRegex:    regexp.MustCompile(`GPU\[(\d+)\]\s*:\s*VRAM Total Used Memory \(B\):\s*(\d+)`),
+		visTotalRegex:    regexp.MustCompile(`GPU\[(\d+)\]\s*:\s*VIS_VRAM Total Memory \(B\):\s*(\d+)`),
+		visUsedRegex:     regexp.MustCompile(`GPU\[(\d+)\]\s*:\s*VIS_VRAM Total Used Memory \(B\):\s*(\d+)`),
+		gttTotalRegex:    regexp.MustCompile(`GPU\[(\d+)\]\s*:\s*GTT Total Memory \(B\):\s*(\d+)`),
+		gttUsedRegex:     regexp.MustCompile(`GPU\[(\d+)\]\s*:\s*GTT Total Used Memory \(B\):\s*(\d+)`),
 		sclkRegex:        regexp.MustCompile(`GPU\[\d+\]\s*:\s*sclk clock level:\s*\d+:\s*\((\d+)Mhz\)`), // SCLK frequency  
 		mclkRegex:        regexp.MustCompile(`GPU\[\d+\]\s*:\s*mclk clock level:\s*\d+:\s*\((\d+)Mhz\)`), // MCLK frequency
 	}
@@ -86,28 +117,13 @@ func (p *Parser) ParseRocmSMIOutput(output string) (*RocmData, error) {
 		GPUs:      make([]GPU, 0),
 	}
 
-	// Parse detailed VRAM information first
-	vramTotalMap := make(map[int]float64)
-	vramUsedMap := make(map[int]float64)
-	
-	// Extract VRAM total and used bytes
-	totalMatches := p.vramTotalRegex.FindAllStringSubmatch(output, -1)
-	for _, match := range totalMatches {
-		if len(match) > 2 {
-			gpuID, _ := strconv.Atoi(match[1])
-			totalBytes, _ := strconv.ParseFloat(match[2], 64)
-			vramTotalMap[gpuID] = totalBytes / (1024 * 1024 * 1024) // Convert to GB
-		}
-	}
-	
-	usedMatches := p.vramUsedRegex.FindAllStringSubmatch(output, -1)
-	for _, match := range usedMatches {
-		if len(match) > 2 {
-			gpuID, _ := strconv.Atoi(match[1])
-			usedBytes, _ := strconv.ParseFloat(match[2], 64)
-			vramUsedMap[gpuID] = usedBytes / (1024 * 1024 * 1024) // Convert to GB
-		}
-	}
+	// Parse detailed memory information first
+	vramTotalMap := p.parseMemInfo(p.vramTotalRegex, output)
+	vramUsedMap := p.parseMemInfo(p.vramUsedRegex, output)
+	visVRAMTotalMap := p.parseMemInfo(p.visTotalRegex, output)
+	visVRAMUsedMap := p.parseMemInfo(p.visUsedRegex, output)
+	gttTotalMap := p.parseMemInfo(p.gttTotalRegex, output)
+	gttUsedMap := p.parseMemInfo(p.gttUsedRegex, output)
 
 	// Split output by GPU sections
 	gpuSections := p.splitByGPU(output)
@@ -119,43 +135,60 @@ func (p *Parser) ParseRocmSMIOutput(output string) (*RocmData, error) {
 
 		// Parse temperature
 		if matches := p.tempRegex.FindStringSubmatch(section); len(matches) > 1 {
-			gpu.Temperature, _ = strconv.ParseFloat(matches[1], 64)
+			setParsed(&gpu, FieldTemperature, matches[1])
 		}
 
 		// Parse power
 		if matches := p.powerRegex.FindStringSubmatch(section); len(matches) > 1 {
-			gpu.Power, _ = strconv.ParseFloat(matches[1], 64)
+			setParsed(&gpu, FieldPower, matches[1])
 		}
 
-		// Parse VRAM% and GPU% from end of line
+		// Parse VRAM% and GPU% from end of line; VRAM% only becomes a usage in GB when the
+		// total is known
 		if matches := p.vramRegex.FindStringSubmatch(section); len(matches) > 2 {
-			gpu.VRAMUsage, _ = strconv.ParseFloat(matches[1], 64)
-			gpu.GPUUsage, _ = strconv.ParseFloat(matches[2], 64)
+			if total, exists := vramTotalMap[id]; exists {
+				if percent, err := strconv.ParseFloat(matches[1], 64); err == nil {
+					gpu.Set(FieldVRAMUsage, percent/100*total)
+				}
+			}
+			setParsed(&gpu, FieldGPUUsage, matches[2])
 		}
 
 		// Parse fan speed
 		if matches := p.fanRegex.FindStringSubmatch(section); len(matches) > 1 {
-			gpu.FanSpeed, _ = strconv.ParseFloat(matches[1], 64)
+			setParsed(&gpu, FieldFanSpeed, matches[1])
 		}
 
 		// Parse SCLK frequency from entire output
 		sclkPattern := regexp.MustCompile(fmt.Sprintf(`GPU\[%d\]\s*:\s*sclk clock level:\s*\d+:\s*\((\d+)Mhz\)`, id))
 		if matches := sclkPattern.FindStringSubmatch(output); len(matches) > 1 {
-			gpu.SCLKFreq, _ = strconv.ParseFloat(matches[1], 64)
+			setParsed(&gpu, FieldSCLKFreq, matches[1])
 		}
 
 		// Parse MCLK frequency from entire output
 		mclkPattern := regexp.MustCompile(fmt.Sprintf(`GPU\[%d\]\s*:\s*mclk clock level:\s*\d+:\s*\((\d+)Mhz\)`, id))
 		if matches := mclkPattern.FindStringSubmatch(output); len(matches) > 1 {
-			gpu.MCLKFreq, _ = strconv.ParseFloat(matches[1], 64)
+			setParsed(&gpu, FieldMCLKFreq, matches[1])
 		}
 
 		// Use detailed VRAM information if available
 		if total, exists := vramTotalMap[id]; exists {
-			gpu.VRAMTotal = total
+			gpu.Set(FieldVRAMTotal, total)
 		}
 		if used, exists := vramUsedMap[id]; exists {
-			gpu.VRAMUsage = used
+			gpu.Set(FieldVRAMUsage, used)
+		}
+		if total, exists := visVRAMTotalMap[id]; exists {
+			gpu.Set(FieldVisVRAMTotal, total)
+		}
+		if used, exists := visVRAMUsedMap[id]; exists {
+			gpu.Set(FieldVisVRAMUsage, used)
+		}
+		if total, exists := gttTotalMap[id]; exists {
+			gpu.Set(FieldGTTTotal, total)
+		}
+		if used, exists := gttUsedMap[id]; exists {
+			gpu.Set(FieldGTTUsage, used)
 		}
 
 		data.GPUs = append(data.GPUs, gpu)
@@ -168,6 +201,26 @@ func (p *Parser) ParseRocmSMIOutput(output string) (*RocmData, error) {
 	return data, nil
 }
 
+// setParsed stores a regex match as a metric value, leaving it unavailable if it is not a number
+func setParsed(gpu *GPU, field GPUField, match string) {
+	if value, err := strconv.ParseFloat(match, 64); err == nil {
+		gpu.Set(field, value)
+	}
+}
+
+// parseMemInfo extracts per-GPU byte counts from rocm-smi --showmeminfo output, in GB
+func (p *Parser) parseMemInfo(regex *regexp.Regexp, output string) map[int]float64 {
+	values := make(map[int]float64)
+	for _, match := range regex.FindAllStringSubmatch(output, -1) {
+		if len(match) > 2 {
+			gpuID, _ := strconv.Atoi(match[1])
+			bytes, _ := strconv.ParseFloat(match[2], 64)
+			values[gpuID] = bytes / (1024 * 1024 * 1024) // Convert to GB
+		}
+	}
+	return values
+}
+
 // splitByGPU splits the output into sections per GPU
 func (p *Parser) splitByGPU(output string) map[int]string {
 	sections := make(map[int]string)
@@ -238,17 +291,18 @@ func ReadCPUStats() (*CPUStats, error) {
 	return &CPUStats{Total: total, Idle: idle}, nil
 }
 
-// GetCPUUsage calculates CPU usage percentage between two readings
-func GetCPUUsage() (float64, error) {
+// GetCPUUsage calculates CPU usage percentage between two readings. ok is false for the
+// first reading, which only establishes the baseline.
+func GetCPUUsage() (usage float64, ok bool, err error) {
 	currentStats, err := ReadCPUStats()
 	if err != nil {
-		return 0, err
+		return 0, false, err
 	}
 
-	// If this is the first reading, store it and return 0
+	// If this is the first reading, store it; there is no usage to report yet
 	if lastCPUStats == nil {
 		lastCPUStats = currentStats
-		return 0, nil
+		return 0, false, nil
 	}
 
 	// Calculate differences
@@ -259,240 +313,149 @@ func GetCPUUsage() (float64, error) {
 	lastCPUStats = currentStats
 
 	if totalDiff == 0 {
-		return 0, nil
+		return 0, true, nil
 	}
 
 	// CPU usage percentage = (totalDiff - idleDiff) / totalDiff * 100
-	usage := (totalDiff - idleDiff) / totalDiff * 100
-	return usage, nil
-}
-
-// GetGPUStaticInfo retrieves static GPU information
-func GetGPUStaticInfo() ([]GPUStaticInfo, error) {
-	var gpuInfos []GPUStaticInfo
-	
-	// Get firmware information
-	fwInfo, err := getGPUFirmwareInfo()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get firmware info: %w", err)
-	}
-	
-	// Get product name
-	productName, err := getGPUProductName()
-	if err != nil {
-		productName = "Unknown"
-	}
-	
-	// Get serial number
-	serialNumber, err := getGPUSerialNumber()
-	if err != nil {
-		serialNumber = "Unknown"
-	}
-	
-	// Get unique ID
-	uniqueID, err := getGPUUniqueID()
-	if err != nil {
-		uniqueID = "Unknown"
-	}
-	
-	// Get VRAM vendor
-	vramVendor, err := getGPUVRAMVendor()
-	if err != nil {
-		vramVendor = "Unknown"
-	}
-	
-	// Get bus info
-	busInfo, err := getGPUBusInfo()
-	if err != nil {
-		busInfo = "Unknown"
-	}
-	
-	// For now, assume single GPU (ID 0)
-	gpuInfo := GPUStaticInfo{
-		ID:             0,
-		ProductName:    productName,
-		VendorName:     "AMD",
-		SerialNumber:   serialNumber,
-		UniqueID:       uniqueID,
-		FirmwareInfo:   fwInfo,
-		VRAMVendor:     vramVendor,
-		BusInfo:        busInfo,
-	}
-	
-	gpuInfos = append(gpuInfos, gpuInfo)
-	return gpuInfos, nil
+	usage = (totalDiff - idleDiff) / totalDiff * 100
+	return usage, true, nil
 }
 
-// Helper functions for getting static GPU information
-func getGPUFirmwareInfo() (map[string]string, error) {
-	cmd := exec.Command("rocm-smi", "--showfwinfo")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-	
-	fwInfo := make(map[string]string)
-	lines := strings.Split(string(output), "\n")
-	
-	for _, line := range lines {
-		if strings.Contains(line, "firmware version") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				key := strings.TrimSpace(strings.ReplaceAll(parts[0], "GPU[0]", ""))
-				key = strings.TrimSpace(strings.ReplaceAll(key, "\t", ""))
-				value := strings.TrimSpace(parts[1])
-				fwInfo[key] = value
-			}
+// GetGPUStaticInfo retrieves static information for every GPU in the rocm-smi text output;
+// every query is killed when ctx ends
+func GetGPUStaticInfo(ctx context.Context, binary string) ([]GPUStaticInfo, error) {
+	// Every query is optional; a failure only marks its values as unavailable. Firmware info
+	// is the query most often unsupported, e.g. on APUs and in containers.
+	fwFields, fwErr := getRocmSMIFields(ctx, binary, "--showfwinfo")
+	productFields, productErr := getRocmSMIFields(ctx, binary, "--showproductname")
+	serialFields, serialErr := getRocmSMIFields(ctx, binary, "--showserial")
+	uniqueIDFields, uniqueIDErr := getRocmSMIFields(ctx, binary, "--showuniqueid")
+	vramVendorFields, vramVendorErr := getRocmSMIFields(ctx, binary, "--showmemvendor")
+	busFields, busErr := getRocmSMIFields(ctx, binary, "--showbus")
+
+	// Every GPU mentioned by any query gets an entry
+	idSet := make(map[int]bool)
+	for _, fields := range []map[int]map[string]string{fwFields, productFields, serialFields, uniqueIDFields, vramVendorFields, busFields} {
+		for id := range fields {
+			idSet[id] = true
 		}
 	}
-	
-	return fwInfo, nil
-}
+	ids := make([]int, 0, len(idSet))
+	for id := range idSet {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	gpuInfos := make([]GPUStaticInfo, 0, len(ids))
+	for _, id := range ids {
+		gpuInfo := GPUStaticInfo{
+			ID:           id,
+			ProductName:  staticFieldValue(productFields[id], productErr, "Unknown", "Card Series", "Card series", "Card Model", "Card model"),
+			VendorName:   "AMD",
+			SerialNumber: staticFieldValue(serialFields[id], serialErr, "Not Available", "Serial Number", "Serial number"),
+			UniqueID:     staticFieldValue(uniqueIDFields[id], uniqueIDErr, "Not Available", "Unique ID"),
+			FirmwareInfo: make(map[string]string),
+			VRAMVendor:   staticFieldValue(vramVendorFields[id], vramVendorErr, "Not Available", "GPU memory vendor", "GPU Memory vendor"),
+			BusInfo:      staticFieldValue(busFields[id], busErr, "Not Available", "PCI Bus"),
+		}
 
-func getGPUProductName() (string, error) {
-	cmd := exec.Command("rocm-smi", "--showproductname")
-	output, err := cmd.Output()
-	if err != nil {
-		return "Unknown", nil
-	}
-	
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "GPU[0]") && strings.Contains(line, ":") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				value := strings.TrimSpace(parts[1])
-				// Check for "Not supported" messages
-				if strings.Contains(value, "Not supported") || strings.Contains(value, "get_") {
-					return "Not Available", nil
-				}
-				return value, nil
+		for key, value := range fwFields[id] {
+			if strings.Contains(key, "firmware version") {
+				gpuInfo.FirmwareInfo[key] = value
 			}
 		}
-	}
-	
-	return "Unknown", nil
-}
 
-func getGPUSerialNumber() (string, error) {
-	cmd := exec.Command("rocm-smi", "--showserial")
-	output, err := cmd.Output()
-	if err != nil {
-		return "Not Available", nil
+		gpuInfos = append(gpuInfos, gpuInfo)
 	}
-	
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "GPU[0]") && strings.Contains(line, ":") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				value := strings.TrimSpace(parts[1])
-				if strings.Contains(value, "Not supported") || strings.Contains(value, "get_") {
-					return "Not Available", nil
-				}
-				return value, nil
-			}
+
+	if len(gpuInfos) == 0 {
+		if productErr != nil {
+			return nil, fmt.Errorf("failed to get product info: %w", productErr)
 		}
+		if fwErr != nil {
+			return nil, fmt.Errorf("no GPUs found in rocm-smi output (firmware query: %v)", fwErr)
+		}
+		return nil, fmt.Errorf("no GPUs found in rocm-smi output")
 	}
-	
-	return "Unknown", nil
+
+	return gpuInfos, nil
 }
 
-func getGPUUniqueID() (string, error) {
-	cmd := exec.Command("rocm-smi", "--showuniqueid")
-	output, err := cmd.Output()
+// rocmSMIFieldRegex matches "GPU[n] : Key: Value" lines of rocm-smi info queries
+var rocmSMIFieldRegex = regexp.MustCompile(`^GPU\[(\d+)\]\s*:\s*(.*)$`)
+
+// getRocmSMIFields runs a rocm-smi info query and returns the key/value pairs per GPU.
+// Lines without a "Key:" prefix are stored under the empty key.
+func getRocmSMIFields(ctx context.Context, binary string, args ...string) (map[int]map[string]string, error) {
+	output, err := runCommand(ctx, binary, args...)
 	if err != nil {
-		return "Not Available", nil
+		return nil, err
 	}
-	
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "GPU[0]") && strings.Contains(line, ":") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				value := strings.TrimSpace(parts[1])
-				if strings.Contains(value, "Not supported") || strings.Contains(value, "get_") {
-					return "Not Available", nil
-				}
-				return value, nil
-			}
+
+	fields := make(map[int]map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		matches := rocmSMIFieldRegex.FindStringSubmatch(strings.TrimSpace(line))
+		if len(matches) < 3 {
+			continue
+		}
+		gpuID, _ := strconv.Atoi(matches[1])
+		if fields[gpuID] == nil {
+			fields[gpuID] = make(map[string]string)
 		}
-	}
-	
-	return "Unknown", nil
-}
 
-func getGPUVRAMVendor() (string, error) {
-	cmd := exec.Command("rocm-smi", "--showmemvendor")
-	output, err := cmd.Output()
-	if err != nil {
-		return "Not Available", nil
-	}
-	
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "GPU[0]") && strings.Contains(line, ":") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				value := strings.TrimSpace(parts[1])
-				if strings.Contains(value, "Not supported") || strings.Contains(value, "get_") {
-					return "Not Available", nil
-				}
-				return value, nil
-			}
+		key, value := "", strings.TrimSpace(matches[2])
+		if parts := strings.SplitN(value, ":", 2); len(parts) == 2 {
+			key, value = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		}
+		// Keep the first occurrence, e.g. "Card Series" before later repeats
+		if _, exists := fields[gpuID][key]; !exists {
+			fields[gpuID][key] = value
 		}
 	}
-	
-	return "Unknown", nil
+
+	return fields, nil
 }
 
-func getGPUBusInfo() (string, error) {
-	cmd := exec.Command("rocm-smi", "--showbus")
-	output, err := cmd.Output()
-	if err != nil {
-		return "Not Available", nil
+// staticFieldValue picks the first of keys from a GPU's fields, mapping unsupported values
+// to "Not Available" and missing values to fallback
+func staticFieldValue(fields map[string]string, queryErr error, fallback string, keys ...string) string {
+	if queryErr != nil {
+		return "Not Available"
 	}
-	
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "GPU[0]") && strings.Contains(line, ":") {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				value := strings.TrimSpace(parts[1])
-				if strings.Contains(value, "Not supported") || strings.Contains(value, "get_") {
-					return "Not Available", nil
-				}
-				return value, nil
-			}
+	for _, key := range append(keys, "") {
+		value, ok := fields[key]
+		if !ok || value == "" {
+			continue
+		}
+		// Check for "Not supported" messages
+		if strings.Contains(value, "Not supported") || strings.Contains(value, "get_") || value == "N/A" {
+			return "Not Available"
 		}
+		return value
 	}
-	
-	return "Unknown", nil
+	return fallback
 }
 
-// Validate checks if the parsed data is valid
-func (d *RocmData) Validate() error {
+// Validate checks if the parsed data is usable. Implausible values do not reject the sample;
+// they are marked unavailable and returned as problems, one per dropped value.
+func (d *RocmData) Validate() ([]string, error) {
 	if len(d.GPUs) == 0 {
-		return fmt.Errorf("no GPU data available")
+		return nil, fmt.Errorf("no GPU data available")
 	}
 
-	for i, gpu := range d.GPUs {
-		// Basic sanity checks
-		if gpu.Temperature < 0 || gpu.Temperature > 150 {
-			return fmt.Errorf("invalid temperature for GPU %d: %.2f", i, gpu.Temperature)
-		}
-		if gpu.Power < 0 || gpu.Power > 1000 {
-			return fmt.Errorf("invalid power for GPU %d: %.2f", i, gpu.Power)
-		}
-		if gpu.GPUUsage < 0 || gpu.GPUUsage > 100 {
-			return fmt.Errorf("invalid GPU usage for GPU %d: %.2f", i, gpu.GPUUsage)
+	var problems []string
+	for i := range d.GPUs {
+		for _, name := range d.GPUs[i].Sanitize() {
+			problems = append(problems, fmt.Sprintf("GPU %d %s", d.GPUs[i].ID, name))
 		}
 	}
 
 	// Validate CPU usage
-	if d.CPUUsage < 0 || d.CPUUsage > 100 {
-		return fmt.Errorf("invalid CPU usage: %.2f", d.CPUUsage)
+	if d.CPUAvailable && (d.CPUUsage < 0 || d.CPUUsage > 100) {
+		problems = append(problems, fmt.Sprintf("CPU usage %.2f", d.CPUUsage))
+		d.CPUUsage = 0
+		d.CPUAvailable = false
 	}
 
-	return nil
-}
\ No newline at end of file
+	return problems, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseRocmSMIOutput(t *testing.T) {
+	const table = "0       1     0x744c,   12345  45.0°C  60.0W  N/A, N/A, 0    1900Mhz  1249Mhz  30.0%  auto  255.0W  12%   37%\n"
+	const clocks = "GPU[0]		: sclk clock level: 1: (1900Mhz)\nGPU[0]		: mclk clock level: 3: (1249Mhz)\n"
+	const memory = "GPU[0]		: VRAM Total Memory (B): 17179869184\nGPU[0]		: GTT Total Memory (B): 34359738368\nGPU[0]		: GTT Total Used Memory (B): 1073741824\n"
+
+	base := map[GPUField]float64{
+		FieldTemperature: 45,
+		FieldPower:       60,
+		FieldFanSpeed:    30,
+		FieldGPUUsage:    37,
+		FieldSCLKFreq:    1900,
+		FieldMCLKFreq:    1249,
+	}
+	with := func(extra map[GPUField]float64) map[GPUField]float64 {
+		want := make(map[GPUField]float64, len(base)+len(extra))
+		for field, value := range base {
+			want[field] = value
+		}
+		for field, value := range extra {
+			want[field] = value
+		}
+		return want
+	}
+
+	tests := []struct {
+		name   string
+		output string
+		want   map[GPUField]float64
+	}{
+		{
+			// The table's VRAM% cannot be turned into GB without the total
+			name:   "table only",
+			output: table + clocks,
+			want:   base,
+		},
+		{
+			name:   "VRAM% scaled by the reported total",
+			output: table + clocks + memory,
+			want: with(map[GPUField]float64{
+				FieldVRAMTotal: 16,
+				FieldVRAMUsage: 0.12 * 16,
+				FieldGTTTotal:  32,
+				FieldGTTUsage:  1,
+			}),
+		},
+		{
+			name:   "used VRAM in bytes wins over the percentage",
+			output: table + clocks + memory + "GPU[0]		: VRAM Total Used Memory (B): 3221225472\n",
+			want: with(map[GPUField]float64{
+				FieldVRAMTotal: 16,
+				FieldVRAMUsage: 3,
+				FieldGTTTotal:  32,
+				FieldGTTUsage:  1,
+			}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := NewParser().ParseRocmSMIOutput(tt.output)
+			if err != nil {
+				t.Fatalf("ParseRocmSMIOutput: %v", err)
+			}
+			if len(data.GPUs) != 1 {
+				t.Fatalf("got %d GPUs, want 1", len(data.GPUs))
+			}
+			assertGPUFields(t, data.GPUs[0], tt.want)
+		})
+	}
+}
+
+func TestUpdateAccessibleMemory(t *testing.T) {
+	tests := []struct {
+		name string
+		set  map[GPUField]float64
+		want map[GPUField]float64 // The accessible memory fields
+	}{
+		{
+			name: "VRAM and GTT",
+			set:  map[GPUField]float64{FieldVRAMUsage: 1, FieldVRAMTotal: 16, FieldGTTUsage: 2, FieldGTTTotal: 32},
+			want: map[GPUField]float64{FieldAccessibleMemUsage: 3, FieldAccessibleMemTotal: 48},
+		},
+		{
+			name: "VRAM only",
+			set:  map[GPUField]float64{FieldVRAMUsage: 1, FieldVRAMTotal: 16},
+			want: map[GPUField]float64{},
+		},
+		{
+			name: "totals without GTT usage",
+			set:  map[GPUField]float64{FieldVRAMUsage: 1, FieldVRAMTotal: 16, FieldGTTTotal: 32},
+			want: map[GPUField]float64{FieldAccessibleMemTotal: 48},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gpu GPU
+			for field, value := range tt.set {
+				gpu.Set(field, value)
+			}
+			gpu.UpdateAccessibleMemory()
+
+			want := make(map[GPUField]float64, len(tt.set)+len(tt.want))
+			for field, value := range tt.set {
+				want[field] = value
+			}
+			for field, value := range tt.want {
+				want[field] = value
+			}
+			assertGPUFields(t, gpu, want)
+		})
+	}
+}
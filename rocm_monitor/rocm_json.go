@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rocmSMIJSONCard holds the key/value pairs rocm-smi reports for one card
+type rocmSMIJSONCard map[string]string
+
+// rocm-smi renames keys between releases, so every value is looked up by a list of candidates
+var (
+	jsonTempKeys      = []string{"Temperature (Sensor edge) (C)", "Temperature (Sensor junction) (C)"}
+	jsonPowerKeys     = []string{"Average Graphics Package Power (W)", "Current Socket Graphics Package Power (W)"}
+	jsonGPUUseKeys    = []string{"GPU use (%)"}
+	jsonFanKeys       = []string{"Fan speed (%)"}
+	jsonVRAMTotalKeys = []string{"VRAM Total Memory (B)"}
+	jsonVRAMUsedKeys  = []string{"VRAM Total Used Memory (B)"}
+	jsonVisTotalKeys  = []string{"VIS_VRAM Total Memory (B)"}
+	jsonVisUsedKeys   = []string{"VIS_VRAM Total Used Memory (B)"}
+	jsonGTTTotalKeys  = []string{"GTT Total Memory (B)"}
+	jsonGTTUsedKeys   = []string{"GTT Total Used Memory (B)"}
+	jsonSCLKKeys      = []string{"sclk clock speed:", "sclk clock speed"}
+	jsonMCLKKeys      = []string{"mclk clock speed:", "mclk clock speed"}
+	jsonProductKeys   = []string{"Card Series", "Card series", "Card Model", "Card model"}
+	jsonSerialKeys    = []string{"Serial Number", "Serial number"}
+	jsonUniqueIDKeys  = []string{"Unique ID"}
+	jsonMemVendorKeys = []string{"GPU memory vendor", "GPU Memory vendor"}
+	jsonBusKeys       = []string{"PCI Bus"}
+)
+
+var (
+	jsonCardRegex   = regexp.MustCompile(`^card(\d+)$`)
+	jsonNumberRegex = regexp.MustCompile(`-?\d+(\.\d+)?`)
+)
+
+// decodeRocmSMIJSON extracts the per-card objects from rocm-smi --json output
+func decodeRocmSMIJSON(output []byte) (map[int]rocmSMIJSONCard, error) {
+	// rocm-smi may print warnings around the JSON document
+	start := bytes.IndexByte(output, '{')
+	end := bytes.LastIndexByte(output, '}')
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON object in rocm-smi output")
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(output[start:end+1], &doc); err != nil {
+		return nil, fmt.Errorf("invalid rocm-smi JSON: %w", err)
+	}
+
+	cards := make(map[int]rocmSMIJSONCard)
+	for key, raw := range doc {
+		matches := jsonCardRegex.FindStringSubmatch(key)
+		if len(matches) < 2 {
+			continue
+		}
+		id, _ := strconv.Atoi(matches[1])
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, fmt.Errorf("invalid rocm-smi JSON for %s: %w", key, err)
+		}
+
+		card := make(rocmSMIJSONCard, len(fields))
+		for name, value := range fields {
+			card[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(fmt.Sprint(value))
+		}
+		cards[id] = card
+	}
+
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("no GPU data found in rocm-smi JSON")
+	}
+
+	return cards, nil
+}
+
+// lookup returns the first supported value among the candidate keys
+func (c rocmSMIJSONCard) lookup(keys ...string) (string, bool) {
+	for _, key := range keys {
+		value, ok := c[strings.ToLower(key)]
+		if !ok || value == "" || value == "N/A" || strings.Contains(value, "Not supported") {
+			continue
+		}
+		return value, true
+	}
+	return "", false
+}
+
+// number returns the first numeric value among the candidate keys, e.g. "(2900Mhz)" -> 2900
+func (c rocmSMIJSONCard) number(keys ...string) (float64, bool) {
+	value, ok := c.lookup(keys...)
+	if !ok {
+		return 0, false
+	}
+	match := jsonNumberRegex.FindString(value)
+	if match == "" {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return 0, false
+	}
+	return number, true
+}
+
+// sortedCardIDs returns the card indices in ascending order
+func sortedCardIDs(cards map[int]rocmSMIJSONCard) []int {
+	ids := make([]int, 0, len(cards))
+	for id := range cards {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// ParseRocmSMIJSON parses one or more rocm-smi --json metric outputs and returns structured
+// data, merging the per-card fields of all documents
+func (p *Parser) ParseRocmSMIJSON(outputs ...[]byte) (*RocmData, error) {
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("empty rocm-smi output")
+	}
+
+	cards := make(map[int]rocmSMIJSONCard)
+	for _, output := range outputs {
+		part, err := decodeRocmSMIJSON(output)
+		if err != nil {
+			return nil, err
+		}
+		for id, fields := range part {
+			if cards[id] == nil {
+				cards[id] = make(rocmSMIJSONCard, len(fields))
+			}
+			for key, value := range fields {
+				cards[id][key] = value
+			}
+		}
+	}
+
+	data := &RocmData{
+		Timestamp: time.Now(),
+		GPUs:      make([]GPU, 0, len(cards)),
+	}
+
+	for _, id := range sortedCardIDs(cards) {
+		card := cards[id]
+		gpu := GPU{ID: id}
+
+		if v, ok := card.lookup(jsonUniqueIDKeys...); ok {
+			gpu.UniqueID = v
+		}
+		if v, ok := card.number(jsonTempKeys...); ok {
+			gpu.Set(FieldTemperature, v)
+		}
+		if v, ok := card.number(jsonPowerKeys...); ok {
+			gpu.Set(FieldPower, v)
+		}
+		if v, ok := card.number(jsonGPUUseKeys...); ok {
+			gpu.Set(FieldGPUUsage, v)
+		}
+		if v, ok := card.number(jsonFanKeys...); ok {
+			gpu.Set(FieldFanSpeed, v)
+		}
+		if v, ok := card.number(jsonSCLKKeys...); ok {
+			gpu.Set(FieldSCLKFreq, v)
+		}
+		if v, ok := card.number(jsonMCLKKeys...); ok {
+			gpu.Set(FieldMCLKFreq, v)
+		}
+
+		// Memory sizes are reported in bytes
+		if v, ok := card.number(jsonVRAMTotalKeys...); ok {
+			gpu.Set(FieldVRAMTotal, v/(1024*1024*1024))
+		}
+		if v, ok := card.number(jsonVRAMUsedKeys...); ok {
+			gpu.Set(FieldVRAMUsage, v/(1024*1024*1024))
+		}
+		if v, ok := card.number(jsonVisTotalKeys...); ok {
+			gpu.Set(FieldVisVRAMTotal, v/(1024*1024*1024))
+		}
+		if v, ok := card.number(jsonVisUsedKeys...); ok {
+			gpu.Set(FieldVisVRAMUsage, v/(1024*1024*1024))
+		}
+		if v, ok := card.number(jsonGTTTotalKeys...); ok {
+			gpu.Set(FieldGTTTotal, v/(1024*1024*1024))
+		}
+		if v, ok := card.number(jsonGTTUsedKeys...); ok {
+			gpu.Set(FieldGTTUsage, v/(1024*1024*1024))
+		}
+
+		data.GPUs = append(data.GPUs, gpu)
+	}
+
+	return data, nil
+}
+
+// ParseStaticInfoJSON parses rocm-smi --json identification output into static GPU info
+func (p *Parser) ParseStaticInfoJSON(output []byte) ([]GPUStaticInfo, error) {
+	cards, err := decodeRocmSMIJSON(output)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]GPUStaticInfo, 0, len(cards))
+	for _, id := range sortedCardIDs(cards) {
+		card := cards[id]
+		info := GPUStaticInfo{
+			ID:           id,
+			ProductName:  "Unknown",
+			VendorName:   "AMD",
+			SerialNumber: "Not Available",
+			UniqueID:     "Not Available",
+			FirmwareInfo: make(map[string]string),
+			VRAMVendor:   "Not Available",
+			BusInfo:      "Not Available",
+		}
+
+		if v, ok := card.lookup(jsonProductKeys...); ok {
+			info.ProductName = v
+		}
+		if v, ok := card.lookup(jsonSerialKeys...); ok {
+			info.SerialNumber = v
+		}
+		if v, ok := card.lookup(jsonUniqueIDKeys...); ok {
+			info.UniqueID = v
+		}
+		if v, ok := card.lookup(jsonMemVendorKeys...); ok {
+			info.VRAMVendor = v
+		}
+		if v, ok := card.lookup(jsonBusKeys...); ok {
+			info.BusInfo = v
+		}
+
+		// Keys were lower-cased while decoding; restore the acronym style of the text output
+		for key, value := range card {
+			if !strings.HasSuffix(key, "firmware version") || value == "" {
+				continue
+			}
+			name := strings.TrimSuffix(key, "firmware version")
+			fwInfo := strings.ToUpper(strings.TrimSpace(name)) + " firmware version"
+			info.FirmwareInfo[fwInfo] = value
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
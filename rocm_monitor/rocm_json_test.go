@@ -0,0 +1,236 @@
+package main
+
+import (
+	"testing"
+)
+
+const gib = 1 << 30
+
+func TestParseRocmSMIJSONFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []map[GPUField]float64 // Per card; fields left out must be unavailable
+	}{
+		{
+			fixture: "rocm-5.7",
+			want: []map[GPUField]float64{{
+				FieldTemperature: 38, // Edge sensor wins over junction
+				FieldPower:       24,
+				FieldGPUUsage:    3,
+				FieldFanSpeed:    20,
+				FieldSCLKFreq:    39,
+				FieldMCLKFreq:    96,
+				FieldVRAMTotal:   25753026560.0 / gib,
+				FieldVRAMUsage:   1173286912.0 / gib,
+				FieldGTTTotal:    33569931264.0 / gib,
+				FieldGTTUsage:    23105536.0 / gib,
+			}},
+		},
+		{
+			// APU: socket power instead of average power, no fan
+			fixture: "rocm-6.2",
+			want: []map[GPUField]float64{{
+				FieldTemperature: 42,
+				FieldPower:       8.044,
+				FieldGPUUsage:    7,
+				FieldSCLKFreq:    800,
+				FieldMCLKFreq:    2800,
+				FieldVRAMTotal:   0.5,
+				FieldVRAMUsage:   150499328.0 / gib,
+				FieldGTTTotal:    16315584512.0 / gib,
+				FieldGTTUsage:    1204416512.0 / gib,
+			}},
+		},
+		{
+			// "Not supported" fan on the APU, 0 MHz sclk on the idle discrete card
+			fixture: "rocm-6.4",
+			want: []map[GPUField]float64{
+				{
+					FieldTemperature: 51,
+					FieldPower:       68.021,
+					FieldGPUUsage:    96,
+					FieldSCLKFreq:    2900,
+					FieldMCLKFreq:    8000,
+					FieldVRAMTotal:   0.5,
+					FieldVRAMUsage:   201326592.0 / gib,
+					FieldGTTTotal:    96,
+					FieldGTTUsage:    90,
+				},
+				{
+					FieldTemperature: 33,
+					FieldPower:       11,
+					FieldGPUUsage:    0,
+					FieldFanSpeed:    0,
+					FieldSCLKFreq:    0,
+					FieldMCLKFreq:    96,
+					FieldVRAMTotal:   17163091968.0 / gib,
+					FieldVRAMUsage:   303603712.0 / gib,
+					FieldGTTTotal:    65842737152.0 / gib,
+					FieldGTTUsage:    14852096.0 / gib,
+				},
+			},
+		},
+		{
+			fixture: "synthetic-vis-vram",
+			want: []map[GPUField]float64{
+				{
+					FieldTemperature:  51,
+					FieldPower:        68.021,
+					FieldGPUUsage:     96,
+					FieldSCLKFreq:     2900,
+					FieldMCLKFreq:     8000,
+					FieldVRAMTotal:    0.5,
+					FieldVRAMUsage:    201326592.0 / gib,
+					FieldVisVRAMTotal: 0.5,
+					FieldVisVRAMUsage: 201326592.0 / gib,
+					FieldGTTTotal:     96,
+					FieldGTTUsage:     90,
+				},
+				{
+					FieldTemperature:  33,
+					FieldPower:        11,
+					FieldGPUUsage:     0,
+					FieldFanSpeed:     0,
+					FieldSCLKFreq:     0,
+					FieldMCLKFreq:     96,
+					FieldVRAMTotal:    17163091968.0 / gib,
+					FieldVRAMUsage:    303603712.0 / gib,
+					FieldVisVRAMTotal: 17163091968.0 / gib,
+					FieldVisVRAMUsage: 303603712.0 / gib,
+					FieldGTTTotal:     65842737152.0 / gib,
+					FieldGTTUsage:     14852096.0 / gib,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data, err := NewParser().ParseRocmSMIJSON(readFixture(t, "rocm-smi", tt.fixture, "metrics.json"))
+			if err != nil {
+				t.Fatalf("ParseRocmSMIJSON: %v", err)
+			}
+			if len(data.GPUs) != len(tt.want) {
+				t.Fatalf("got %d GPUs, want %d", len(data.GPUs), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if data.GPUs[i].ID != i {
+					t.Errorf("GPU %d has ID %d", i, data.GPUs[i].ID)
+				}
+				assertGPUFields(t, data.GPUs[i], want)
+			}
+		})
+	}
+}
+
+func TestParseRocmSMIJSONMerge(t *testing.T) {
+	metrics := []byte(`WARNING: AMD GPU device(s) is/are in a low-power state
+{"card0": {"Temperature (Sensor edge) (C)": "40.0", "GPU use (%)": "12"}, "system": {}}`)
+	memory := []byte(`{"card0": {"VRAM Total Memory (B)": "1073741824", "VRAM Total Used Memory (B)": "N/A"}}`)
+
+	data, err := NewParser().ParseRocmSMIJSON(metrics, memory)
+	if err != nil {
+		t.Fatalf("ParseRocmSMIJSON: %v", err)
+	}
+	if len(data.GPUs) != 1 {
+		t.Fatalf("got %d GPUs, want 1", len(data.GPUs))
+	}
+	assertGPUFields(t, data.GPUs[0], map[GPUField]float64{
+		FieldTemperature: 40,
+		FieldGPUUsage:    12,
+		FieldVRAMTotal:   1,
+	})
+
+	for _, output := range []string{"", "not json", `{"system": {"Driver version": "6.8"}}`, `{"card0": "x"}`} {
+		if _, err := NewParser().ParseRocmSMIJSON([]byte(output)); err == nil {
+			t.Errorf("ParseRocmSMIJSON(%q) succeeded", output)
+		}
+	}
+}
+
+func TestParseStaticInfoJSONFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []GPUStaticInfo // Firmware is checked by count and a sample entry
+		fwCount []int
+	}{
+		{
+			fixture: "rocm-5.7",
+			want: []GPUStaticInfo{{
+				ID:           0,
+				ProductName:  "Navi 31 [Radeon RX 7900 XT/7900 XTX/7900 GRE/7900M]",
+				SerialNumber: "Not Available",
+				UniqueID:     "0x3a0f4e4a1c2b5d61",
+				VRAMVendor:   "samsung",
+				BusInfo:      "0000:03:00.0",
+				FirmwareInfo: map[string]string{"MEC2 firmware version": "2170", "TA RAS firmware version": "1b.00.01.3f"},
+			}},
+			fwCount: []int{17},
+		},
+		{
+			fixture: "rocm-6.2",
+			want: []GPUStaticInfo{{
+				ID:           0,
+				ProductName:  "Phoenix1",
+				SerialNumber: "Not Available",
+				UniqueID:     "Not Available",
+				VRAMVendor:   "unknown",
+				BusInfo:      "0000:C4:00.0",
+				FirmwareInfo: map[string]string{"VCN firmware version": "0x09118010"},
+			}},
+			fwCount: []int{10},
+		},
+		{
+			fixture: "rocm-6.4",
+			want: []GPUStaticInfo{
+				{
+					ID:           0,
+					ProductName:  "AMD Radeon Graphics",
+					SerialNumber: "Not Available",
+					UniqueID:     "Not Available",
+					VRAMVendor:   "unknown",
+					BusInfo:      "0000:C5:00.0",
+					FirmwareInfo: map[string]string{"SMC firmware version": "00.100.63.00"},
+				},
+				{
+					ID:           1,
+					ProductName:  "Navi 48 [Radeon RX 9070/9070 XT]",
+					SerialNumber: "Not Available",
+					UniqueID:     "0x5b7c1e2d9a4f8036",
+					VRAMVendor:   "hynix",
+					BusInfo:      "0000:03:00.0",
+					FirmwareInfo: map[string]string{"SDMA firmware version": "875"},
+				},
+			},
+			fwCount: []int{10, 8},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			infos, err := NewParser().ParseStaticInfoJSON(readFixture(t, "rocm-smi", tt.fixture, "static.json"))
+			if err != nil {
+				t.Fatalf("ParseStaticInfoJSON: %v", err)
+			}
+			if len(infos) != len(tt.want) {
+				t.Fatalf("got %d GPUs, want %d", len(infos), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := infos[i]
+				if got.ID != want.ID || got.ProductName != want.ProductName || got.VendorName != "AMD" ||
+					got.SerialNumber != want.SerialNumber || got.UniqueID != want.UniqueID ||
+					got.VRAMVendor != want.VRAMVendor || got.BusInfo != want.BusInfo {
+					t.Errorf("GPU %d = %+v, want %+v", i, got, want)
+				}
+				if len(got.FirmwareInfo) != tt.fwCount[i] {
+					t.Errorf("GPU %d has %d firmware entries, want %d: %v", i, len(got.FirmwareInfo), tt.fwCount[i], got.FirmwareInfo)
+				}
+				for key, value := range want.FirmwareInfo {
+					if got.FirmwareInfo[key] != value {
+						t.Errorf("GPU %d %s = %q, want %q", i, key, got.FirmwareInfo[key], value)
+					}
+				}
+			}
+		})
+	}
+}
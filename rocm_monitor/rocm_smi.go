@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// rocmSMIQuery is one rocm-smi invocation contributing part of a sample
+type rocmSMIQuery struct {
+	name     string
+	args     []string
+	fields   GPUField // Reported in RocmData.Unavailable when the query fails
+	required bool     // The sample is dropped when a required query fails
+}
+
+// Fields contributed by each kind of query
+const (
+	rocmSMIMetricFields = FieldTemperature | FieldPower | FieldGPUUsage | FieldFanSpeed
+	rocmSMIMemoryFields = FieldVRAMUsage | FieldVRAMTotal | FieldVisVRAMUsage | FieldVisVRAMTotal |
+		FieldGTTUsage | FieldGTTTotal | FieldAccessibleMemUsage | FieldAccessibleMemTotal
+	rocmSMIClockFields = FieldSCLKFreq | FieldMCLKFreq
+)
+
+// rocmSMIJSONQueries split the sampled metrics into independent --json invocations
+var rocmSMIJSONQueries = []rocmSMIQuery{
+	{name: "metrics", args: []string{"--showtemp", "--showpower", "--showuse", "--showfan", "--showuniqueid", "--json"}, fields: rocmSMIMetricFields, required: true},
+	{name: "memory", args: []string{"--showmeminfo", "vram", "vis_vram", "gtt", "--json"}, fields: rocmSMIMemoryFields},
+	{name: "clocks", args: []string{"--showclocks", "--json"}, fields: rocmSMIClockFields},
+}
+
+// rocmSMITextQueries are the plain table invocations used when JSON is unavailable
+var rocmSMITextQueries = []rocmSMIQuery{
+	{name: "metrics", args: []string{}, fields: rocmSMIMetricFields, required: true},
+	{name: "memory", args: []string{"--showmeminfo", "vram", "vis_vram", "gtt"}, fields: rocmSMIMemoryFields},
+	{name: "clocks", args: []string{"-c"}, fields: rocmSMIClockFields},
+}
+
+// rocmSMIJSONStaticArgs request the identification fields as a single JSON document; the
+// second set leaves out firmware, which some rocm-smi builds cannot report
+var rocmSMIJSONStaticArgs = [][]string{
+	{"--showproductname", "--showserial", "--showuniqueid", "--showmemvendor", "--showbus", "--showfwinfo", "--json"},
+	{"--showproductname", "--showserial", "--showuniqueid", "--showmemvendor", "--showbus", "--json"},
+}
+
+// rocmSMIMaxConcurrent bounds the number of rocm-smi processes running at once
+const rocmSMIMaxConcurrent = 3
+
+// rocmSMIResult is the outcome of a single query
+type rocmSMIResult struct {
+	query  rocmSMIQuery
+	output []byte
+	err    error
+}
+
+// RocmSMISource collects data by running rocm-smi, preferring its JSON output
+type RocmSMISource struct {
+	parser       *Parser
+	binary       string
+	queryTimeout time.Duration
+	slots        chan struct{}
+
+	jsonMutex    sync.Mutex
+	jsonDisabled bool
+}
+
+// NewRocmSMISource creates a rocm-smi backed source; binary defaults to rocm-smi from PATH
+// and every sub-query is limited to queryTimeout (3s if unset)
+func NewRocmSMISource(binary string, queryTimeout time.Duration) *RocmSMISource {
+	if binary == "" {
+		binary = "rocm-smi"
+	}
+	if queryTimeout <= 0 {
+		queryTimeout = 3 * time.Second
+	}
+	return &RocmSMISource{
+		parser:       NewParser(),
+		binary:       binary,
+		queryTimeout: queryTimeout,
+		slots:        make(chan struct{}, rocmSMIMaxConcurrent),
+	}
+}
+
+// Name returns the source identifier
+func (s *RocmSMISource) Name() string {
+	return "rocm-smi"
+}
+
+// useJSON reports whether the JSON mode has not been ruled out yet
+func (s *RocmSMISource) useJSON() bool {
+	s.jsonMutex.Lock()
+	defer s.jsonMutex.Unlock()
+	return !s.jsonDisabled
+}
+
+// disableJSON switches the source to text parsing after JSON turned out to be unavailable
+func (s *RocmSMISource) disableJSON(err error) {
+	s.jsonMutex.Lock()
+	defer s.jsonMutex.Unlock()
+	if !s.jsonDisabled {
+		log.Printf("rocm-smi JSON output unavailable, falling back to text parsing: %v", err)
+		s.jsonDisabled = true
+	}
+}
+
+// runQueries executes the queries concurrently, each under its own timeout
+func (s *RocmSMISource) runQueries(ctx context.Context, queries []rocmSMIQuery) []rocmSMIResult {
+	results := make([]rocmSMIResult, len(queries))
+
+	var wg sync.WaitGroup
+	for i, query := range queries {
+		wg.Add(1)
+		go func(i int, query rocmSMIQuery) {
+			defer wg.Done()
+			results[i] = rocmSMIResult{query: query}
+
+			// Wait for a free slot so a burst of requests cannot fork unbounded processes
+			select {
+			case s.slots <- struct{}{}:
+				defer func() { <-s.slots }()
+			case <-ctx.Done():
+				results[i].err = ctx.Err()
+				return
+			}
+
+			queryCtx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+			defer cancel()
+
+			output, err := runCommand(queryCtx, s.binary, query.args...)
+			if err != nil && queryCtx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("timed out after %v", s.queryTimeout)
+			}
+			results[i].output, results[i].err = output, err
+		}(i, query)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// runCommand runs a command and returns its stdout; on a non-zero exit the *exec.ExitError
+// carries stderr. When ctx ends the whole process group is killed, so children of wrapper
+// scripts cannot hold the output pipe open past the timeout.
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitErr.Stderr = stderr.Bytes()
+		}
+		return stdout.Bytes(), err
+	case <-ctx.Done():
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return stdout.Bytes(), ctx.Err()
+	}
+}
+
+// Collect runs rocm-smi and parses its output
+func (s *RocmSMISource) Collect(ctx context.Context) (*RocmData, error) {
+	if !s.useJSON() {
+		return s.collectText(ctx)
+	}
+
+	data, jsonErr := s.collectJSON(ctx)
+	if jsonErr == nil {
+		return data, nil
+	}
+
+	// Only give up on JSON once the text output proves rocm-smi itself works, and only when
+	// the JSON failure says JSON is unsupported rather than that rocm-smi was slow or failed
+	data, err := s.collectText(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if jsonUnsupported(jsonErr) {
+		s.disableJSON(jsonErr)
+	}
+	return data, nil
+}
+
+// unsupportedFlagMessages are what rocm-smi builds print when they reject an argument
+var unsupportedFlagMessages = []string{"unrecognized argument", "unrecognised argument", "invalid option", "unknown option"}
+
+// jsonUnsupported reports whether a failed JSON collection means this rocm-smi cannot
+// produce JSON: its output did not parse, or it rejected one of the flags
+func jsonUnsupported(err error) bool {
+	if errorCategory(err) == ErrorParse {
+		return true
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	stderr := strings.ToLower(string(exitErr.Stderr))
+	for _, message := range unsupportedFlagMessages {
+		if strings.Contains(stderr, message) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectJSON runs the --json queries and merges whatever succeeded
+func (s *RocmSMISource) collectJSON(ctx context.Context) (*RocmData, error) {
+	var outputs [][]byte
+	var unavailable []string
+
+	for _, result := range s.runQueries(ctx, rocmSMIJSONQueries) {
+		err := result.err
+		if err != nil {
+			err = execError(err)
+		} else if _, decodeErr := decodeRocmSMIJSON(result.output); decodeErr != nil {
+			// Check each part on its own so one malformed document only loses its fields
+			err = parseError(decodeErr)
+		}
+		if err != nil {
+			if result.query.required {
+				return nil, fmt.Errorf("rocm-smi %s query failed: %w", result.query.name, err)
+			}
+			unavailable = append(unavailable, result.query.fields.Names()...)
+			continue
+		}
+		outputs = append(outputs, result.output)
+	}
+
+	data, err := s.parser.ParseRocmSMIJSON(outputs...)
+	if err != nil {
+		return nil, parseError(fmt.Errorf("parsing failed: %w", err))
+	}
+	data.Unavailable = unavailable
+	return data, nil
+}
+
+// collectText runs the plain rocm-smi tables and parses the combined text output
+func (s *RocmSMISource) collectText(ctx context.Context) (*RocmData, error) {
+	var combinedOutput strings.Builder
+	var unavailable []string
+
+	for _, result := range s.runQueries(ctx, rocmSMITextQueries) {
+		if result.err != nil {
+			if result.query.required {
+				return nil, execError(fmt.Errorf("rocm-smi execution failed: %w", result.err))
+			}
+			unavailable = append(unavailable, result.query.fields.Names()...)
+			continue
+		}
+		// Combine outputs for parsing
+		combinedOutput.Write(result.output)
+		combinedOutput.WriteString("\n")
+	}
+
+	data, err := s.parser.ParseRocmSMIOutput(combinedOutput.String())
+	if err != nil {
+		return nil, parseError(fmt.Errorf("parsing failed: %w", err))
+	}
+	data.Unavailable = unavailable
+	return data, nil
+}
+
+// StaticInfo returns static GPU information reported by rocm-smi
+func (s *RocmSMISource) StaticInfo(ctx context.Context) ([]GPUStaticInfo, error) {
+	if s.useJSON() {
+		for _, args := range rocmSMIJSONStaticArgs {
+			output, err := runCommand(ctx, s.binary, args...)
+			if err != nil {
+				continue
+			}
+			if infos, err := s.parser.ParseStaticInfoJSON(output); err == nil {
+				return infos, nil
+			}
+		}
+	}
+	return GetGPUStaticInfo(ctx, s.binary)
+}
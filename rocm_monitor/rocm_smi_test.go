@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetGPUStaticInfoWithoutFirmware(t *testing.T) {
+	binary := writeFakeCommand(t, `
+case " $* " in
+*" --showfwinfo "*) echo "fwinfo not supported" >&2; exit 2 ;;
+*" --showproductname "*) echo "GPU[0]		: Card Series: 		Navi 31"; echo "GPU[1]		: Card Series: 		Phoenix1" ;;
+*" --showbus "*) echo "GPU[0]		: PCI Bus: 0000:03:00.0" ;;
+*) exit 1 ;;
+esac
+`)
+
+	infos, err := GetGPUStaticInfo(context.Background(), binary)
+	if err != nil {
+		t.Fatalf("GetGPUStaticInfo: %v", err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("got %d GPUs, want 2", len(infos))
+	}
+	if infos[0].ProductName != "Navi 31" || infos[0].BusInfo != "0000:03:00.0" || len(infos[0].FirmwareInfo) != 0 {
+		t.Errorf("GPU 0 = %+v", infos[0])
+	}
+	if infos[1].ProductName != "Phoenix1" || infos[1].BusInfo != "Not Available" || infos[1].SerialNumber != "Not Available" {
+		t.Errorf("GPU 1 = %+v", infos[1])
+	}
+
+	failing := writeFakeCommand(t, "exit 1\n")
+	if _, err := GetGPUStaticInfo(context.Background(), failing); err == nil {
+		t.Error("GetGPUStaticInfo succeeded although every query failed")
+	}
+}
+
+func TestRocmSMISourceStaticInfoHonoursContext(t *testing.T) {
+	// JSON is rejected, and the text queries hang behind a child holding stdout open
+	binary := writeFakeCommand(t, `
+case " $* " in
+*" --json "*) echo "unrecognized arguments: --json" >&2; exit 2 ;;
+*) sleep 30 ;;
+esac
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := NewRocmSMISource(binary, time.Second).StaticInfo(ctx); err == nil {
+		t.Error("StaticInfo succeeded although every query hung")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("StaticInfo returned after %v, want shortly after the context ended", elapsed)
+	}
+}
+
+func TestRocmSMISourceStaticInfoJSONWithoutFirmware(t *testing.T) {
+	fixture, err := filepath.Abs(filepath.Join("testdata", "rocm-smi", "rocm-6.2", "static.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := writeFakeCommand(t, `
+case " $* " in
+*" --showfwinfo "*) exit 2 ;;
+*" --json "*) cat '`+fixture+`' ;;
+*) exit 1 ;;
+esac
+`)
+
+	infos, err := NewRocmSMISource(binary, time.Second).StaticInfo(context.Background())
+	if err != nil {
+		t.Fatalf("StaticInfo: %v", err)
+	}
+	if len(infos) != 1 || infos[0].ProductName != "Phoenix1" || infos[0].BusInfo != "0000:C4:00.0" {
+		t.Errorf("infos = %+v", infos)
+	}
+}
+
+// rocmSMITextScript answers the plain table queries; the memory query fails
+const rocmSMITextScript = `
+case " $* " in
+*" --showmeminfo "*) exit 1 ;;
+*" -c "*) echo "GPU[0]		: sclk clock level: 1: (1900Mhz)" ;;
+*) echo "0       1     0x744c,   12345  45.0°C  60.0W  N/A, N/A, 0    1900Mhz  1249Mhz  30.0%  auto  255.0W  12%   37%" ;;
+esac
+`
+
+func TestRocmSMISourceJSONFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string // Script run for --json queries
+		keepJSON bool
+	}{
+		{"timeout keeps JSON", "sleep 5", true},
+		{"non-zero exit keeps JSON", `echo "GPU is busy" >&2; exit 1`, true},
+		{"unsupported flag disables JSON", `echo "rocm-smi: error: unrecognized arguments: --json" >&2; exit 2`, false},
+		{"unparseable output disables JSON", `echo "not json"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binary := writeFakeCommand(t, `case " $* " in *" --json "*) `+tt.json+` ; exit ;; esac`+rocmSMITextScript)
+			source := NewRocmSMISource(binary, 200*time.Millisecond)
+
+			data, err := source.Collect(context.Background())
+			if err != nil {
+				t.Fatalf("Collect: %v", err)
+			}
+			if len(data.GPUs) != 1 || !data.GPUs[0].Has(FieldTemperature) || data.GPUs[0].SCLKFreq != 1900 {
+				t.Errorf("text fallback sample = %+v", data.GPUs)
+			}
+			if got := source.useJSON(); got != tt.keepJSON {
+				t.Errorf("JSON enabled = %v, want %v", got, tt.keepJSON)
+			}
+		})
+	}
+}
+
+func TestRocmSMISourceUnavailableFields(t *testing.T) {
+	fixture, err := filepath.Abs(filepath.Join("testdata", "rocm-smi", "rocm-6.2", "metrics.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantMemory := []string{"vram_usage", "vram_total", "vis_vram_usage", "vis_vram_total", "gtt_usage", "gtt_total", "accessible_mem_usage", "accessible_mem_total"}
+
+	// JSON mode: only the memory query fails
+	binary := writeFakeCommand(t, `
+case " $* " in
+*" --showmeminfo "*) exit 1 ;;
+*) cat '`+fixture+`' ;;
+esac
+`)
+	data, err := NewRocmSMISource(binary, time.Second).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if !equalStrings(data.Unavailable, wantMemory) {
+		t.Errorf("JSON Unavailable = %v, want %v", data.Unavailable, wantMemory)
+	}
+
+	// Text mode
+	source := NewRocmSMISource(writeFakeCommand(t, rocmSMITextScript), time.Second)
+	source.disableJSON(nil)
+	if data, err = source.Collect(context.Background()); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if !equalStrings(data.Unavailable, wantMemory) {
+		t.Errorf("text Unavailable = %v, want %v", data.Unavailable, wantMemory)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// MetricRollup summarises one metric over a rollup interval
+type MetricRollup struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	Last  float64 `json:"last"`
+	Count int     `json:"count"`
+}
+
+// GPURollup holds the per-metric summaries of one GPU; metrics never reported are absent
+type GPURollup struct {
+	ID      int
+	Metrics map[GPUField]MetricRollup
+}
+
+// MarshalJSON writes the averages under the usual GPU field names, so a rollup point can be
+// charted like a raw sample, and the full summaries under "min", "max" and "last"
+func (g GPURollup) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{"id":%d`, g.ID)
+	for _, info := range gpuFields {
+		if rollup, ok := g.Metrics[info.field]; ok {
+			fmt.Fprintf(&buf, `,"%s":%g`, info.name, rollup.Avg)
+		}
+	}
+	for _, part := range []string{"min", "max", "last"} {
+		fmt.Fprintf(&buf, `,"%s":{`, part)
+		first := true
+		for _, info := range gpuFields {
+			rollup, ok := g.Metrics[info.field]
+			if !ok {
+				continue
+			}
+			value := rollup.Last
+			switch part {
+			case "min":
+				value = rollup.Min
+			case "max":
+				value = rollup.Max
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(&buf, `"%s":%g`, info.name, value)
+			first = false
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// RollupPoint is the summary of all samples within one interval of a tier
+type RollupPoint struct {
+	Timestamp  time.Time     `json:"timestamp"` // Start of the interval
+	Resolution float64       `json:"resolution_seconds"`
+	Samples    int           `json:"samples"`
+	GPUs       []GPURollup   `json:"gpus"`
+	CPU        *MetricRollup `json:"cpu,omitempty"`
+	CPUUsage   *float64      `json:"cpu_usage,omitempty"` // Average, for charting like raw samples
+}
+
+// metricAccumulator collects min/max/sum/last of one metric
+type metricAccumulator struct {
+	min, max, sum, last float64
+	count               int
+}
+
+func (a *metricAccumulator) add(value float64) {
+	if a.count == 0 || value < a.min {
+		a.min = value
+	}
+	if a.count == 0 || value > a.max {
+		a.max = value
+	}
+	a.sum += value
+	a.last = value
+	a.count++
+}
+
+func (a *metricAccumulator) rollup() MetricRollup {
+	return MetricRollup{Min: a.min, Max: a.max, Avg: a.sum / float64(a.count), Last: a.last, Count: a.count}
+}
+
+// rollupBucket accumulates the samples of the interval starting at start
+type rollupBucket struct {
+	start   time.Time
+	samples int
+	gpus    map[int]map[GPUField]*metricAccumulator
+	gpuIDs  []int // In order of first appearance
+	cpu     metricAccumulator
+}
+
+func newRollupBucket(start time.Time) *rollupBucket {
+	return &rollupBucket{start: start, gpus: make(map[int]map[GPUField]*metricAccumulator)}
+}
+
+func (b *rollupBucket) add(data *RocmData) {
+	b.samples++
+	if data.CPUAvailable {
+		b.cpu.add(data.CPUUsage)
+	}
+	for _, gpu := range data.GPUs {
+		metrics, ok := b.gpus[gpu.ID]
+		if !ok {
+			metrics = make(map[GPUField]*metricAccumulator)
+			b.gpus[gpu.ID] = metrics
+			b.gpuIDs = append(b.gpuIDs, gpu.ID)
+		}
+		for _, info := range gpuFields {
+			value, ok := gpu.Value(info.field)
+			if !ok {
+				continue
+			}
+			if metrics[info.field] == nil {
+				metrics[info.field] = &metricAccumulator{}
+			}
+			metrics[info.field].add(value)
+		}
+	}
+}
+
+func (b *rollupBucket) point(resolution time.Duration) RollupPoint {
+	point := RollupPoint{
+		Timestamp:  b.start,
+		Resolution: resolution.Seconds(),
+		Samples:    b.samples,
+		GPUs:       make([]GPURollup, 0, len(b.gpuIDs)),
+	}
+	for _, id := range b.gpuIDs {
+		gpu := GPURollup{ID: id, Metrics: make(map[GPUField]MetricRollup, len(b.gpus[id]))}
+		for field, acc := range b.gpus[id] {
+			gpu.Metrics[field] = acc.rollup()
+		}
+		point.GPUs = append(point.GPUs, gpu)
+	}
+	if b.cpu.count > 0 {
+		cpu := b.cpu.rollup()
+		point.CPU = &cpu
+		point.CPUUsage = &cpu.Avg
+	}
+	return point
+}
+
+// RollupTier keeps downsampled history at one resolution for a retention period
+type RollupTier struct {
+	Name       string
+	Resolution time.Duration
+	Retention  time.Duration
+	points     []RollupPoint
+	current    *rollupBucket
+}
+
+// NewRollupTier creates an empty tier
+func NewRollupTier(name string, resolution, retention time.Duration) *RollupTier {
+	return &RollupTier{Name: name, Resolution: resolution, Retention: retention}
+}
+
+// Add feeds a sample into the tier, closing the current interval when the sample is past it
+func (t *RollupTier) Add(data *RocmData) {
+	start := data.Timestamp.Truncate(t.Resolution)
+	if t.current != nil && !start.Equal(t.current.start) {
+		// Samples from the past (clock steps) are folded into the open interval
+		if start.After(t.current.start) {
+			t.points = append(t.points, t.current.point(t.Resolution))
+			t.current = nil
+		} else {
+			start = t.current.start
+		}
+	}
+	if t.current == nil {
+		t.current = newRollupBucket(start)
+	}
+	t.current.add(data)
+
+	// Drop points that fell out of the retention period
+	cutoff := data.Timestamp.Add(-t.Retention)
+	drop := 0
+	for drop < len(t.points) && t.points[drop].Timestamp.Before(cutoff) {
+		drop++
+	}
+	if drop > 0 {
+		t.points = append(t.points[:0], t.points[drop:]...)
+	}
+}
+
+// Since returns the points whose interval ends after cutoff, including the open interval
+func (t *RollupTier) Since(cutoff time.Time) []RollupPoint {
+	var points []RollupPoint
+	for _, point := range t.points {
+		if point.Timestamp.Add(t.Resolution).After(cutoff) {
+			points = append(points, point)
+		}
+	}
+	if t.current != nil {
+		points = append(points, t.current.point(t.Resolution))
+	}
+	return points
+}
+
+// Oldest returns the start of the oldest interval held, or the zero time if the tier is empty
+func (t *RollupTier) Oldest() time.Time {
+	if len(t.points) > 0 {
+		return t.points[0].Timestamp
+	}
+	if t.current != nil {
+		return t.current.start
+	}
+	return time.Time{}
+}
+
+// Len returns the number of intervals held, including the open one
+func (t *RollupTier) Len() int {
+	if t.current != nil {
+		return len(t.points) + 1
+	}
+	return len(t.points)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// rollupSamples are taken every 20 seconds from 12:00:30 to 12:02:50. GPU 0 reports the
+// temperatures below, GPU 1 only appears from the fourth sample with a power of 50, and the
+// CPU usage, ten times the temperature, is only available for even samples.
+func rollupSamples() []RocmData {
+	start := time.Date(2026, 3, 1, 12, 0, 30, 0, time.UTC)
+	temperatures := []float64{5, 3, 8, 1, 9, 4, 7, 2}
+	var samples []RocmData
+	for i, temperature := range temperatures {
+		gpu0 := GPU{ID: 0}
+		gpu0.Set(FieldTemperature, temperature)
+		data := RocmData{
+			Timestamp:    start.Add(time.Duration(20*i) * time.Second),
+			GPUs:         []GPU{gpu0},
+			CPUUsage:     10 * temperature,
+			CPUAvailable: i%2 == 0,
+		}
+		if i >= 3 {
+			gpu1 := GPU{ID: 1}
+			gpu1.Set(FieldPower, 50)
+			data.GPUs = append(data.GPUs, gpu1)
+		}
+		samples = append(samples, data)
+	}
+	return samples
+}
+
+func TestRollupTier(t *testing.T) {
+	at := func(minute int) time.Time { return time.Date(2026, 3, 1, 12, minute, 0, 0, time.UTC) }
+	type gpuRollups map[int]map[GPUField]MetricRollup
+
+	tests := []struct {
+		name       string
+		resolution time.Duration
+		timestamps []time.Time
+		samples    []int
+		gpus       []gpuRollups
+		cpu        []MetricRollup
+	}{
+		{
+			name:       "1m",
+			resolution: time.Minute,
+			timestamps: []time.Time{at(0), at(1), at(2)},
+			samples:    []int{2, 3, 3},
+			gpus: []gpuRollups{
+				{0: {FieldTemperature: {Min: 3, Max: 5, Avg: 4, Last: 3, Count: 2}}},
+				{
+					0: {FieldTemperature: {Min: 1, Max: 9, Avg: 6, Last: 9, Count: 3}},
+					1: {FieldPower: {Min: 50, Max: 50, Avg: 50, Last: 50, Count: 2}},
+				},
+				{
+					0: {FieldTemperature: {Min: 2, Max: 7, Avg: 13.0 / 3, Last: 2, Count: 3}},
+					1: {FieldPower: {Min: 50, Max: 50, Avg: 50, Last: 50, Count: 3}},
+				},
+			},
+			cpu: []MetricRollup{
+				{Min: 50, Max: 50, Avg: 50, Last: 50, Count: 1},
+				{Min: 80, Max: 90, Avg: 85, Last: 90, Count: 2},
+				{Min: 70, Max: 70, Avg: 70, Last: 70, Count: 1},
+			},
+		},
+		{
+			name:       "15m",
+			resolution: 15 * time.Minute,
+			timestamps: []time.Time{at(0)},
+			samples:    []int{8},
+			gpus: []gpuRollups{{
+				0: {FieldTemperature: {Min: 1, Max: 9, Avg: 4.875, Last: 2, Count: 8}},
+				1: {FieldPower: {Min: 50, Max: 50, Avg: 50, Last: 50, Count: 5}},
+			}},
+			cpu: []MetricRollup{{Min: 50, Max: 90, Avg: 72.5, Last: 70, Count: 4}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tier := NewRollupTier(tt.name, tt.resolution, 48*time.Hour)
+			for _, data := range rollupSamples() {
+				tier.Add(&data)
+			}
+
+			points := tier.Since(time.Time{})
+			if len(points) != len(tt.timestamps) || tier.Len() != len(points) {
+				t.Fatalf("%d points, Len %d, want %d", len(points), tier.Len(), len(tt.timestamps))
+			}
+			for i, point := range points {
+				if !point.Timestamp.Equal(tt.timestamps[i]) || point.Resolution != tt.resolution.Seconds() || point.Samples != tt.samples[i] {
+					t.Errorf("point %d: %v, %vs, %d samples; want %v, %d samples", i, point.Timestamp, point.Resolution, point.Samples, tt.timestamps[i], tt.samples[i])
+				}
+				got := gpuRollups{}
+				for _, gpu := range point.GPUs {
+					got[gpu.ID] = gpu.Metrics
+				}
+				if !reflect.DeepEqual(got, tt.gpus[i]) {
+					t.Errorf("point %d GPUs %+v, want %+v", i, got, tt.gpus[i])
+				}
+				if point.CPU == nil || *point.CPU != tt.cpu[i] || *point.CPUUsage != tt.cpu[i].Avg {
+					t.Errorf("point %d CPU %+v, want %+v", i, point.CPU, tt.cpu[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRollupTierSinceAndRetention(t *testing.T) {
+	at := func(minute, second int) time.Time { return time.Date(2026, 3, 1, 12, minute, second, 0, time.UTC) }
+
+	tier := NewRollupTier("1m", time.Minute, 2*time.Minute)
+	for _, data := range rollupSamples() {
+		tier.Add(&data)
+	}
+	// The last sample at 12:02:50 keeps the intervals ending after 12:00:50
+	if oldest := tier.Oldest(); !oldest.Equal(at(1, 0)) || tier.Len() != 2 {
+		t.Errorf("oldest interval %v of %d, want 12:01 of 2", oldest, tier.Len())
+	}
+	if points := tier.Since(at(2, 0)); len(points) != 1 || !points[0].Timestamp.Equal(at(2, 0)) {
+		t.Errorf("Since(12:02) returned %d points, want the open interval", len(points))
+	}
+
+	// A sample from before the open interval, after a clock step, is folded into it
+	step := GPU{ID: 0}
+	step.Set(FieldTemperature, 100)
+	tier.Add(&RocmData{Timestamp: at(0, 0), GPUs: []GPU{step}})
+	points := tier.Since(time.Time{})
+	open := points[len(points)-1]
+	if rollup := open.GPUs[0].Metrics[FieldTemperature]; len(points) != 2 || open.Samples != 4 || rollup.Max != 100 || rollup.Last != 100 {
+		t.Errorf("after a clock step: %d points, open interval %+v", len(points), open)
+	}
+}
+
+func TestGPURollupJSON(t *testing.T) {
+	gpu := GPURollup{ID: 1, Metrics: map[GPUField]MetricRollup{
+		FieldTemperature: {Min: 3, Max: 5, Avg: 4, Last: 3, Count: 2},
+		FieldPower:       {Min: 100, Max: 150.5, Avg: 125.25, Last: 150.5, Count: 2},
+	}}
+	got, err := json.Marshal(gpu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":1,"temperature":4,"power":125.25,"min":{"temperature":3,"power":100},"max":{"temperature":5,"power":150.5},"last":{"temperature":3,"power":150.5}}`
+	if string(got) != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import "encoding/binary"
+
+// Encoder for the snappy block format, as required by Prometheus remote_write. It finds
+// matches with a single hash table lookup per position, trading some compression ratio for
+// simplicity; the output decodes with any snappy implementation.
+const (
+	snappyTableBits = 14
+	snappyMaxOffset = 1<<16 - 1 // Longest distance a 2-byte-offset copy can reach
+)
+
+// snappyEncode compresses src into a snappy block
+func snappyEncode(src []byte) []byte {
+	dst := make([]byte, 0, binary.MaxVarintLen64+len(src)+len(src)/6)
+	dst = binary.AppendUvarint(dst, uint64(len(src)))
+
+	// table maps a hash of 4 bytes to the last position they were seen at, plus one
+	table := make([]int32, 1<<snappyTableBits)
+	literal := 0
+	for i := 0; i+4 <= len(src); {
+		current := binary.LittleEndian.Uint32(src[i:])
+		hash := (current * 0x1e35a7bd) >> (32 - snappyTableBits)
+		candidate := int(table[hash]) - 1
+		table[hash] = int32(i + 1)
+		if candidate < 0 || i-candidate > snappyMaxOffset || binary.LittleEndian.Uint32(src[candidate:]) != current {
+			i++
+			continue
+		}
+
+		length := 4
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+		dst = appendSnappyLiteral(dst, src[literal:i])
+		dst = appendSnappyCopy(dst, i-candidate, length)
+		i += length
+		literal = i
+	}
+	return appendSnappyLiteral(dst, src[literal:])
+}
+
+// appendSnappyLiteral appends a literal element holding data
+func appendSnappyLiteral(dst, data []byte) []byte {
+	if len(data) == 0 {
+		return dst
+	}
+	n := uint32(len(data) - 1)
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2)
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, data...)
+}
+
+// appendSnappyCopy appends copy elements repeating length bytes from offset bytes back
+func appendSnappyCopy(dst []byte, offset, length int) []byte {
+	// A copy element holds at most 64 bytes; keep at least 4 for the last one
+	for length >= 68 {
+		dst = append(dst, 63<<2|2, byte(offset), byte(offset>>8))
+		length -= 64
+	}
+	if length > 64 {
+		dst = append(dst, 59<<2|2, byte(offset), byte(offset>>8))
+		length -= 60
+	}
+
+	// Short, near copies fit the 1-byte-offset form
+	if length < 12 && offset < 2048 {
+		return append(dst, byte(offset>>8)<<5|byte(length-4)<<2|1, byte(offset))
+	}
+	return append(dst, byte(length-1)<<2|2, byte(offset), byte(offset>>8))
+}
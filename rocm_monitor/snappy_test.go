@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// snappyDecode decompresses a snappy block, rejecting anything the format does not allow
+func snappyDecode(src []byte) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 || length > 1<<32-1 {
+		return nil, errors.New("bad length preamble")
+	}
+	src = src[n:]
+	dst := make([]byte, 0, length)
+	for len(src) > 0 {
+		tag := src[0]
+		src = src[1:]
+		var offset, size int
+		switch tag & 3 {
+		case 0:
+			size = int(tag>>2) + 1
+			if extra := int(tag>>2) - 59; extra > 0 {
+				if len(src) < extra {
+					return nil, errors.New("short literal length")
+				}
+				size = 1
+				for i := 0; i < extra; i++ {
+					size += int(src[i]) << (8 * i)
+				}
+				src = src[extra:]
+			}
+			if len(src) < size {
+				return nil, errors.New("short literal")
+			}
+			dst = append(dst, src[:size]...)
+			src = src[size:]
+			continue
+		case 1:
+			if len(src) < 1 {
+				return nil, errors.New("short copy")
+			}
+			size = int(tag>>2&7) + 4
+			offset = int(tag>>5)<<8 | int(src[0])
+			src = src[1:]
+		case 2:
+			if len(src) < 2 {
+				return nil, errors.New("short copy")
+			}
+			size = int(tag>>2) + 1
+			offset = int(binary.LittleEndian.Uint16(src))
+			src = src[2:]
+		case 3:
+			if len(src) < 4 {
+				return nil, errors.New("short copy")
+			}
+			size = int(tag>>2) + 1
+			offset = int(binary.LittleEndian.Uint32(src))
+			src = src[4:]
+		}
+		if offset <= 0 || offset > len(dst) {
+			return nil, fmt.Errorf("bad copy offset %d at %d", offset, len(dst))
+		}
+		// Copies may overlap their own output, so go byte by byte
+		for i := 0; i < size; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if uint64(len(dst)) != length {
+		return nil, fmt.Errorf("decoded %d bytes, preamble says %d", len(dst), length)
+	}
+	return dst, nil
+}
+
+func TestSnappyDecode(t *testing.T) {
+	// Hand-assembled blocks, so the decoder is not only checked against the encoder
+	tests := []struct {
+		name  string
+		block []byte
+		want  string
+	}{
+		{"empty", []byte{0}, ""},
+		{"literal", []byte{5, 4 << 2, 'h', 'e', 'l', 'l', 'o'}, "hello"},
+		{"overlapping 1-byte-offset copy", []byte{10, 2 << 2, 'a', 'b', 'c', 3<<2 | 1, 3}, "abcabcabca"},
+		{"2-byte-offset copy", []byte{10, 2 << 2, 'a', 'b', 'c', 6<<2 | 2, 3, 0}, "abcabcabca"},
+		{"4-byte-offset copy", []byte{10, 2 << 2, 'a', 'b', 'c', 6<<2 | 3, 3, 0, 0, 0}, "abcabcabca"},
+		{"literal with a length byte", append([]byte{61, 60 << 2, 60}, bytes.Repeat([]byte{'x'}, 61)...), string(bytes.Repeat([]byte{'x'}, 61))},
+	}
+	for _, tt := range tests {
+		got, err := snappyDecode(tt.block)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	for _, block := range [][]byte{
+		{},
+		{3, 2 << 2, 'a', 'b'},           // Short literal
+		{4, 0, 'a', 3<<2 | 1, 2},        // Copy reaching before the start
+		{2, 0, 'a', 0<<2 | 1},           // Copy without its offset byte
+		{5, 4 << 2, 'h', 'e', 'l', 'l'}, // Truncated
+		{4, 2 << 2, 'a', 'b', 'c'},      // Shorter than the preamble
+	} {
+		if got, err := snappyDecode(block); err == nil {
+			t.Errorf("snappyDecode(%v) = %q, want an error", block, got)
+		}
+	}
+}
+
+// snappyInputs are the encoder test cases, also used to seed the fuzzer
+func snappyInputs() map[string][]byte {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	// Repeats further back than a 2-byte offset can reach
+	far := append(append(append([]byte(nil), random[:1000]...), make([]byte, snappyMaxOffset)...), random[:1000]...)
+
+	var text bytes.Buffer
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&text, "rocm_gpu_temperature_celsius{gpu_id=\"%d\",vendor=\"AMD\"} %d\n", i%4, 40+i%17)
+	}
+
+	return map[string][]byte{
+		"empty":           nil,
+		"single byte":     {'a'},
+		"under a match":   []byte("abc"),
+		"short match":     []byte("abcdabcd"),
+		"run":             bytes.Repeat([]byte{0}, 1000),
+		"run of 67":       append([]byte{1}, bytes.Repeat([]byte{0}, 68)...),
+		"run of 68":       append([]byte{1}, bytes.Repeat([]byte{0}, 69)...),
+		"long literal":    random[:300],
+		"random":          random,
+		"far repeat":      far,
+		"near 2048":       append(append(append([]byte(nil), random[:2100]...), random[:8]...), random[60:70]...),
+		"exposition text": text.Bytes(),
+	}
+}
+
+func TestSnappyEncodeRoundTrip(t *testing.T) {
+	for name, input := range snappyInputs() {
+		encoded := snappyEncode(input)
+		decoded, err := snappyDecode(encoded)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(decoded, input) {
+			t.Errorf("%s: round trip changed the data", name)
+		}
+		if name == "run" || name == "exposition text" {
+			if len(encoded) > len(input)/4 {
+				t.Errorf("%s: %d bytes compressed to %d", name, len(input), len(encoded))
+			}
+		}
+	}
+}
+
+func FuzzSnappyEncode(f *testing.F) {
+	for _, input := range snappyInputs() {
+		f.Add(input)
+	}
+	f.Fuzz(func(t *testing.T, input []byte) {
+		decoded, err := snappyDecode(snappyEncode(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, input) {
+			t.Fatalf("round trip changed %q into %q", input, decoded)
+		}
+	})
+}
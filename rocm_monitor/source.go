@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Source is a GPU data backend that produces monitoring snapshots
+type Source interface {
+	// Name returns the identifier used to select the source with -source
+	Name() string
+	// Collect gathers a single monitoring snapshot
+	Collect(ctx context.Context) (*RocmData, error)
+	// StaticInfo returns static information for the detected GPUs
+	StaticInfo(ctx context.Context) ([]GPUStaticInfo, error)
+}
+
+// SourceConfig holds backend specific settings for data sources
+type SourceConfig struct {
+	RocmSMIPath  string
+	AmdSMIPath   string
+	SysfsRoot    string
+	QueryTimeout time.Duration
+}
+
+// sourceFactories maps -source names to their constructors
+var sourceFactories = map[string]func(SourceConfig) (Source, error){
+	"auto": detectSource,
+	"rocm-smi": func(config SourceConfig) (Source, error) {
+		return NewRocmSMISource(config.RocmSMIPath, config.QueryTimeout), nil
+	},
+	"amd-smi": func(config SourceConfig) (Source, error) {
+		return NewAmdSMISource(config.AmdSMIPath), nil
+	},
+	"sysfs": func(config SourceConfig) (Source, error) {
+		return NewSysfsSource(config.SysfsRoot), nil
+	},
+}
+
+// detectSource picks the first available backend: rocm-smi, then amd-smi, then sysfs
+func detectSource(config SourceConfig) (Source, error) {
+	rocmSMI := NewRocmSMISource(config.RocmSMIPath, config.QueryTimeout)
+	if _, err := exec.LookPath(rocmSMI.binary); err == nil {
+		return rocmSMI, nil
+	}
+
+	amdSMI := NewAmdSMISource(config.AmdSMIPath)
+	if _, err := exec.LookPath(amdSMI.binary); err == nil {
+		return amdSMI, nil
+	}
+
+	sysfs := NewSysfsSource(config.SysfsRoot)
+	if _, err := sysfs.cards(); err == nil {
+		return sysfs, nil
+	}
+
+	return nil, fmt.Errorf("no GPU data source found: neither %s nor %s is installed and no amdgpu devices are visible in %s",
+		rocmSMI.binary, amdSMI.binary, config.SysfsRoot)
+}
+
+// NewSource creates the data source registered under the given name
+func NewSource(name string, config SourceConfig) (Source, error) {
+	factory, ok := sourceFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q (available: %s)", name, strings.Join(SourceNames(), ", "))
+	}
+	return factory(config)
+}
+
+// SourceNames returns the sorted list of registered source names
+func SourceNames() []string {
+	names := make([]string, 0, len(sourceFactories))
+	for name := range sourceFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
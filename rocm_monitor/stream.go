@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Server-Sent Events stream of samples. Event IDs are sample timestamps in Unix nanoseconds,
+// so a client can resume from the in-memory history after a reconnect or a server restart.
+const (
+	streamHeartbeat = 15 * time.Second
+	streamBuffer    = 16 // Samples a client may fall behind before it is disconnected
+	streamRetry     = 3 * time.Second
+)
+
+// streamHandler pushes every stored sample as an SSE event. The Last-Event-ID header, or the
+// last_event_id parameter for a first connect, replays the samples taken after that event.
+func streamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var last time.Time
+	if lastID != "" {
+		nanos, err := strconv.ParseInt(lastID, 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid event id %q", lastID), http.StatusBadRequest)
+			return
+		}
+		last = time.Unix(0, nanos)
+	}
+
+	// Subscribe before reading the history so no sample falls between the two
+	sub := collector.Subscribe(streamBuffer)
+	defer collector.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds()); err != nil {
+		return
+	}
+
+	if !last.IsZero() {
+		for _, data := range collector.GetHistorySince(last) {
+			if err := writeStreamEvent(w, &data); err != nil {
+				return
+			}
+			last = data.Timestamp
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client resumes from the history on reconnect
+				return
+			}
+			if !data.Timestamp.After(last) {
+				continue // Already sent from the history
+			}
+			if err := writeStreamEvent(w, &data); err != nil {
+				return
+			}
+			last = data.Timestamp
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeStreamEvent writes a sample as one SSE event
+func writeStreamEvent(w io.Writer, data *RocmData) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: sample\ndata: %s\n\n", data.Timestamp.UnixNano(), payload)
+	return err
+}
@@ -0,0 +1,70 @@
+package main
+
+import "sync"
+
+// Subscription delivers each sample the collector stores. A subscriber that falls more than
+// its buffer behind is dropped and C is closed, so a slow reader never blocks collection.
+type Subscription struct {
+	C  <-chan RocmData
+	ch chan RocmData
+}
+
+// subscriberSet holds the live subscriptions of a collector
+type subscriberSet struct {
+	mutex   sync.Mutex
+	subs    map[*Subscription]struct{}
+	dropped uint64 // Subscriptions closed because they fell behind
+}
+
+// Subscribe registers for new samples with room for buffer undelivered samples
+func (c *Collector) Subscribe(buffer int) *Subscription {
+	if buffer < 1 {
+		buffer = 1
+	}
+	ch := make(chan RocmData, buffer)
+	sub := &Subscription{C: ch, ch: ch}
+
+	c.subscribers.mutex.Lock()
+	defer c.subscribers.mutex.Unlock()
+	if c.subscribers.subs == nil {
+		c.subscribers.subs = make(map[*Subscription]struct{})
+	}
+	c.subscribers.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe stops delivery and closes C; it is safe to call more than once
+func (c *Collector) Unsubscribe(sub *Subscription) {
+	c.subscribers.mutex.Lock()
+	defer c.subscribers.mutex.Unlock()
+
+	if _, ok := c.subscribers.subs[sub]; ok {
+		delete(c.subscribers.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// publish hands a stored sample to every subscriber without blocking
+func (c *Collector) publish(data RocmData) {
+	c.subscribers.mutex.Lock()
+	defer c.subscribers.mutex.Unlock()
+
+	for sub := range c.subscribers.subs {
+		select {
+		case sub.ch <- data:
+		default:
+			// Full buffer: drop the subscriber rather than wait for it
+			delete(c.subscribers.subs, sub)
+			close(sub.ch)
+			c.subscribers.dropped++
+		}
+	}
+}
+
+// subscriberCounts returns the number of live and dropped subscriptions
+func (c *Collector) subscriberCounts() (live int, dropped uint64) {
+	c.subscribers.mutex.Lock()
+	defer c.subscribers.mutex.Unlock()
+
+	return len(c.subscribers.subs), c.subscribers.dropped
+}
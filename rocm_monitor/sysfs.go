@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// amdVendorID is the PCI vendor ID of AMD/ATI devices
+const amdVendorID = "0x1002"
+
+// SysfsSource collects data directly from the amdgpu sysfs and hwmon interfaces
+type SysfsSource struct {
+	root        string
+	cardRegex   *regexp.Regexp
+	dpmRegex    *regexp.Regexp
+	fwNameRegex *regexp.Regexp
+}
+
+// NewSysfsSource creates a sysfs backed source reading below root (normally /sys)
+func NewSysfsSource(root string) *SysfsSource {
+	if root == "" {
+		root = "/sys"
+	}
+	return &SysfsSource{
+		root:        root,
+		cardRegex:   regexp.MustCompile(`^card(\d+)$`),
+		dpmRegex:    regexp.MustCompile(`(\d+)\s*[Mm][Hh]z\s*\*`), // Active DPM level is marked with "*"
+		fwNameRegex: regexp.MustCompile(`^(.+)_fw_version$`),
+	}
+}
+
+// Name returns the source identifier
+func (s *SysfsSource) Name() string {
+	return "sysfs"
+}
+
+// sysfsCard is an amdgpu DRM card found in sysfs
+type sysfsCard struct {
+	id        int
+	deviceDir string
+	hwmonDir  string
+}
+
+// cards returns the amdgpu cards sorted by DRM card number
+func (s *SysfsSource) cards() ([]sysfsCard, error) {
+	drmDir := filepath.Join(s.root, "class", "drm")
+	entries, err := os.ReadDir(drmDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", drmDir, err)
+	}
+
+	type numbered struct {
+		num int
+		dir string
+	}
+	var found []numbered
+	for _, entry := range entries {
+		// Skip connector entries like card0-DP-1
+		matches := s.cardRegex.FindStringSubmatch(entry.Name())
+		if len(matches) < 2 {
+			continue
+		}
+		deviceDir := filepath.Join(drmDir, entry.Name(), "device")
+		if vendor, err := readSysfsString(filepath.Join(deviceDir, "vendor")); err != nil || vendor != amdVendorID {
+			continue
+		}
+		num, _ := strconv.Atoi(matches[1])
+		found = append(found, numbered{num: num, dir: deviceDir})
+	}
+
+	if len(found) == 0 {
+		return nil, fmt.Errorf("no amdgpu devices found in %s", drmDir)
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].num < found[j].num })
+
+	cards := make([]sysfsCard, 0, len(found))
+	for id, f := range found {
+		card := sysfsCard{id: id, deviceDir: f.dir}
+		if hwmons, _ := filepath.Glob(filepath.Join(f.dir, "hwmon", "hwmon*")); len(hwmons) > 0 {
+			sort.Strings(hwmons)
+			card.hwmonDir = hwmons[0]
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// Collect reads the current metrics of every amdgpu card
+func (s *SysfsSource) Collect(ctx context.Context) (*RocmData, error) {
+	cards, err := s.cards()
+	if err != nil {
+		return nil, execError(fmt.Errorf("sysfs discovery failed: %w", err))
+	}
+
+	data := &RocmData{
+		Timestamp: time.Now(),
+		GPUs:      make([]GPU, 0, len(cards)),
+	}
+
+	for _, card := range cards {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		data.GPUs = append(data.GPUs, s.readGPU(card))
+	}
+
+	return data, nil
+}
+
+// readGPU fills a GPU from the device and hwmon attributes of a card
+func (s *SysfsSource) readGPU(card sysfsCard) GPU {
+	gpu := GPU{ID: card.id}
+	dev := card.deviceDir
+
+	if name, err := readSysfsString(filepath.Join(dev, "product_name")); err == nil {
+		gpu.Name = name
+	}
+	if id, err := readSysfsString(filepath.Join(dev, "unique_id")); err == nil && id != "" {
+		gpu.UniqueID = id
+	}
+	if busy, err := readSysfsFloat(filepath.Join(dev, "gpu_busy_percent")); err == nil {
+		gpu.Set(FieldGPUUsage, busy)
+	}
+
+	// Memory counters are reported in bytes
+	if used, err := readSysfsFloat(filepath.Join(dev, "mem_info_vram_used")); err == nil {
+		gpu.Set(FieldVRAMUsage, used/(1024*1024*1024))
+	}
+	if total, err := readSysfsFloat(filepath.Join(dev, "mem_info_vram_total")); err == nil {
+		gpu.Set(FieldVRAMTotal, total/(1024*1024*1024))
+	}
+	if used, err := readSysfsFloat(filepath.Join(dev, "mem_info_vis_vram_used")); err == nil {
+		gpu.Set(FieldVisVRAMUsage, used/(1024*1024*1024))
+	}
+	if total, err := readSysfsFloat(filepath.Join(dev, "mem_info_vis_vram_total")); err == nil {
+		gpu.Set(FieldVisVRAMTotal, total/(1024*1024*1024))
+	}
+	if used, err := readSysfsFloat(filepath.Join(dev, "mem_info_gtt_used")); err == nil {
+		gpu.Set(FieldGTTUsage, used/(1024*1024*1024))
+	}
+	if total, err := readSysfsFloat(filepath.Join(dev, "mem_info_gtt_total")); err == nil {
+		gpu.Set(FieldGTTTotal, total/(1024*1024*1024))
+	}
+
+	if freq, err := s.readDPMLevel(filepath.Join(dev, "pp_dpm_sclk")); err == nil {
+		gpu.Set(FieldSCLKFreq, freq)
+	}
+	if freq, err := s.readDPMLevel(filepath.Join(dev, "pp_dpm_mclk")); err == nil {
+		gpu.Set(FieldMCLKFreq, freq)
+	}
+
+	if card.hwmonDir == "" {
+		return gpu
+	}
+	hwmon := card.hwmonDir
+
+	// Temperatures are reported in millidegrees Celsius
+	if temp, err := readSysfsFloat(s.edgeTempInput(hwmon)); err == nil {
+		gpu.Set(FieldTemperature, temp/1000)
+	}
+
+	// Power is reported in microwatts; newer kernels expose power1_input instead of power1_average
+	if power, err := readSysfsFloat(filepath.Join(hwmon, "power1_average")); err == nil {
+		gpu.Set(FieldPower, power/1000000)
+	} else if power, err := readSysfsFloat(filepath.Join(hwmon, "power1_input")); err == nil {
+		gpu.Set(FieldPower, power/1000000)
+	}
+
+	if fan, ok := readFanPercent(hwmon); ok {
+		gpu.Set(FieldFanSpeed, fan)
+	}
+
+	return gpu
+}
+
+// edgeTempInput returns the temperature input labelled "edge", falling back to temp1_input
+func (s *SysfsSource) edgeTempInput(hwmonDir string) string {
+	labels, _ := filepath.Glob(filepath.Join(hwmonDir, "temp*_label"))
+	for _, labelPath := range labels {
+		if label, err := readSysfsString(labelPath); err == nil && label == "edge" {
+			return strings.TrimSuffix(labelPath, "_label") + "_input"
+		}
+	}
+	return filepath.Join(hwmonDir, "temp1_input")
+}
+
+// readDPMLevel returns the active clock in MHz from a pp_dpm_* table
+func (s *SysfsSource) readDPMLevel(path string) (float64, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	matches := s.dpmRegex.FindStringSubmatch(string(content))
+	if len(matches) < 2 {
+		return 0, fmt.Errorf("no active DPM level in %s", path)
+	}
+	return strconv.ParseFloat(matches[1], 64)
+}
+
+// readFanPercent returns the fan speed as a percentage of its maximum
+func readFanPercent(hwmonDir string) (float64, bool) {
+	rpm, rpmErr := readSysfsFloat(filepath.Join(hwmonDir, "fan1_input"))
+	maxRPM, maxErr := readSysfsFloat(filepath.Join(hwmonDir, "fan1_max"))
+	if rpmErr == nil && maxErr == nil && maxRPM > 0 {
+		return rpm / maxRPM * 100, true
+	}
+
+	// Fall back to the PWM duty cycle (0-255 unless pwm1_max says otherwise)
+	pwm, err := readSysfsFloat(filepath.Join(hwmonDir, "pwm1"))
+	if err != nil {
+		return 0, false
+	}
+	pwmMax, err := readSysfsFloat(filepath.Join(hwmonDir, "pwm1_max"))
+	if err != nil || pwmMax <= 0 {
+		pwmMax = 255
+	}
+	return pwm / pwmMax * 100, true
+}
+
+// StaticInfo returns static information read from the device attributes
+func (s *SysfsSource) StaticInfo(ctx context.Context) ([]GPUStaticInfo, error) {
+	cards, err := s.cards()
+	if err != nil {
+		return nil, execError(fmt.Errorf("sysfs discovery failed: %w", err))
+	}
+
+	infos := make([]GPUStaticInfo, 0, len(cards))
+	for _, card := range cards {
+		dev := card.deviceDir
+		info := GPUStaticInfo{
+			ID:           card.id,
+			ProductName:  readSysfsStringOr(filepath.Join(dev, "product_name"), "Not Available"),
+			VendorName:   "AMD",
+			SerialNumber: readSysfsStringOr(filepath.Join(dev, "serial_number"), "Not Available"),
+			UniqueID:     readSysfsStringOr(filepath.Join(dev, "unique_id"), "Not Available"),
+			FirmwareInfo: s.readFirmware(dev),
+			VRAMVendor:   readSysfsStringOr(filepath.Join(dev, "mem_info_vram_vendor"), "Not Available"),
+			BusInfo:      "Unknown",
+		}
+
+		// The device directory links to the PCI device, whose name is the bus address
+		if target, err := filepath.EvalSymlinks(dev); err == nil && strings.Contains(filepath.Base(target), ":") {
+			info.BusInfo = strings.ToUpper(filepath.Base(target))
+		}
+
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// readFirmware collects fw_version/*_fw_version entries keyed like rocm-smi does
+func (s *SysfsSource) readFirmware(deviceDir string) map[string]string {
+	fwInfo := make(map[string]string)
+
+	if vbios, err := readSysfsString(filepath.Join(deviceDir, "vbios_version")); err == nil {
+		fwInfo["VBIOS version"] = vbios
+	}
+
+	files, _ := filepath.Glob(filepath.Join(deviceDir, "fw_version", "*_fw_version"))
+	for _, file := range files {
+		matches := s.fwNameRegex.FindStringSubmatch(filepath.Base(file))
+		if len(matches) < 2 {
+			continue
+		}
+		value, err := readSysfsString(file)
+		if err != nil {
+			continue
+		}
+		fwInfo[strings.ToUpper(matches[1])+" firmware version"] = value
+	}
+
+	return fwInfo
+}
+
+// readSysfsString reads a sysfs attribute and trims surrounding whitespace
+func readSysfsString(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// readSysfsStringOr reads a sysfs attribute, returning fallback when it is missing or empty
+func readSysfsStringOr(path, fallback string) string {
+	value, err := readSysfsString(path)
+	if err != nil || value == "" {
+		return fallback
+	}
+	return value
+}
+
+// readSysfsFloat reads a numeric sysfs attribute
+func readSysfsFloat(path string) (float64, error) {
+	value, err := readSysfsString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(value, 64)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSysfsTree creates the given files, relative to root, with their contents
+func writeSysfsTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSysfsSourceCollect(t *testing.T) {
+	root := t.TempDir()
+	writeSysfsTree(t, root, map[string]string{
+		// A fully populated discrete GPU
+		"class/drm/card0/device/vendor":                      "0x1002\n",
+		"class/drm/card0/device/product_name":                "Radeon RX 7900 XTX\n",
+		"class/drm/card0/device/unique_id":                   "3a0f4e4a1c2b5d61\n",
+		"class/drm/card0/device/gpu_busy_percent":            "37\n",
+		"class/drm/card0/device/mem_info_vram_used":          "2147483648\n",
+		"class/drm/card0/device/mem_info_vram_total":         "25769803776\n",
+		"class/drm/card0/device/mem_info_vis_vram_used":      "1073741824\n",
+		"class/drm/card0/device/mem_info_vis_vram_total":     "25769803776\n",
+		"class/drm/card0/device/mem_info_gtt_used":           "536870912\n",
+		"class/drm/card0/device/mem_info_gtt_total":          "33285996544\n",
+		"class/drm/card0/device/pp_dpm_sclk":                 "0: 500Mhz\n1: 2304Mhz *\n2: 2482Mhz\n",
+		"class/drm/card0/device/pp_dpm_mclk":                 "0: 96Mhz\n1: 1249Mhz *\n",
+		"class/drm/card0/device/hwmon/hwmon3/temp1_label":    "junction\n",
+		"class/drm/card0/device/hwmon/hwmon3/temp1_input":    "71000\n",
+		"class/drm/card0/device/hwmon/hwmon3/temp2_label":    "edge\n",
+		"class/drm/card0/device/hwmon/hwmon3/temp2_input":    "54000\n",
+		"class/drm/card0/device/hwmon/hwmon3/power1_input":   "212000000\n",
+		"class/drm/card0/device/hwmon/hwmon3/fan1_input":     "1200\n",
+		"class/drm/card0/device/hwmon/hwmon3/fan1_max":       "3000\n",
+		"class/drm/card0-DP-1/status":                        "connected\n",
+		"class/drm/renderD128/device/vendor":                 "0x1002\n",
+		"class/drm/card1/device/vendor":                      "0x8086\n",
+		"class/drm/card1/device/gpu_busy_percent":            "99\n",
+		"class/drm/card2/device/vendor":                      "0x1002\n",
+		"class/drm/card2/device/gpu_busy_percent":            "5\n",
+		"class/drm/card2/device/mem_info_vram_used":          "not a number\n",
+		"class/drm/card2/device/pp_dpm_sclk":                 "0: 400Mhz\n1: 2900Mhz\n",
+		"class/drm/card2/device/hwmon/hwmon5/temp1_input":    "40000\n",
+		"class/drm/card2/device/hwmon/hwmon5/power1_average": "15000000\n",
+		"class/drm/card2/device/hwmon/hwmon5/pwm1":           "51\n",
+	})
+
+	data, err := NewSysfsSource(root).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(data.GPUs) != 2 {
+		t.Fatalf("got %d GPUs, want 2 (card0 and card2; the Intel card and connectors are skipped)", len(data.GPUs))
+	}
+
+	tests := []struct {
+		name   string
+		gpu    GPU
+		id     int
+		values map[GPUField]float64 // Every other field must be unavailable
+	}{
+		{
+			name: "card0",
+			gpu:  data.GPUs[0],
+			id:   0,
+			values: map[GPUField]float64{
+				FieldGPUUsage:     37,
+				FieldVRAMUsage:    2,
+				FieldVRAMTotal:    24,
+				FieldVisVRAMUsage: 1,
+				FieldVisVRAMTotal: 24,
+				FieldGTTUsage:     0.5,
+				FieldGTTTotal:     33285996544.0 / (1 << 30),
+				FieldSCLKFreq:     2304,
+				FieldMCLKFreq:     1249,
+				FieldTemperature:  54,
+				FieldPower:        212,
+				FieldFanSpeed:     40,
+			},
+		},
+		{
+			name: "card2 with missing and unreadable files",
+			gpu:  data.GPUs[1],
+			id:   1,
+			values: map[GPUField]float64{
+				FieldGPUUsage:    5,
+				FieldTemperature: 40,
+				FieldPower:       15,
+				FieldFanSpeed:    20,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.gpu.ID != tt.id {
+				t.Errorf("ID = %d, want %d", tt.gpu.ID, tt.id)
+			}
+			assertGPUFields(t, tt.gpu, tt.values)
+		})
+	}
+	if name := data.GPUs[0].Name; name != "Radeon RX 7900 XTX" {
+		t.Errorf("card0 name = %q", name)
+	}
+	if data.GPUs[0].UniqueID != "3a0f4e4a1c2b5d61" || data.GPUs[1].UniqueID != "" {
+		t.Errorf("unique IDs = %q, %q", data.GPUs[0].UniqueID, data.GPUs[1].UniqueID)
+	}
+}
+
+func TestSysfsSourceNoDevices(t *testing.T) {
+	root := t.TempDir()
+	writeSysfsTree(t, root, map[string]string{
+		"class/drm/card0/device/vendor": "0x10de\n",
+	})
+	if _, err := NewSysfsSource(root).Collect(context.Background()); err == nil {
+		t.Fatal("Collect succeeded without amdgpu devices")
+	}
+	if _, err := NewSysfsSource(t.TempDir()).Collect(context.Background()); err == nil {
+		t.Fatal("Collect succeeded without a drm class directory")
+	}
+}
+
+func TestSysfsSourceStaticInfo(t *testing.T) {
+	root := t.TempDir()
+	writeSysfsTree(t, root, map[string]string{
+		"class/drm/card0/device/vendor":                    "0x1002\n",
+		"class/drm/card0/device/product_name":              "Radeon RX 7900 XTX\n",
+		"class/drm/card0/device/unique_id":                 "\n",
+		"class/drm/card0/device/vbios_version":             "113-D7020100-102\n",
+		"class/drm/card0/device/fw_version/mec_fw_version": "0x000001f6\n",
+		"class/drm/card0/device/fw_version/smc_fw_version": "0x00504b00\n",
+		"class/drm/card0/device/fw_version/not_a_fw_entry": "1\n",
+		"class/drm/card0/device/mem_info_vram_vendor":      "samsung\n",
+	})
+
+	infos, err := NewSysfsSource(root).StaticInfo(context.Background())
+	if err != nil {
+		t.Fatalf("StaticInfo: %v", err)
+	}
+	if len(infos) != 1 {
+		t.Fatalf("got %d GPUs, want 1", len(infos))
+	}
+	info := infos[0]
+	if info.ProductName != "Radeon RX 7900 XTX" || info.VRAMVendor != "samsung" {
+		t.Errorf("product %q, VRAM vendor %q", info.ProductName, info.VRAMVendor)
+	}
+	if info.SerialNumber != "Not Available" || info.UniqueID != "Not Available" {
+		t.Errorf("missing and empty attributes: serial %q, unique ID %q", info.SerialNumber, info.UniqueID)
+	}
+	wantFW := map[string]string{
+		"VBIOS version":        "113-D7020100-102",
+		"MEC firmware version": "0x000001f6",
+		"SMC firmware version": "0x00504b00",
+	}
+	if len(info.FirmwareInfo) != len(wantFW) {
+		t.Errorf("firmware = %v, want %v", info.FirmwareInfo, wantFW)
+	}
+	for key, want := range wantFW {
+		if got := info.FirmwareInfo[key]; got != want {
+			t.Errorf("firmware %q = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -4,15 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"os/exec"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
 // ROCmTestResult represents the result of a single ROCm test
 type ROCmTestResult struct {
+	Name        string    `json:"name"`
 	Command     string    `json:"command"`
 	Success     bool      `json:"success"`
 	Output      string    `json:"output"`
@@ -148,6 +151,7 @@ func (rt *ROCmTester) RunTests() *ROCmTestSuite {
 // runSingleTest executes a single test command
 func (rt *ROCmTester) runSingleTest(name, command string, args []string, description string) ROCmTestResult {
 	result := ROCmTestResult{
+		Name:      name,
 		Command:   fmt.Sprintf("%s %s", command, strings.Join(args, " ")),
 		Timestamp: time.Now(),
 		Issues:    []string{},
@@ -356,16 +360,73 @@ func (rt *ROCmTester) generateSummary(suite *ROCmTestSuite) string {
 	return summary
 }
 
-// rocmTestHandler handles the /api/rocm-test endpoint
+// ROCmTestStore runs the diagnostics one at a time and keeps the latest result
+type ROCmTestStore struct {
+	tester   *ROCmTester
+	runMutex sync.Mutex // Serialises runs
+	mutex    sync.RWMutex
+	latest   *ROCmTestSuite
+}
+
+// NewROCmTestStore creates a store with no result yet
+func NewROCmTestStore() *ROCmTestStore {
+	return &ROCmTestStore{tester: NewROCmTester()}
+}
+
+// Run executes the test suite, waiting for a run already in progress first, and stores the result
+func (s *ROCmTestStore) Run() *ROCmTestSuite {
+	s.runMutex.Lock()
+	defer s.runMutex.Unlock()
+
+	suite := s.tester.RunTests()
+
+	s.mutex.Lock()
+	s.latest = suite
+	s.mutex.Unlock()
+	return suite
+}
+
+// Latest returns the most recent result, or nil if the suite has not run yet
+func (s *ROCmTestStore) Latest() *ROCmTestSuite {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.latest
+}
+
+// Schedule runs the suite now and then every interval in the background
+func (s *ROCmTestStore) Schedule(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			suite := s.Run()
+			if !suite.OverallSuccess {
+				log.Printf("Scheduled ROCm diagnostics failed: %s", suite.Summary)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// rocmTestHandler handles the /api/rocm-test endpoint: POST runs the diagnostics, GET returns
+// the latest result
 func rocmTestHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+	var results *ROCmTestSuite
+	switch r.Method {
+	case http.MethodPost:
+		results = rocmTests.Run()
+	case http.MethodGet:
+		results = rocmTests.Latest()
+		if results == nil {
+			http.Error(w, "ROCm diagnostics have not run yet", http.StatusNotFound)
+			return
+		}
+	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	tester := NewROCmTester()
-	results := tester.RunTests()
-
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(results); err != nil {
 		http.Error(w, "Failed to encode test results", http.StatusInternalServerError)
@@ -0,0 +1,12 @@
+#!/bin/sh
+# Stand-in for amd-smi that replays captured --json output for offline testing:
+#   AMD_SMI_FIXTURE=rocm-6.4 ./rocm-monitor -source amd-smi -amd-smi-path testdata/amd-smi/stub.sh
+dir="$(dirname "$0")/${AMD_SMI_FIXTURE:-rocm-6.4}"
+
+case "$1" in
+metric | static | firmware) cat "$dir/$1.json" ;;
+*)
+	echo "stub: no captured output for: $*" >&2
+	exit 2
+	;;
+esac
@@ -0,0 +1,422 @@
+// Command receiver is a stub push target for trying out the monitor's push exporters
+// without a metrics backend:
+//
+//	go run ./testdata/receiver -addr :9201
+//	./rocm-monitor -remote-write-url http://localhost:9201/api/v1/write
+//	./rocm-monitor -influx-url http://localhost:9201 -influx-org lab -influx-bucket gpus
+//	./rocm-monitor -otlp-endpoint http://localhost:9201
+//
+// It decodes every request and prints the series or lines it holds.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":9201", "Listen address")
+	failFirst := flag.Int("fail-first", 0, "Answer the first N requests with 503 to exercise retries")
+	verbose := flag.Bool("v", false, "Print every sample instead of a summary")
+	flag.Parse()
+
+	var mutex sync.Mutex
+	requests := 0
+	// accept numbers requests across endpoints and fails the first ones if asked to
+	accept := func(w http.ResponseWriter, kind string) (int, bool) {
+		mutex.Lock()
+		requests++
+		n := requests
+		mutex.Unlock()
+		if n <= *failFirst {
+			log.Printf("%s #%d: failing on purpose", kind, n)
+			http.Error(w, "failing on purpose", http.StatusServiceUnavailable)
+			return n, false
+		}
+		return n, true
+	}
+
+	http.HandleFunc("/api/v1/write", func(w http.ResponseWriter, r *http.Request) {
+		n, ok := accept(w, "remote_write")
+		if !ok {
+			return
+		}
+
+		if r.Header.Get("Content-Encoding") != "snappy" {
+			http.Error(w, "expected snappy encoding", http.StatusBadRequest)
+			return
+		}
+		compressed, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, err := snappyDecode(compressed)
+		if err != nil {
+			http.Error(w, "snappy: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		series, err := decodeWriteRequest(body)
+		if err != nil {
+			http.Error(w, "protobuf: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		samples := 0
+		var oldest, newest int64 = math.MaxInt64, math.MinInt64
+		for _, s := range series {
+			samples += len(s.samples)
+			for _, sample := range s.samples {
+				if sample.timestamp < oldest {
+					oldest = sample.timestamp
+				}
+				if sample.timestamp > newest {
+					newest = sample.timestamp
+				}
+				if *verbose {
+					fmt.Printf("  %s %g %d\n", s.labels, sample.value, sample.timestamp)
+				}
+			}
+		}
+		log.Printf("remote_write #%d: %d bytes (%d uncompressed), %d series, %d samples, %s to %s",
+			n, len(compressed), len(body), len(series), samples,
+			time.UnixMilli(oldest).Format(time.RFC3339), time.UnixMilli(newest).Format(time.RFC3339))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	http.HandleFunc("/api/v2/write", func(w http.ResponseWriter, r *http.Request) {
+		n, ok := accept(w, "influx")
+		if !ok {
+			return
+		}
+
+		query := r.URL.Query()
+		if query.Get("org") == "" || query.Get("bucket") == "" {
+			http.Error(w, "org and bucket are required", http.StatusBadRequest)
+			return
+		}
+		if precision := query.Get("precision"); precision != "ns" {
+			http.Error(w, "expected precision=ns, got "+precision, http.StatusBadRequest)
+			return
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, "gzip: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		measurements := make(map[string]int)
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		lines := 0
+		for scanner.Scan() {
+			line := scanner.Text()
+			parts := splitLine(line)
+			if len(parts) != 3 {
+				http.Error(w, fmt.Sprintf("line %d: expected measurement, fields and timestamp", lines+1), http.StatusBadRequest)
+				return
+			}
+			measurements[strings.SplitN(parts[0], ",", 2)[0]]++
+			lines++
+			if *verbose {
+				fmt.Printf("  %s\n", line)
+			}
+		}
+		log.Printf("influx #%d: org=%s bucket=%s auth=%q, %d lines %v",
+			n, query.Get("org"), query.Get("bucket"), r.Header.Get("Authorization"), lines, measurements)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	http.HandleFunc("/v1/metrics", func(w http.ResponseWriter, r *http.Request) {
+		n, ok := accept(w, "otlp")
+		if !ok {
+			return
+		}
+
+		if r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "only the JSON encoding is supported", http.StatusUnsupportedMediaType)
+			return
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, "gzip: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		var request otlpRequest
+		decoder := json.NewDecoder(body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&request); err != nil {
+			http.Error(w, "json: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		points := 0
+		for _, resource := range request.ResourceMetrics {
+			var attributes []string
+			for _, attribute := range resource.Resource.Attributes {
+				attributes = append(attributes, attribute.Key+"="+attribute.Value.StringValue)
+			}
+			if *verbose {
+				fmt.Printf("  resource %s\n", strings.Join(attributes, " "))
+			}
+			for _, scope := range resource.ScopeMetrics {
+				for _, metric := range scope.Metrics {
+					points += len(metric.Gauge.DataPoints)
+					if *verbose && len(metric.Gauge.DataPoints) > 0 {
+						point := metric.Gauge.DataPoints[len(metric.Gauge.DataPoints)-1]
+						fmt.Printf("    %s [%s] %g at %s (%d points)\n", metric.Name, metric.Unit, point.AsDouble, point.TimeUnixNano, len(metric.Gauge.DataPoints))
+					}
+				}
+			}
+		}
+		log.Printf("otlp #%d: headers %v, %d resources, %d data points", n, r.Header.Values("Authorization"), len(request.ResourceMetrics), points)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, "{}")
+	})
+
+	log.Printf("Stub receiver listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// otlpRequest is the part of an OTLP/JSON metrics request the monitor sends
+type otlpRequest struct {
+	ResourceMetrics []struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeMetrics []struct {
+			Scope struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"scope"`
+			Metrics []struct {
+				Name        string `json:"name"`
+				Description string `json:"description"`
+				Unit        string `json:"unit"`
+				Gauge       struct {
+					DataPoints []struct {
+						TimeUnixNano string  `json:"timeUnixNano"`
+						AsDouble     float64 `json:"asDouble"`
+					} `json:"dataPoints"`
+				} `json:"gauge"`
+			} `json:"metrics"`
+		} `json:"scopeMetrics"`
+	} `json:"resourceMetrics"`
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type series struct {
+	labels  string
+	samples []sample
+}
+
+type sample struct {
+	value     float64
+	timestamp int64
+}
+
+// splitLine splits a line protocol line at the spaces that are not escaped
+func splitLine(line string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case ' ':
+			parts = append(parts, line[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, line[start:])
+}
+
+// decodeWriteRequest parses a remote_write WriteRequest
+func decodeWriteRequest(b []byte) ([]series, error) {
+	var result []series
+	err := walkProto(b, func(field int, value []byte, _ uint64) error {
+		if field != 1 {
+			return nil
+		}
+		var s series
+		var labels []string
+		err := walkProto(value, func(field int, value []byte, _ uint64) error {
+			switch field {
+			case 1:
+				var name, labelValue string
+				err := walkProto(value, func(field int, value []byte, _ uint64) error {
+					if field == 1 {
+						name = string(value)
+					} else if field == 2 {
+						labelValue = string(value)
+					}
+					return nil
+				})
+				labels = append(labels, fmt.Sprintf("%s=%q", name, labelValue))
+				return err
+			case 2:
+				var smp sample
+				err := walkProto(value, func(field int, value []byte, number uint64) error {
+					if field == 1 {
+						smp.value = math.Float64frombits(number)
+					} else if field == 2 {
+						smp.timestamp = int64(number)
+					}
+					return nil
+				})
+				s.samples = append(s.samples, smp)
+				return err
+			}
+			return nil
+		})
+		if !sort.StringsAreSorted(labels) {
+			return fmt.Errorf("labels not sorted: %v", labels)
+		}
+		s.labels = "{" + strings.Join(labels, ",") + "}"
+		result = append(result, s)
+		return err
+	})
+	return result, err
+}
+
+// walkProto calls fn for every field of a message with either its bytes or its number
+func walkProto(b []byte, fn func(field int, value []byte, number uint64) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errors.New("bad field key")
+		}
+		b = b[n:]
+		field := int(key >> 3)
+		switch key & 7 {
+		case 0:
+			number, n := binary.Uvarint(b)
+			if n <= 0 {
+				return errors.New("bad varint")
+			}
+			b = b[n:]
+			if err := fn(field, nil, number); err != nil {
+				return err
+			}
+		case 1:
+			if len(b) < 8 {
+				return errors.New("short fixed64")
+			}
+			if err := fn(field, nil, binary.LittleEndian.Uint64(b)); err != nil {
+				return err
+			}
+			b = b[8:]
+		case 2:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return errors.New("bad length")
+			}
+			value := b[n : n+int(length)]
+			b = b[n+int(length):]
+			if err := fn(field, value, 0); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported wire type %d", key&7)
+		}
+	}
+	return nil
+}
+
+// snappyDecode decompresses a snappy block
+func snappyDecode(src []byte) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, errors.New("bad length preamble")
+	}
+	src = src[n:]
+	dst := make([]byte, 0, length)
+	for len(src) > 0 {
+		tag := src[0]
+		var offset, size int
+		switch tag & 3 {
+		case 0:
+			size = int(tag>>2) + 1
+			src = src[1:]
+			if extra := int(tag>>2) - 59; extra > 0 {
+				if len(src) < extra {
+					return nil, errors.New("short literal length")
+				}
+				size = 1
+				for i := 0; i < extra; i++ {
+					size += int(src[i]) << (8 * i)
+				}
+				src = src[extra:]
+			}
+			if len(src) < size {
+				return nil, errors.New("short literal")
+			}
+			dst = append(dst, src[:size]...)
+			src = src[size:]
+			continue
+		case 1:
+			if len(src) < 2 {
+				return nil, errors.New("short copy")
+			}
+			size = int(tag>>2&7) + 4
+			offset = int(tag>>5)<<8 | int(src[1])
+			src = src[2:]
+		case 2:
+			if len(src) < 3 {
+				return nil, errors.New("short copy")
+			}
+			size = int(tag>>2) + 1
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case 3:
+			if len(src) < 5 {
+				return nil, errors.New("short copy")
+			}
+			size = int(tag>>2) + 1
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+		if offset <= 0 || offset > len(dst) {
+			return nil, fmt.Errorf("bad copy offset %d", offset)
+		}
+		for i := 0; i < size; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if uint64(len(dst)) != length {
+		return nil, fmt.Errorf("decoded %d bytes, expected %d", len(dst), length)
+	}
+	return dst, nil
+}
@@ -0,0 +1,18 @@
+#!/bin/sh
+# Stand-in for rocm-smi that replays captured --json output for offline testing (the
+# synthetic-* directories are hand-made, not captures):
+#   ROCM_SMI_FIXTURE=rocm-6.4 ./rocm-monitor -rocm-smi-path testdata/rocm-smi/stub.sh
+dir="$(dirname "$0")/${ROCM_SMI_FIXTURE:-rocm-6.4}"
+
+case " $* " in
+*" --json "*) ;;
+*)
+	echo "stub: only --json output is captured" >&2
+	exit 2
+	;;
+esac
+
+case " $* " in
+*" --showproductname "*) cat "$dir/static.json" ;;
+*) cat "$dir/metrics.json" ;;
+esac
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readFixture returns the contents of a file below testdata
+func readFixture(t *testing.T, path ...string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(append([]string{"testdata"}, path...)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// assertGPUFields checks that exactly the given metrics are available, with the given values
+func assertGPUFields(t *testing.T, gpu GPU, want map[GPUField]float64) {
+	t.Helper()
+	for _, info := range gpuFields {
+		wantValue, wantOK := want[info.field]
+		got, ok := gpu.Value(info.field)
+		if ok != wantOK {
+			t.Errorf("GPU %d %s available = %v, want %v", gpu.ID, info.name, ok, wantOK)
+			continue
+		}
+		if ok && math.Abs(got-wantValue) > 1e-9*math.Max(1, math.Abs(wantValue)) {
+			t.Errorf("GPU %d %s = %v, want %v", gpu.ID, info.name, got, wantValue)
+		}
+	}
+}
+
+// writeFakeCommand writes an executable shell script standing in for rocm-smi or amd-smi
+func writeFakeCommand(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fake-smi")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// equalStrings reports whether two string slices hold the same elements in order
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
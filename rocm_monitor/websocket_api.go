@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// WebSocket API on /api/ws. Clients send JSON requests:
+//
+//	{"type":"subscribe","gpus":[0],"metrics":["temperature","power"]}  // empty lists select all
+//	{"type":"unsubscribe"}
+//	{"type":"set_interval","id":"1","interval":"2s"}
+//	{"type":"run_diagnostics","id":"2"}
+//	{"type":"get_config","id":"3"}
+//
+// After subscribing the client receives a "snapshot" of the selected values and then a
+// "delta" per sample holding only the values that changed. Commands are answered with a
+// "result" or "error" message carrying the request id. Commands are only accepted from
+// same-origin pages and from clients without an Origin header; -cors only opens up the
+// subscription.
+const websocketPingInterval = 30 * time.Second
+
+// wsRequest is a client message
+type wsRequest struct {
+	Type     string   `json:"type"`
+	ID       string   `json:"id,omitempty"`
+	GPUs     []int    `json:"gpus,omitempty"`
+	Metrics  []string `json:"metrics,omitempty"`
+	Interval string   `json:"interval,omitempty"`
+}
+
+// wsMessage is a server message
+type wsMessage struct {
+	Type        string                     `json:"type"`
+	ID          string                     `json:"id,omitempty"`
+	Timestamp   *time.Time                 `json:"timestamp,omitempty"`
+	GPUs        map[int]map[string]float64 `json:"gpus,omitempty"`
+	CPUUsage    *float64                   `json:"cpu_usage,omitempty"`
+	Removed     map[int][]string           `json:"removed,omitempty"`      // Metrics no longer reported
+	RemovedGPUs []int                      `json:"removed_gpus,omitempty"` // GPUs no longer reported
+	CPURemoved  bool                       `json:"cpu_removed,omitempty"`
+	Data        interface{}                `json:"data,omitempty"`
+	Error       string                     `json:"error,omitempty"`
+}
+
+// wsFilter selects the part of each sample a client receives
+type wsFilter struct {
+	gpus   map[int]bool // Empty selects every GPU
+	fields GPUField
+	cpu    bool
+}
+
+// newWSFilter builds a filter from a subscribe request
+func newWSFilter(req wsRequest) (*wsFilter, error) {
+	filter := &wsFilter{gpus: make(map[int]bool, len(req.GPUs))}
+	for _, id := range req.GPUs {
+		filter.gpus[id] = true
+	}
+	if len(req.Metrics) == 0 {
+		for _, info := range gpuFields {
+			filter.fields |= info.field
+		}
+		filter.cpu = true
+		return filter, nil
+	}
+	for _, name := range req.Metrics {
+		if name == queryMetricCPU {
+			filter.cpu = true
+			continue
+		}
+		field := gpuFieldByName(name)
+		if field == 0 {
+			return nil, fmt.Errorf("unknown metric %q", name)
+		}
+		filter.fields |= field
+	}
+	return filter, nil
+}
+
+// wsDeltaState remembers the values last sent to a client
+type wsDeltaState struct {
+	gpus map[int]map[GPUField]float64
+	cpu  *float64
+}
+
+// delta returns the message that brings the client from the last sent values to data, and
+// whether it holds any change
+func (s *wsDeltaState) delta(filter *wsFilter, data *RocmData, kind string) (wsMessage, bool) {
+	timestamp := data.Timestamp
+	msg := wsMessage{Type: kind, Timestamp: &timestamp}
+	changed := false
+
+	seen := make(map[int]bool, len(data.GPUs))
+	for i := range data.GPUs {
+		gpu := &data.GPUs[i]
+		if len(filter.gpus) > 0 && !filter.gpus[gpu.ID] {
+			continue
+		}
+		seen[gpu.ID] = true
+		previous, known := s.gpus[gpu.ID]
+		if !known {
+			previous = make(map[GPUField]float64)
+			s.gpus[gpu.ID] = previous
+		}
+
+		for _, info := range gpuFields {
+			if filter.fields&info.field == 0 {
+				continue
+			}
+			value, ok := gpu.Value(info.field)
+			old, had := previous[info.field]
+			switch {
+			case ok && (!had || old != value):
+				if msg.GPUs == nil {
+					msg.GPUs = make(map[int]map[string]float64)
+				}
+				if msg.GPUs[gpu.ID] == nil {
+					msg.GPUs[gpu.ID] = make(map[string]float64)
+				}
+				msg.GPUs[gpu.ID][info.name] = value
+				previous[info.field] = value
+				changed = true
+			case !ok && had:
+				if msg.Removed == nil {
+					msg.Removed = make(map[int][]string)
+				}
+				msg.Removed[gpu.ID] = append(msg.Removed[gpu.ID], info.name)
+				delete(previous, info.field)
+				changed = true
+			}
+		}
+	}
+	for id := range s.gpus {
+		if !seen[id] {
+			msg.RemovedGPUs = append(msg.RemovedGPUs, id)
+			delete(s.gpus, id)
+			changed = true
+		}
+	}
+
+	if filter.cpu {
+		switch {
+		case data.CPUAvailable && (s.cpu == nil || *s.cpu != data.CPUUsage):
+			cpu := data.CPUUsage
+			msg.CPUUsage = &cpu
+			s.cpu = &cpu
+			changed = true
+		case !data.CPUAvailable && s.cpu != nil:
+			msg.CPURemoved = true
+			s.cpu = nil
+			changed = true
+		}
+	}
+	return msg, changed
+}
+
+// websocketOriginAllowed rejects cross-site browser connections unless the origin is allowed
+func websocketOriginAllowed(r *http.Request, allowedOrigin string) bool {
+	origin := r.Header.Get("Origin")
+	return allowedOrigin == "*" || (origin != "" && origin == allowedOrigin) || websocketSameOrigin(r)
+}
+
+// websocketSameOrigin reports whether a connection comes from a page served by this host;
+// clients that send no Origin header are not browsers and count as same-origin
+func websocketSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && parsed.Host == r.Host
+}
+
+// websocketHandler serves the WebSocket API
+func websocketHandler(allowedOrigin string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !websocketOriginAllowed(r, allowedOrigin) {
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+			return
+		}
+		conn, err := upgradeWebSocket(w, r)
+		if err != nil {
+			log.Printf("WebSocket: %v", err)
+			return
+		}
+
+		session := &wsSession{
+			conn:     conn,
+			filters:  make(chan *wsFilter),
+			quit:     make(chan struct{}),
+			commands: websocketSameOrigin(r),
+		}
+		session.run()
+	}
+}
+
+// wsSession is one WebSocket client
+type wsSession struct {
+	conn     *wsConn
+	filters  chan *wsFilter // Subscription changes from the reader to the sample loop
+	quit     chan struct{}  // Closed when the sample loop exits
+	commands bool           // Commands are accepted; see websocketSameOrigin
+
+	diagnosticsMutex sync.Mutex
+	diagnostics      bool // A diagnostics run is in progress
+}
+
+// run forwards samples until the client disconnects or falls behind
+func (s *wsSession) run() {
+	sub := collector.Subscribe(streamBuffer)
+	defer collector.Unsubscribe(sub)
+	defer close(s.quit)
+
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		s.readLoop()
+	}()
+
+	ping := time.NewTicker(websocketPingInterval)
+	defer ping.Stop()
+
+	var filter *wsFilter
+	var state wsDeltaState
+	snapshotSent := false
+	for {
+		select {
+		case <-readerDone:
+			s.conn.Close(wsCloseNormal, "")
+			return
+		case filter = <-s.filters:
+			state = wsDeltaState{gpus: make(map[int]map[GPUField]float64)}
+			snapshotSent = false
+			if filter == nil {
+				continue
+			}
+			// Start the client off with the current values
+			latest, err := collector.GetLatest()
+			if err != nil {
+				continue
+			}
+			msg, _ := state.delta(filter, latest, "snapshot")
+			if err := s.conn.WriteJSON(msg); err != nil {
+				s.conn.Close(wsCloseNormal, "")
+				return
+			}
+			snapshotSent = true
+		case data, ok := <-sub.C:
+			if !ok {
+				s.conn.Close(wsCloseTryAgain, "client too slow")
+				return
+			}
+			if filter == nil {
+				continue
+			}
+			kind := "delta"
+			if !snapshotSent {
+				kind = "snapshot"
+			}
+			msg, changed := state.delta(filter, &data, kind)
+			if !changed && snapshotSent {
+				continue
+			}
+			if err := s.conn.WriteJSON(msg); err != nil {
+				s.conn.Close(wsCloseNormal, "")
+				return
+			}
+			snapshotSent = true
+		case <-ping.C:
+			if err := s.conn.Ping(); err != nil {
+				s.conn.Close(wsCloseNormal, "")
+				return
+			}
+		}
+	}
+}
+
+// readLoop handles client requests until the connection fails or closes
+func (s *wsSession) readLoop() {
+	for {
+		opcode, message, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if opcode != wsOpText {
+			s.conn.Close(wsCloseUnsupported, "text messages only")
+			return
+		}
+
+		var req wsRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			s.reply(wsRequest{}, nil, fmt.Errorf("invalid request: %w", err))
+			continue
+		}
+		s.handle(req)
+	}
+}
+
+// wsCommands are the requests that read or change the monitor rather than the subscription
+var wsCommands = map[string]bool{"set_interval": true, "run_diagnostics": true, "get_config": true}
+
+// handle executes one client request
+func (s *wsSession) handle(req wsRequest) {
+	if wsCommands[req.Type] && !s.commands {
+		s.reply(req, nil, fmt.Errorf("%s is only accepted from same-origin clients", req.Type))
+		return
+	}
+
+	switch req.Type {
+	case "subscribe":
+		filter, err := newWSFilter(req)
+		if err != nil {
+			s.reply(req, nil, err)
+			return
+		}
+		s.reply(req, nil, nil)
+		s.setFilter(filter)
+	case "unsubscribe":
+		s.reply(req, nil, nil)
+		s.setFilter(nil)
+	case "set_interval":
+		interval, err := setCollectionInterval(req.Interval)
+		if err != nil {
+			s.reply(req, nil, err)
+			return
+		}
+		s.reply(req, map[string]float64{"interval_seconds": interval.Seconds()}, nil)
+	case "get_config":
+		s.reply(req, collector.GetStats(), nil)
+	case "run_diagnostics":
+		s.diagnosticsMutex.Lock()
+		running := s.diagnostics
+		s.diagnostics = true
+		s.diagnosticsMutex.Unlock()
+		if running {
+			s.reply(req, nil, fmt.Errorf("diagnostics already running"))
+			return
+		}
+		// Diagnostics take a while; keep reading requests meanwhile
+		go func() {
+			results := rocmTests.Run()
+			s.diagnosticsMutex.Lock()
+			s.diagnostics = false
+			s.diagnosticsMutex.Unlock()
+			s.reply(req, results, nil)
+		}()
+	default:
+		s.reply(req, nil, fmt.Errorf("unknown request type %q", req.Type))
+	}
+}
+
+// setFilter hands a new subscription to the sample loop
+func (s *wsSession) setFilter(filter *wsFilter) {
+	select {
+	case s.filters <- filter:
+	case <-s.quit:
+	}
+}
+
+// reply answers a request with a result or an error
+func (s *wsSession) reply(req wsRequest, data interface{}, err error) {
+	msg := wsMessage{Type: "result", ID: req.ID, Data: data}
+	if err != nil {
+		msg = wsMessage{Type: "error", ID: req.ID, Error: err.Error()}
+	}
+	s.conn.WriteJSON(msg)
+}
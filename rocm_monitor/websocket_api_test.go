@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// readServerMessage reads one unmasked server frame from the client end of a connection
+func readServerMessage(t *testing.T, conn net.Conn) wsMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		t.Fatalf("reading frame header: %v", err)
+	}
+	length := int(header[1] & 0x7f)
+	if length == 126 {
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(conn, extended); err != nil {
+			t.Fatal(err)
+		}
+		length = int(binary.BigEndian.Uint16(extended))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		t.Fatalf("reading frame payload: %v", err)
+	}
+
+	var msg wsMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		t.Fatalf("invalid message %q: %v", payload, err)
+	}
+	return msg
+}
+
+func TestWebsocketSameOrigin(t *testing.T) {
+	tests := []struct {
+		origin     string
+		allowed    string
+		connect    bool
+		sameOrigin bool
+	}{
+		{"", "*", true, true},
+		{"http://monitor:8080", "*", true, true},
+		{"http://dashboard.example", "*", true, false},
+		{"http://dashboard.example", "http://dashboard.example", true, false},
+		{"http://evil.example", "http://dashboard.example", false, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "http://monitor:8080/api/ws", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := websocketOriginAllowed(r, tt.allowed); got != tt.connect {
+			t.Errorf("origin %q with -cors %q: connect = %v, want %v", tt.origin, tt.allowed, got, tt.connect)
+		}
+		if got := websocketSameOrigin(r); got != tt.sameOrigin {
+			t.Errorf("origin %q: same origin = %v, want %v", tt.origin, got, tt.sameOrigin)
+		}
+	}
+}
+
+func TestWebsocketCommandsRequireSameOrigin(t *testing.T) {
+	useCollector(t, NewCollector(CollectorConfig{Source: &fakeSource{}, Interval: 5 * time.Second}))
+
+	tests := []struct {
+		name     string
+		commands bool
+		req      wsRequest
+		wantType string
+	}{
+		{"cross-origin set_interval", false, wsRequest{Type: "set_interval", ID: "1", Interval: "3s"}, "error"},
+		{"cross-origin run_diagnostics", false, wsRequest{Type: "run_diagnostics", ID: "2"}, "error"},
+		{"cross-origin get_config", false, wsRequest{Type: "get_config", ID: "3"}, "error"},
+		{"cross-origin subscribe", false, wsRequest{Type: "subscribe", ID: "4"}, "result"},
+		{"same-origin interval below the minimum", true, wsRequest{Type: "set_interval", ID: "5", Interval: "10ms"}, "error"},
+		{"same-origin set_interval", true, wsRequest{Type: "set_interval", ID: "6", Interval: "2s"}, "result"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+
+			session := &wsSession{
+				conn:     &wsConn{conn: server, reader: bufio.NewReader(server)},
+				filters:  make(chan *wsFilter),
+				quit:     make(chan struct{}),
+				commands: tt.commands,
+			}
+			close(session.quit)
+			go session.handle(tt.req)
+
+			msg := readServerMessage(t, client)
+			if msg.Type != tt.wantType || msg.ID != tt.req.ID {
+				t.Errorf("reply = %+v, want type %q", msg, tt.wantType)
+			}
+		})
+	}
+
+	// Only the same-origin request changed the interval
+	if got := collector.Interval(); got != 2*time.Second {
+		t.Errorf("Interval = %v, want 2s", got)
+	}
+}